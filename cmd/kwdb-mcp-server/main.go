@@ -7,6 +7,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"gitee.com/kwdb/kwdb-mcp-server/pkg/server"
 	"gitee.com/kwdb/kwdb-mcp-server/pkg/version"
@@ -19,6 +20,25 @@ func main() {
 	var tlsCertFile string
 	var tlsKeyFile string
 	var adminBaseURL string
+	var readConnectionString string
+	var resultTimeZone string
+	var maxBinaryBytes int
+	var defaultRows int
+	var maxRows int
+	var maxResultBytes int
+	var txIdleTimeout time.Duration
+	var statementTimeout time.Duration
+	var tenantStatementTimeouts string
+	var policyFile string
+	var importDir string
+	var exportDir string
+	var jobDir string
+	var jobTimeout time.Duration
+	var auditFile string
+	var auditMaxSize int
+	var auditMaxBackups int
+	var auditTable string
+	var auditConnectionString string
 	var showVersion bool
 
 	flag.StringVar(&transport, "t", "stdio", "Transport type (stdio, sse, or http)")
@@ -28,6 +48,25 @@ func main() {
 	flag.StringVar(&tlsCertFile, "tls-cert", "", "TLS certificate file for HTTP mode (requires --tls-key)")
 	flag.StringVar(&tlsKeyFile, "tls-key", "", "TLS private key file for HTTP mode (requires --tls-cert)")
 	flag.StringVar(&adminBaseURL, "admin-base-url", "", "Default KWDB admin HTTP base URL for metrics history queries")
+	flag.StringVar(&readConnectionString, "read-connection-string", "", "Optional connection string with a read-only role used by read-query (requires a connection string)")
+	flag.StringVar(&resultTimeZone, "result-time-zone", "UTC", "IANA time zone used to render TIMESTAMPTZ values in query results")
+	flag.IntVar(&maxBinaryBytes, "max-binary-bytes", 0, "Maximum bytes of a binary result value before base64 encoding (0 uses the default of 64 KiB)")
+	flag.IntVar(&defaultRows, "default-rows", 20, "Rows returned per read-query call when page_size is not given")
+	flag.IntVar(&maxRows, "max-rows", 1000, "Maximum page_size a read-query call may request")
+	flag.IntVar(&maxResultBytes, "max-result-bytes", 1<<20, "Maximum serialized bytes of the rows returned by one read-query call")
+	flag.DurationVar(&txIdleTimeout, "tx-idle-timeout", 5*time.Minute, "Roll back session transactions left idle for this long")
+	flag.DurationVar(&statementTimeout, "statement-timeout", 30*time.Second, "Default statement timeout of tool calls (0 disables it)")
+	flag.StringVar(&tenantStatementTimeouts, "tenant-statement-timeouts", "", "Per-database statement timeouts, e.g. \"analytics=2m,10.0.0.5:26257/iot=10s\"")
+	flag.StringVar(&policyFile, "policy-file", "", "JSON write policy applied to the write tools")
+	flag.StringVar(&importDir, "import-dir", "", "Directory import-file may read files from (the tool is disabled without it)")
+	flag.StringVar(&exportDir, "export-dir", "", "Directory export-query writes files to (the tool is disabled without it)")
+	flag.StringVar(&jobDir, "job-dir", "", "Directory for the spill files of submit-query jobs (default: the system temporary directory)")
+	flag.DurationVar(&jobTimeout, "job-timeout", time.Hour, "Default timeout of submit-query jobs (0 disables it)")
+	flag.StringVar(&auditFile, "audit-file", "", "JSONL file every tool call is recorded in")
+	flag.IntVar(&auditMaxSize, "audit-max-size", 100, "Size in MiB at which the audit file is rotated")
+	flag.IntVar(&auditMaxBackups, "audit-max-backups", 5, "Number of rotated audit files kept")
+	flag.StringVar(&auditTable, "audit-table", "", "KWDB table every tool call is recorded in")
+	flag.StringVar(&auditConnectionString, "audit-connection-string", "", "Connection string of the audit table (default: the server's connection string)")
 	flag.BoolVar(&showVersion, "v", false, "Show version information")
 	flag.BoolVar(&showVersion, "version", false, "Show version information")
 
@@ -56,8 +95,28 @@ func main() {
 
 	// Create server - if connectionString is empty, tools must use X-Database-URI
 	s, err := server.CreateServerWithConfig(server.ServerConfig{
-		ConnectionString:    connectionString,
-		DefaultAdminBaseURL: adminBaseURL,
+		ConnectionString:        connectionString,
+		ReadConnectionString:    readConnectionString,
+		DefaultAdminBaseURL:     adminBaseURL,
+		ResultTimeZone:          resultTimeZone,
+		MaxBinaryBytes:          maxBinaryBytes,
+		DefaultRows:             defaultRows,
+		MaxRows:                 maxRows,
+		MaxResultBytes:          maxResultBytes,
+		TxIdleTimeout:           txIdleTimeout,
+		StatementTimeout:        statementTimeout,
+		TenantStatementTimeouts: tenantStatementTimeouts,
+		PolicyFile:              policyFile,
+		ImportDir:               importDir,
+		ExportDir:               exportDir,
+		JobDir:                  jobDir,
+		JobTimeout:              jobTimeout,
+		Transport:               strings.ToLower(transport),
+		AuditFile:               auditFile,
+		AuditMaxBytes:           int64(auditMaxSize) << 20,
+		AuditMaxBackups:         auditMaxBackups,
+		AuditTable:              auditTable,
+		AuditConnectionString:   auditConnectionString,
 	})
 	if err != nil {
 		transport = strings.ToLower(transport)
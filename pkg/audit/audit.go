@@ -0,0 +1,248 @@
+// Package audit records every tool call: who ran what against which database, how long it took
+// and how it ended. Records are written to sinks such as a JSONL file or a KWDB table.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Record is the audit entry of one tool call.
+type Record struct {
+	Time      time.Time `json:"time"`
+	SessionID string    `json:"session_id"`
+	Transport string    `json:"transport"`
+	// Tenant identifies the target database without revealing its credentials, see TenantID.
+	Tenant string `json:"tenant"`
+	Tool   string `json:"tool"`
+	SQL    string `json:"sql,omitempty"`
+	// Category is the statement category of SQL; for several statements, the first write category.
+	Category string `json:"category,omitempty"`
+	// Arguments holds the other scalar arguments of the call, such as table or job_id.
+	Arguments  map[string]interface{} `json:"arguments,omitempty"`
+	DurationMS int64                  `json:"duration_ms"`
+	// Rows is the number of rows read, changed or loaded, when the result reports one.
+	Rows   *int64 `json:"rows,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	// access keys the call in History, see accessKey.
+	access string
+}
+
+// Sink stores audit records.
+type Sink interface {
+	Write(record Record) error
+	Close() error
+}
+
+// Logger writes a record of every tool call to its sinks.
+type Logger struct {
+	transport string
+	sinks     []Sink
+	now       func() time.Time
+}
+
+// NewLogger returns a logger for a server serving transport (stdio, http or sse).
+func NewLogger(transport string, sinks ...Sink) *Logger {
+	return &Logger{transport: transport, sinks: sinks, now: time.Now}
+}
+
+// Close closes the sinks.
+func (l *Logger) Close() {
+	for _, sink := range l.sinks {
+		if err := sink.Close(); err != nil {
+			log.Printf("Failed to close audit sink: %v", err)
+		}
+	}
+}
+
+// Middleware is a tool handler middleware that records each call after it returns.
+func (l *Logger) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		started := l.now()
+		result, err := next(ctx, request)
+
+		databaseURI := request.Header.Get("X-Database-URI")
+		record := Record{
+			Time:       started,
+			Transport:  l.transport,
+			Tenant:     TenantID(databaseURI),
+			Tool:       request.Params.Name,
+			DurationMS: l.now().Sub(started).Milliseconds(),
+			access:     accessKey(databaseURI),
+		}
+		if session := server.ClientSessionFromContext(ctx); session != nil {
+			record.SessionID = session.SessionID()
+		}
+		record.SQL, record.Arguments = callArguments(request)
+		record.Category = sqlCategory(record.SQL)
+		record.Status, record.Error, record.Rows = outcome(result, err)
+
+		for _, sink := range l.sinks {
+			if err := sink.Write(record); err != nil {
+				log.Printf("Failed to write audit record: %v", err)
+			}
+		}
+		return result, err
+	}
+}
+
+// payloadArguments are not recorded: they hold data rather than describe the call.
+var payloadArguments = map[string]bool{"sql": true, "params": true, "rows": true, "csv": true}
+
+// callArguments returns the sql argument and the other scalar arguments of request.
+func callArguments(request mcp.CallToolRequest) (string, map[string]interface{}) {
+	var args map[string]interface{}
+	for name, value := range request.GetArguments() {
+		if payloadArguments[name] {
+			continue
+		}
+		switch value.(type) {
+		case string, bool, float64, int, int64, json.Number:
+			if args == nil {
+				args = make(map[string]interface{})
+			}
+			args[name] = value
+		}
+	}
+	return request.GetString("sql", ""), args
+}
+
+// sqlCategory classifies sql, reporting the first write category of a multi-statement text.
+func sqlCategory(sql string) string {
+	if strings.TrimSpace(sql) == "" {
+		return ""
+	}
+	statements, err := db.SplitStatements(sql)
+	if err != nil || len(statements) == 0 {
+		return string(db.CategoryUnknown)
+	}
+	for _, stmt := range statements {
+		if stmt.Category != db.CategoryRead {
+			return string(stmt.Category)
+		}
+	}
+	return string(statements[0].Category)
+}
+
+// outcome returns the status, error message and row count of a call.
+func outcome(result *mcp.CallToolResult, err error) (string, string, *int64) {
+	if err != nil {
+		return "error", err.Error(), nil
+	}
+	if result == nil {
+		return "error", "no result", nil
+	}
+
+	status, message := "success", ""
+	response, _ := result.StructuredContent.(map[string]interface{})
+	if s, ok := response["status"].(string); ok {
+		status = s
+	}
+	if e, ok := response["error"].(string); ok {
+		message = e
+	}
+	if result.IsError {
+		if status == "success" {
+			status = "error"
+		}
+		if message == "" {
+			for _, content := range result.Content {
+				if text, ok := content.(mcp.TextContent); ok {
+					message = text.Text
+					break
+				}
+			}
+		}
+	}
+	return status, message, resultRows(response["data"])
+}
+
+// resultRows finds the row count the tools report in data: affected rows of writes, loaded rows
+// of bulk loads, otherwise the returned rows.
+func resultRows(data interface{}) *int64 {
+	fields := asMap(data)
+	metadata := asMap(fields["metadata"])
+	load := asMap(fields["load"])
+
+	var found *int64
+	for _, value := range []interface{}{metadata["affected_rows"], metadata["total_affected_rows"], fields["rows_loaded"], load["rows_loaded"], metadata["row_count"], fields["row_count"]} {
+		n, ok := toInt64(value)
+		if !ok {
+			continue
+		}
+		if n != 0 {
+			return &n
+		}
+		if found == nil {
+			found = &n
+		}
+	}
+	return found
+}
+
+// asMap returns value as a JSON object; struct values such as the bulk-insert result are
+// converted through JSON.
+func asMap(value interface{}) map[string]interface{} {
+	if value == nil {
+		return nil
+	}
+	if fields, ok := value.(map[string]interface{}); ok {
+		return fields
+	}
+	var fields map[string]interface{}
+	encoded, err := json.Marshal(value)
+	if err != nil || json.Unmarshal(encoded, &fields) != nil {
+		return nil
+	}
+	return fields
+}
+
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+var dsnPassword = regexp.MustCompile(`(?i)password\s*=\s*('[^']*'|\S+)`)
+
+// TenantID identifies the database of connectionString by a hash of the connection string
+// without its password, so the audit log shows which calls share a database but never a
+// credential. The default database is "default".
+func TenantID(connectionString string) string {
+	if connectionString == "" {
+		return "default"
+	}
+	normalized := connectionString
+	if u, err := url.Parse(connectionString); err == nil && u.Scheme != "" {
+		if u.User != nil {
+			u.User = url.User(u.User.Username())
+		}
+		query := u.Query()
+		query.Del("password")
+		u.RawQuery = query.Encode()
+		normalized = u.String()
+	} else {
+		normalized = dsnPassword.ReplaceAllString(connectionString, "")
+	}
+	sum := sha256.Sum256([]byte(normalized))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
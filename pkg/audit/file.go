@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+const (
+	defaultMaxFileBytes   = 100 << 20
+	defaultMaxFileBackups = 5
+)
+
+// FileSink appends records to a JSONL file, one record per line. When the file would grow past
+// maxBytes it is renamed to path.1, older backups shift to path.2 and so on, and a new file is
+// started; backups beyond maxBackups are removed.
+type FileSink struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewFileSink opens path for appending. maxBytes and maxBackups of 0 use 100 MiB and 5.
+func NewFileSink(path string, maxBytes int64, maxBackups int) (*FileSink, error) {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxFileBytes
+	}
+	if maxBackups <= 0 {
+		maxBackups = defaultMaxFileBackups
+	}
+	f := &FileSink{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *FileSink) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write appends record as one line.
+func (f *FileSink) Write(record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return fmt.Errorf("audit log is closed")
+	}
+	if f.size > 0 && f.size+int64(len(line)) > f.maxBytes {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	return err
+}
+
+// rotate moves the current file to path.1 and starts a new one.
+func (f *FileSink) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %v", err)
+	}
+	f.file = nil
+	os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+	}
+	if err := os.Rename(f.path, f.path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %v", err)
+	}
+	return f.open()
+}
+
+// Close closes the file.
+func (f *FileSink) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,173 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+)
+
+const (
+	// maxHistoryRecords bounds the calls kept for one database; the oldest is dropped first.
+	maxHistoryRecords = 500
+	// maxHistoryDatabases bounds the databases with a history; the one written least recently is dropped first.
+	maxHistoryDatabases = 64
+	// historyTTL is how long a call stays in the history.
+	historyTTL = 24 * time.Hour
+)
+
+// HistoryFilter selects records from History. Zero fields match every record.
+type HistoryFilter struct {
+	// SessionID keeps the calls of one session.
+	SessionID string
+	// Status keeps calls with this status, such as success or error.
+	Status string
+	// Table keeps calls whose SQL or table argument names this table.
+	Table string
+	Since time.Time
+	Until time.Time
+	// Limit is the maximum number of records returned, newest first (0 returns all).
+	Limit int
+}
+
+// History is a Sink that keeps the recent SQL calls of each database in memory, so clients can
+// look up queries they ran before. Calls are kept per connection string including its password:
+// unlike Tenant, the history of a database is only visible to callers holding its credentials.
+type History struct {
+	mu      sync.Mutex
+	records map[string][]Record
+	now     func() time.Time
+}
+
+// NewHistory returns an empty history.
+func NewHistory() *History {
+	return &History{records: make(map[string][]Record), now: time.Now}
+}
+
+// Write keeps record if the call ran SQL.
+func (h *History) Write(record Record) error {
+	if record.SQL == "" {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.expireLocked()
+	if _, ok := h.records[record.access]; !ok && len(h.records) >= maxHistoryDatabases {
+		// 数据库数达到上限时，淘汰最近一次写入最早的数据库的历史
+		var oldestKey string
+		var oldest time.Time
+		for key, records := range h.records {
+			if last := records[len(records)-1].Time; oldestKey == "" || last.Before(oldest) {
+				oldestKey, oldest = key, last
+			}
+		}
+		delete(h.records, oldestKey)
+	}
+	records := append(h.records[record.access], record)
+	if len(records) > maxHistoryRecords {
+		records = append([]Record(nil), records[len(records)-maxHistoryRecords:]...)
+	}
+	h.records[record.access] = records
+	return nil
+}
+
+// Close drops the history.
+func (h *History) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = make(map[string][]Record)
+	return nil
+}
+
+// Query returns the records of connectionString ("" for the default database) that match
+// filter, newest first.
+func (h *History) Query(connectionString string, filter HistoryFilter) []Record {
+	h.mu.Lock()
+	h.expireLocked()
+	records := h.records[accessKey(connectionString)]
+	h.mu.Unlock()
+
+	// records 只会被整体替换，不会原地修改，可在锁外遍历
+	matched := []Record{}
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		if filter.SessionID != "" && r.SessionID != filter.SessionID {
+			continue
+		}
+		if filter.Status != "" && !strings.EqualFold(r.Status, filter.Status) {
+			continue
+		}
+		if !filter.Since.IsZero() && r.Time.Before(filter.Since) {
+			continue
+		}
+		if !filter.Until.IsZero() && r.Time.After(filter.Until) {
+			continue
+		}
+		if filter.Table != "" && !mentionsTable(r, filter.Table) {
+			continue
+		}
+		matched = append(matched, r)
+		if filter.Limit > 0 && len(matched) == filter.Limit {
+			break
+		}
+	}
+	return matched
+}
+
+// expireLocked drops the calls older than historyTTL, and the databases left without calls.
+func (h *History) expireLocked() {
+	cutoff := h.now().Add(-historyTTL)
+	for key, records := range h.records {
+		// 记录按写入顺序排列，过期的记录都在开头
+		n := 0
+		for n < len(records) && records[n].Time.Before(cutoff) {
+			n++
+		}
+		switch {
+		case n == len(records):
+			delete(h.records, key)
+		case n > 0:
+			h.records[key] = append([]Record(nil), records[n:]...)
+		}
+	}
+}
+
+// mentionsTable reports whether the SQL or the table argument of r names table. Qualified
+// names match on their last part.
+func mentionsTable(r Record, table string) bool {
+	name := identifierName(table[strings.LastIndex(table, ".")+1:])
+	if arg, ok := r.Arguments["table"].(string); ok && identifierName(arg[strings.LastIndex(arg, ".")+1:]) == name {
+		return true
+	}
+	tokens, err := db.Tokenize(r.SQL)
+	if err != nil {
+		return false
+	}
+	for _, tok := range tokens {
+		if (tok.Kind == db.TokenWord || tok.Kind == db.TokenQuotedIdent) && identifierName(tok.Text) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// identifierName folds an unquoted identifier to lower case and strips the quotes of a quoted one.
+func identifierName(ident string) string {
+	ident = strings.TrimSpace(ident)
+	if len(ident) >= 2 && strings.HasPrefix(ident, `"`) && strings.HasSuffix(ident, `"`) {
+		return strings.ReplaceAll(ident[1:len(ident)-1], `""`, `"`)
+	}
+	return strings.ToLower(ident)
+}
+
+// accessKey identifies the database and credentials of connectionString.
+func accessKey(connectionString string) string {
+	if connectionString == "" {
+		return "default"
+	}
+	sum := sha256.Sum256([]byte(connectionString))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	_ "github.com/lib/pq"
+)
+
+const (
+	// tableQueueSize bounds the records waiting to be inserted; records beyond it are dropped.
+	tableQueueSize    = 1024
+	tableWriteTimeout = 10 * time.Second
+)
+
+// TableSink inserts records into a KWDB table over its own connection, so auditing does not use
+// the connections of tool calls. Records are inserted in the background; when the database
+// falls behind, records that do not fit the queue are dropped and logged.
+type TableSink struct {
+	conn    *sql.DB
+	insert  string
+	records chan Record
+	done    chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewTableSink connects to connectionString and creates table if it does not exist.
+func NewTableSink(connectionString, table string) (*TableSink, error) {
+	quoted, err := db.QuoteTableName(table)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit database: %v", err)
+	}
+	conn.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), tableWriteTimeout)
+	defer cancel()
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+quoted+` (
+	ts TIMESTAMPTZ NOT NULL,
+	session_id STRING,
+	transport STRING,
+	tenant STRING,
+	tool STRING NOT NULL,
+	sql_text STRING,
+	category STRING,
+	arguments JSONB,
+	duration_ms INT8,
+	row_count INT8,
+	status STRING,
+	error STRING
+)`)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create audit table: %v", err)
+	}
+
+	t := &TableSink{
+		conn: conn,
+		insert: `INSERT INTO ` + quoted + ` (ts, session_id, transport, tenant, tool, sql_text, category, arguments, duration_ms, row_count, status, error) ` +
+			`VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		records: make(chan Record, tableQueueSize),
+		done:    make(chan struct{}),
+	}
+	go t.run()
+	return t, nil
+}
+
+// Write queues record for insertion.
+func (t *TableSink) Write(record Record) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return fmt.Errorf("audit table sink is closed")
+	}
+	select {
+	case t.records <- record:
+		return nil
+	default:
+		return fmt.Errorf("audit table queue is full, dropped record of %s", record.Tool)
+	}
+}
+
+func (t *TableSink) run() {
+	defer close(t.done)
+	for record := range t.records {
+		if err := t.write(record); err != nil {
+			log.Printf("Failed to insert audit record: %v", err)
+		}
+	}
+}
+
+func (t *TableSink) write(record Record) error {
+	var arguments interface{}
+	if len(record.Arguments) > 0 {
+		encoded, err := json.Marshal(record.Arguments)
+		if err != nil {
+			return err
+		}
+		arguments = string(encoded)
+	}
+	var rows interface{}
+	if record.Rows != nil {
+		rows = *record.Rows
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tableWriteTimeout)
+	defer cancel()
+	_, err := t.conn.ExecContext(ctx, t.insert,
+		record.Time, record.SessionID, record.Transport, record.Tenant, record.Tool, record.SQL,
+		record.Category, arguments, record.DurationMS, rows, record.Status, record.Error)
+	return err
+}
+
+// Close inserts the queued records and closes the connection.
+func (t *TableSink) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.records)
+	t.mu.Unlock()
+
+	<-t.done
+	return t.conn.Close()
+}
@@ -0,0 +1,296 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Bulk load methods.
+const (
+	// BulkMethodAuto uses COPY and falls back to INSERT when the table does not accept COPY.
+	BulkMethodAuto = "auto"
+	// BulkMethodCopy streams rows with COPY FROM STDIN.
+	BulkMethodCopy = "copy"
+	// BulkMethodInsert sends rows as batched multi-row INSERT statements.
+	BulkMethodInsert = "insert"
+)
+
+const (
+	// DefaultBulkBatchSize is the number of rows per batch when BulkOptions.BatchSize is not set.
+	DefaultBulkBatchSize = 1000
+	// MaxBulkBatchSize caps BulkOptions.BatchSize.
+	MaxBulkBatchSize = 10000
+	// maxBindParams is the PostgreSQL wire protocol limit on parameters of one statement.
+	maxBindParams = 65535
+)
+
+// BulkOptions controls how BulkInsert loads rows.
+type BulkOptions struct {
+	// Method is BulkMethodAuto (the default), BulkMethodCopy or BulkMethodInsert.
+	Method string
+	// BatchSize is the number of rows per COPY or INSERT batch (0 uses DefaultBulkBatchSize).
+	BatchSize int
+	// OnBatch, when set, is called after each batch with the progress so far.
+	OnBatch func(progress *BulkResult)
+}
+
+// BulkBatchError reports a batch that failed to load.
+type BulkBatchError struct {
+	// Batch is the zero-based batch number.
+	Batch int `json:"batch"`
+	// FirstRow is the zero-based index of the batch's first row in the input.
+	FirstRow int    `json:"first_row"`
+	Rows     int    `json:"rows"`
+	Error    string `json:"error"`
+}
+
+// BulkResult reports the outcome of BulkInsert.
+type BulkResult struct {
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"`
+	// Method is the method that loaded the rows; it is BulkMethodInsert after a fallback.
+	Method string `json:"method"`
+	// FallbackReason is the COPY error that made BulkMethodAuto switch to INSERT.
+	FallbackReason string           `json:"fallback_reason,omitempty"`
+	RowsLoaded     int64            `json:"rows_loaded"`
+	RowsFailed     int64            `json:"rows_failed"`
+	Batches        int              `json:"batches"`
+	Errors         []BulkBatchError `json:"errors"`
+	DurationMS     float64          `json:"duration_ms"`
+}
+
+// BulkInsert loads rows into table in batches. table is a possibly qualified SQL name such as
+// sensors, public.sensors or db.public.sensors; columns are exact column names, and every row
+// holds one driver value per column.
+//
+// Each COPY batch runs in its own transaction, so a failed batch is rolled back and reported
+// while the others are kept. With BulkMethodAuto, a COPY failure before any batch has loaded
+// switches the load to INSERT, which also covers time-series tables. Inside a session
+// transaction rows are inserted in that transaction and the load stops at the first failed batch.
+func BulkInsert(ctx context.Context, connectionString, table string, columns []string, rows [][]interface{}, opts BulkOptions) (*BulkResult, error) {
+	name, err := QuoteTableName(table)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("at least one column is required")
+	}
+	quotedColumns := make([]string, len(columns))
+	for i, column := range columns {
+		if column == "" {
+			return nil, fmt.Errorf("column %d has an empty name", i)
+		}
+		quotedColumns[i] = pq.QuoteIdentifier(column)
+	}
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return nil, fmt.Errorf("row %d has %d values, expected %d", i, len(row), len(columns))
+		}
+	}
+
+	method := opts.Method
+	switch method {
+	case "":
+		method = BulkMethodAuto
+	case BulkMethodAuto, BulkMethodCopy, BulkMethodInsert:
+	default:
+		return nil, fmt.Errorf("unknown bulk load method %q", opts.Method)
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBulkBatchSize
+	}
+	if batchSize > MaxBulkBatchSize {
+		batchSize = MaxBulkBatchSize
+	}
+	if limit := maxBindParams / len(columns); batchSize > limit {
+		batchSize = limit
+	}
+
+	loader := &bulkLoader{
+		copyStmt:   fmt.Sprintf("COPY %s (%s) FROM STDIN", name, strings.Join(quotedColumns, ", ")),
+		insertStmt: fmt.Sprintf("INSERT INTO %s (%s) VALUES ", name, strings.Join(quotedColumns, ", ")),
+		width:      len(columns),
+		onBatch:    opts.OnBatch,
+		result: &BulkResult{
+			Table:   table,
+			Columns: columns,
+			Method:  method,
+			Errors:  []BulkBatchError{},
+		},
+	}
+	started := time.Now()
+	defer func() { loader.result.DurationMS = durationMS(time.Since(started)) }()
+
+	if stx := sessionTxFromContext(ctx); stx != nil {
+		if method == BulkMethodCopy {
+			return nil, fmt.Errorf("copy is not supported inside a session transaction; use method insert")
+		}
+		// COPY needs a transaction of its own, so a session transaction loads with INSERT.
+		loader.result.Method = BulkMethodInsert
+		err = stx.run(ctx, func(tx *sql.Tx) error {
+			return loader.load(ctx, rows, batchSize, func(batch [][]interface{}) (int64, error) {
+				return loader.insert(ctx, tx, batch)
+			}, true)
+		})
+		return loader.result, err
+	}
+
+	err = withConn(ctx, connectionString, func(conn *sql.Conn) error {
+		copied := false
+		return loader.load(ctx, rows, batchSize, func(batch [][]interface{}) (int64, error) {
+			if loader.result.Method != BulkMethodInsert {
+				n, err := loader.copy(ctx, conn, batch)
+				if err == nil || loader.result.Method == BulkMethodCopy || copied || ctx.Err() != nil {
+					copied = copied || err == nil
+					return n, err
+				}
+				log.Printf("COPY into %s failed, falling back to INSERT: %v", table, err)
+				loader.result.Method = BulkMethodInsert
+				loader.result.FallbackReason = err.Error()
+			}
+			return loader.insert(ctx, conn, batch)
+		}, false)
+	})
+	return loader.result, err
+}
+
+// bulkLoader holds the statements and progress of one BulkInsert call.
+type bulkLoader struct {
+	copyStmt   string
+	insertStmt string
+	width      int
+	onBatch    func(*BulkResult)
+	result     *BulkResult
+}
+
+// load splits rows into batches and runs each with fn, recording failed batches. It stops at
+// the first failure when stopOnError is set, and when ctx is done.
+func (l *bulkLoader) load(ctx context.Context, rows [][]interface{}, batchSize int, fn func([][]interface{}) (int64, error), stopOnError bool) error {
+	for start, batch := 0, 0; start < len(rows); start, batch = start+batchSize, batch+1 {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		loaded, err := fn(rows[start:end])
+		l.result.Batches++
+		if err != nil && ctx.Err() != nil {
+			return err
+		}
+		if err != nil {
+			l.result.RowsFailed += int64(end - start)
+			l.result.Errors = append(l.result.Errors, BulkBatchError{
+				Batch:    batch,
+				FirstRow: start,
+				Rows:     end - start,
+				Error:    err.Error(),
+			})
+		} else {
+			l.result.RowsLoaded += loaded
+		}
+		if l.onBatch != nil {
+			l.onBatch(l.result)
+		}
+		if err != nil && stopOnError {
+			return nil
+		}
+	}
+	return nil
+}
+
+// copy streams one batch with COPY in its own transaction.
+func (l *bulkLoader) copy(ctx context.Context, conn *sql.Conn, batch [][]interface{}) (int64, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	// 提交成功后回滚不会生效；失败时丢弃整个批次
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, l.copyStmt)
+	if err != nil {
+		return 0, err
+	}
+	for _, row := range batch {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			stmt.Close()
+			return 0, err
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return 0, err
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(len(batch)), nil
+}
+
+// insert sends one batch as a multi-row INSERT.
+func (l *bulkLoader) insert(ctx context.Context, q querier, batch [][]interface{}) (int64, error) {
+	var b strings.Builder
+	b.WriteString(l.insertStmt)
+	args := make([]interface{}, 0, len(batch)*l.width)
+	for i, row := range batch {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for j := range row {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "$%d", len(args)+j+1)
+		}
+		b.WriteByte(')')
+		args = append(args, row...)
+	}
+
+	res, err := q.ExecContext(ctx, b.String(), args...)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return int64(len(batch)), nil
+	}
+	return affected, nil
+}
+
+// QuoteTableName parses a possibly qualified table name, such as sensors, public.sensors or
+// "My DB".public.sensors, and returns it with every part quoted. Unquoted parts are folded to
+// lower case like KWDB does.
+func QuoteTableName(table string) (string, error) {
+	tokens, err := Tokenize(table)
+	if err != nil {
+		return "", fmt.Errorf("invalid table name %q: %v", table, err)
+	}
+	var parts []string
+	expectName := true
+	for _, tok := range tokens {
+		switch {
+		case expectName && tok.Kind == TokenWord:
+			parts = append(parts, pq.QuoteIdentifier(strings.ToLower(tok.Text)))
+		case expectName && tok.Kind == TokenQuotedIdent:
+			parts = append(parts, tok.Text)
+		case !expectName && tok.IsOperator("."):
+		default:
+			return "", fmt.Errorf("invalid table name %q", table)
+		}
+		expectName = !expectName
+	}
+	if len(parts) == 0 || expectName || len(parts) > 3 {
+		return "", fmt.Errorf("invalid table name %q", table)
+	}
+	return strings.Join(parts, "."), nil
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestQuoteTableName(t *testing.T) {
+	tests := map[string]string{
+		"sensors":                `"sensors"`,
+		"Public.Sensors":         `"public"."sensors"`,
+		`iot.public."Readings"`:  `"iot"."public"."Readings"`,
+		` "My DB" . public . t `: `"My DB"."public"."t"`,
+	}
+	for in, want := range tests {
+		got, err := QuoteTableName(in)
+		if err != nil || got != want {
+			t.Errorf("QuoteTableName(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "a.", ".a", "a b", "a.b.c.d", "t; DROP TABLE u", "$1"} {
+		if _, err := QuoteTableName(bad); err == nil {
+			t.Errorf("QuoteTableName(%q) should fail", bad)
+		}
+	}
+}
+
+func TestBulkInsertValidatesInput(t *testing.T) {
+	ctx := context.Background()
+	if _, err := BulkInsert(ctx, "", "t", nil, nil, BulkOptions{}); err == nil {
+		t.Fatal("a load without columns should fail")
+	}
+	_, err := BulkInsert(ctx, "", "t", []string{"a", "b"}, [][]interface{}{{1, 2}, {3}}, BulkOptions{})
+	if err == nil || !strings.Contains(err.Error(), "row 1 has 1 values") {
+		t.Fatalf("a short row should be reported, got %v", err)
+	}
+	if _, err := BulkInsert(ctx, "", "t", []string{"a"}, nil, BulkOptions{Method: "merge"}); err == nil {
+		t.Fatal("an unknown method should fail")
+	}
+}
+
+func TestBulkLoaderRecordsFailedBatches(t *testing.T) {
+	l := &bulkLoader{result: &BulkResult{Errors: []BulkBatchError{}}}
+	rows := make([][]interface{}, 7)
+	var sizes []int
+	err := l.load(context.Background(), rows, 3, func(batch [][]interface{}) (int64, error) {
+		sizes = append(sizes, len(batch))
+		if len(sizes) == 2 {
+			return 0, fmt.Errorf("duplicate key")
+		}
+		return int64(len(batch)), nil
+	}, false)
+	if err != nil {
+		t.Fatalf("load returned error: %v", err)
+	}
+	if fmt.Sprint(sizes) != "[3 3 1]" {
+		t.Fatalf("unexpected batch sizes %v", sizes)
+	}
+	r := l.result
+	if r.Batches != 3 || r.RowsLoaded != 4 || r.RowsFailed != 3 || len(r.Errors) != 1 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	if e := r.Errors[0]; e.Batch != 1 || e.FirstRow != 3 || e.Rows != 3 || e.Error != "duplicate key" {
+		t.Fatalf("unexpected batch error %+v", e)
+	}
+
+	// A session transaction stops at the first failed batch.
+	l = &bulkLoader{result: &BulkResult{Errors: []BulkBatchError{}}}
+	calls := 0
+	l.load(context.Background(), rows, 3, func(batch [][]interface{}) (int64, error) {
+		calls++
+		return 0, fmt.Errorf("failed")
+	}, true)
+	if calls != 1 || l.result.RowsFailed != 3 {
+		t.Fatalf("load should stop after the first failure, ran %d batches: %+v", calls, l.result)
+	}
+}
@@ -0,0 +1,273 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ValueCodec maps driver values to stable JSON shapes based on the column's database type.
+//
+//   - TIMESTAMPTZ values are rendered as RFC3339Nano in Location; TIMESTAMP values keep
+//     their wall clock and are rendered in UTC; DATE and TIME use their SQL text forms.
+//   - DECIMAL/NUMERIC values are exact decimal strings.
+//   - BYTEA/BYTES/VARBYTES values are base64 strings. A value longer than MaxBytes becomes
+//     {"base64": <first MaxBytes bytes>, "truncated": true, "length": <full length>}.
+//   - JSON/JSONB values are embedded as native JSON; arrays become JSON arrays.
+//   - INTERVAL and other textual types are strings; NaN and ±Inf floats are strings.
+type ValueCodec struct {
+	// Location is the time zone used to render TIMESTAMPTZ values.
+	Location *time.Location
+	// MaxBytes caps binary values before base64 encoding; 0 disables the cap.
+	MaxBytes int
+}
+
+// DefaultValueCodec renders timestamps in UTC and caps binary values at 64 KiB.
+var DefaultValueCodec = ValueCodec{
+	Location: time.UTC,
+	MaxBytes: 64 * 1024,
+}
+
+var (
+	valueCodecMu sync.RWMutex
+	valueCodec   = DefaultValueCodec
+)
+
+// SetValueCodec replaces the codec shared by every scan loop.
+func SetValueCodec(codec ValueCodec) {
+	if codec.Location == nil {
+		codec.Location = time.UTC
+	}
+	valueCodecMu.Lock()
+	defer valueCodecMu.Unlock()
+	valueCodec = codec
+}
+
+// GetValueCodec returns the codec shared by every scan loop.
+func GetValueCodec() ValueCodec {
+	valueCodecMu.RLock()
+	defer valueCodecMu.RUnlock()
+	return valueCodec
+}
+
+// Encode converts a scanned driver value into its JSON shape for a column of type dbType.
+func (c ValueCodec) Encode(value interface{}, dbType string) interface{} {
+	typeName := strings.ToUpper(dbType)
+
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return c.encodeTime(v, typeName)
+	case []byte:
+		return c.encodeBytes(v, typeName)
+	case string:
+		return c.encodeBytes([]byte(v), typeName)
+	case float64:
+		return encodeFloat(v)
+	case float32:
+		return encodeFloat(float64(v))
+	default:
+		return v
+	}
+}
+
+func (c ValueCodec) encodeTime(t time.Time, typeName string) interface{} {
+	switch typeName {
+	case "DATE":
+		return t.Format("2006-01-02")
+	case "TIME":
+		return t.Format("15:04:05.999999999")
+	case "TIMETZ":
+		return t.Format("15:04:05.999999999Z07:00")
+	case "TIMESTAMP":
+		// TIMESTAMP has no zone; keep the stored wall clock.
+		return t.UTC().Format(time.RFC3339Nano)
+	default:
+		loc := c.Location
+		if loc == nil {
+			loc = time.UTC
+		}
+		return t.In(loc).Format(time.RFC3339Nano)
+	}
+}
+
+func (c ValueCodec) encodeBytes(b []byte, typeName string) interface{} {
+	switch {
+	case typeName == "BYTEA" || typeName == "BYTES" || typeName == "VARBYTES":
+		if c.MaxBytes > 0 && len(b) > c.MaxBytes {
+			// 截断的值返回标记对象，避免客户端把前缀误认为完整的值
+			return map[string]interface{}{
+				"base64":    base64.StdEncoding.EncodeToString(b[:c.MaxBytes]),
+				"truncated": true,
+				"length":    len(b),
+			}
+		}
+		return base64.StdEncoding.EncodeToString(b)
+	case typeName == "JSON" || typeName == "JSONB":
+		if json.Valid(b) {
+			return json.RawMessage(append([]byte(nil), b...))
+		}
+		return string(b)
+	case strings.HasPrefix(typeName, "_"):
+		elements, ok := parseArrayLiteral(string(b))
+		if !ok {
+			return string(b)
+		}
+		return c.encodeArray(elements, typeName[1:])
+	default:
+		// NUMERIC/DECIMAL stay exact strings; INTERVAL and text types are strings too.
+		return string(b)
+	}
+}
+
+// encodeArray decodes array elements according to the element type.
+func (c ValueCodec) encodeArray(elements []interface{}, elementType string) []interface{} {
+	for i, element := range elements {
+		switch e := element.(type) {
+		case string:
+			elements[i] = c.encodeArrayElement(e, elementType)
+		case []interface{}:
+			elements[i] = c.encodeArray(e, elementType)
+		}
+	}
+	return elements
+}
+
+func (c ValueCodec) encodeArrayElement(s string, elementType string) interface{} {
+	switch elementType {
+	case "INT2", "INT4", "INT8":
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+	case "FLOAT4", "FLOAT8":
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return encodeFloat(f)
+		}
+	case "BOOL":
+		return s == "t" || s == "true"
+	case "BYTEA":
+		// Array elements of bytea arrive in hex text form (\x...).
+		if decoded, err := hex.DecodeString(strings.TrimPrefix(s, `\x`)); err == nil {
+			return c.encodeBytes(decoded, elementType)
+		}
+		return s
+	}
+	return c.encodeBytes([]byte(s), elementType)
+}
+
+func encodeFloat(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	default:
+		return f
+	}
+}
+
+// parseArrayLiteral parses a PostgreSQL array literal such as {1,"a b",NULL,{2,3}}.
+// Unquoted NULL elements become nil; all other elements are returned as strings.
+func parseArrayLiteral(s string) ([]interface{}, bool) {
+	s = strings.TrimSpace(s)
+	// Skip an optional dimension decoration such as [1:2]={...}.
+	if strings.HasPrefix(s, "[") {
+		idx := strings.Index(s, "=")
+		if idx < 0 {
+			return nil, false
+		}
+		s = s[idx+1:]
+	}
+	elements, rest, ok := parseArrayLevel(s)
+	if !ok || strings.TrimSpace(rest) != "" {
+		return nil, false
+	}
+	return elements, true
+}
+
+func parseArrayLevel(s string) ([]interface{}, string, bool) {
+	if !strings.HasPrefix(s, "{") {
+		return nil, s, false
+	}
+	s = s[1:]
+	elements := []interface{}{}
+	if strings.HasPrefix(s, "}") {
+		return elements, s[1:], true
+	}
+
+	for {
+		switch {
+		case strings.HasPrefix(s, "{"):
+			nested, rest, ok := parseArrayLevel(s)
+			if !ok {
+				return nil, s, false
+			}
+			elements = append(elements, nested)
+			s = rest
+		case strings.HasPrefix(s, `"`):
+			var sb strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				sb.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, s, false
+			}
+			elements = append(elements, sb.String())
+			s = s[i+1:]
+		default:
+			end := strings.IndexAny(s, ",}")
+			if end < 0 {
+				return nil, s, false
+			}
+			token := strings.TrimSpace(s[:end])
+			if strings.EqualFold(token, "NULL") {
+				elements = append(elements, nil)
+			} else {
+				elements = append(elements, token)
+			}
+			s = s[end:]
+		}
+
+		if s == "" {
+			return nil, s, false
+		}
+		if s[0] == '}' {
+			return elements, s[1:], true
+		}
+		if s[0] != ',' {
+			return nil, s, false
+		}
+		s = s[1:]
+	}
+}
+
+// textValue returns the textual form of a scanned metadata value.
+func textValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case time.Time:
+		return GetValueCodec().encodeTime(v, "TIMESTAMPTZ").(string)
+	default:
+		if encoded, err := json.Marshal(v); err == nil {
+			return string(encoded)
+		}
+		return ""
+	}
+}
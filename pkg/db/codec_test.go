@@ -0,0 +1,71 @@
+package db
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValueCodecEncode(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	codec := ValueCodec{Location: shanghai, MaxBytes: 2}
+	ts := time.Date(2025, 1, 2, 3, 4, 5, 123456000, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  interface{}
+		dbType string
+		want   interface{}
+	}{
+		{name: "nil", value: nil, dbType: "INT8", want: nil},
+		{name: "timestamptz uses codec zone", value: ts, dbType: "TIMESTAMPTZ", want: "2025-01-02T11:04:05.123456+08:00"},
+		{name: "timestamp keeps wall clock", value: ts, dbType: "TIMESTAMP", want: "2025-01-02T03:04:05.123456Z"},
+		{name: "date", value: ts, dbType: "DATE", want: "2025-01-02"},
+		{name: "decimal stays exact", value: []byte("12345678901234567890.000001"), dbType: "NUMERIC", want: "12345678901234567890.000001"},
+		{name: "interval", value: []byte("1 day 02:00:00"), dbType: "INTERVAL", want: "1 day 02:00:00"},
+		{name: "bytes are base64", value: []byte{1, 2}, dbType: "BYTEA", want: "AQI="},
+		{name: "capped bytes are marked", value: []byte{1, 2, 3}, dbType: "BYTEA", want: map[string]interface{}{"base64": "AQI=", "truncated": true, "length": 3}},
+		{name: "text", value: "hello", dbType: "TEXT", want: "hello"},
+		{name: "int", value: int64(7), dbType: "INT8", want: int64(7)},
+		{name: "nan", value: math.NaN(), dbType: "FLOAT8", want: "NaN"},
+		{name: "int array", value: []byte("{1,NULL,3}"), dbType: "_INT8", want: []interface{}{int64(1), nil, int64(3)}},
+		{name: "text array", value: []byte(`{"a,b",c,"NULL"}`), dbType: "_TEXT", want: []interface{}{"a,b", "c", "NULL"}},
+		{name: "bool array", value: []byte("{t,f}"), dbType: "_BOOL", want: []interface{}{true, false}},
+		{name: "nested array", value: []byte("{{1,2},{3,4}}"), dbType: "_INT4", want: []interface{}{[]interface{}{int64(1), int64(2)}, []interface{}{int64(3), int64(4)}}},
+		{name: "invalid array falls back to text", value: []byte("{1,2"), dbType: "_INT4", want: "{1,2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := codec.Encode(tt.value, tt.dbType)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Encode(%v, %s) = %#v, want %#v", tt.value, tt.dbType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValueCodecEncodeJSON(t *testing.T) {
+	got := DefaultValueCodec.Encode([]byte(`{"a": [1, 2]}`), "JSONB")
+	raw, ok := got.(json.RawMessage)
+	if !ok {
+		t.Fatalf("JSONB should encode as json.RawMessage, got %T", got)
+	}
+
+	encoded, err := json.Marshal(map[string]interface{}{"v": raw})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(encoded) != `{"v":{"a":[1,2]}}` {
+		t.Fatalf("JSONB should be embedded natively, got %s", encoded)
+	}
+
+	if got := DefaultValueCodec.Encode([]byte(`not json`), "JSONB"); got != "not json" {
+		t.Fatalf("invalid JSONB should fall back to text, got %#v", got)
+	}
+}
@@ -16,6 +16,11 @@ func InitDB(connectionString string) error {
 	return poolMgr.InitializePool(connectionString)
 }
 
+// InitReadDB initializes the optional read-only pool used by read-query in single-DB mode.
+func InitReadDB(readConnectionString string) error {
+	return GetPoolManager().InitializeReadPool(readConnectionString)
+}
+
 // GetTables retrieves all table names
 func GetTables() ([]string, error) {
 	return GetTablesWithContext(context.Background())
@@ -58,49 +63,40 @@ func GetTableColumns(tableName string) ([]map[string]interface{}, error) {
 
 // GetTableColumnsWithContext retrieves table structure with context
 func GetTableColumnsWithContext(ctx context.Context, tableName string) ([]map[string]interface{}, error) {
+	return GetTableColumnsWithURI(ctx, "", tableName)
+}
+
+// GetTableColumnsWithURI 使用指定数据库 URI 获取表结构（为空时使用默认连接池）。
+// 在会话事务中查询该事务，因此能看到事务内新建的表。
+func GetTableColumnsWithURI(ctx context.Context, connectionString, tableName string) ([]map[string]interface{}, error) {
 	var result []map[string]interface{}
 
-	poolMgr := GetPoolManager()
-	err := poolMgr.ExecuteWithConnection(ctx, func(db *sql.DB) error {
+	err := withQuerier(ctx, connectionString, func(q querier) error {
 		showColumnsQuery := fmt.Sprintf("SHOW COLUMNS FROM %s WITH COMMENT", tableName)
-		rows, err := db.QueryContext(ctx, showColumnsQuery)
+		rows, err := q.QueryContext(ctx, showColumnsQuery)
 		if err != nil {
-			return fmt.Errorf("failed to get table columns: %v", err)
+			return fmt.Errorf("failed to get table columns: %w", err)
 		}
 		defer rows.Close()
 
-		// 获取列名
-		columns, err := rows.Columns()
+		// 获取列信息
+		columns, err := resultColumns(rows)
 		if err != nil {
-			return fmt.Errorf("failed to get column names: %v", err)
+			return err
 		}
 
-		// 处理结果
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-
+		// 处理结果，值编码与查询结果共用同一个 codec
+		codec := GetValueCodec()
 		for rows.Next() {
-			// 初始化指针数组
-			for i := range columns {
-				valuePtrs[i] = &values[i]
-			}
-
-			// 扫描行数据
-			if err := rows.Scan(valuePtrs...); err != nil {
+			values, err := scanRow(rows, columns, codec)
+			if err != nil {
 				return fmt.Errorf("failed to scan column info: %v", err)
 			}
 
 			// 创建列信息映射
 			colInfo := make(map[string]interface{})
 			for i, col := range columns {
-				val := values[i]
-				// 处理不同数据类型
-				switch v := val.(type) {
-				case []byte:
-					colInfo[col] = string(v)
-				default:
-					colInfo[col] = v
-				}
+				colInfo[col.Name] = values[i]
 			}
 
 			result = append(result, colInfo)
@@ -113,98 +109,52 @@ func GetTableColumnsWithContext(ctx context.Context, tableName string) ([]map[st
 }
 
 // ClassifyQuery checks if a query is a read or write operation
-// Returns true for write operations, false for read operations
+// Returns true and the operation keyword when any statement in query is not a read.
+// SQL that cannot be tokenized is reported as a write so it is never treated as a safe read.
 func ClassifyQuery(query string) (bool, string) {
-	// Trim and convert to lowercase
-	trimmedQuery := strings.TrimSpace(strings.ToLower(query))
-
-	// Define write operation patterns (DML and DDL)
-	writePatterns := map[string]*regexp.Regexp{
-		// DML operations
-		"INSERT": regexp.MustCompile(`^insert\s`),
-		"UPDATE": regexp.MustCompile(`^update\s`),
-		"DELETE": regexp.MustCompile(`^delete\s`),
-		// DDL operations
-		"DROP":     regexp.MustCompile(`^drop\s`),
-		"CREATE":   regexp.MustCompile(`^create\s`),
-		"ALTER":    regexp.MustCompile(`^alter\s`),
-		"TRUNCATE": regexp.MustCompile(`^truncate\s`),
-		"GRANT":    regexp.MustCompile(`^grant\s`),
-		"REVOKE":   regexp.MustCompile(`^revoke\s`),
-		"COMMENT":  regexp.MustCompile(`^comment\s`),
+	statements, err := SplitStatements(query)
+	if err != nil {
+		return true, string(CategoryUnknown)
 	}
 
-	// Check if the query matches any write operation pattern
-	for opName, pattern := range writePatterns {
-		if pattern.MatchString(trimmedQuery) {
-			return true, opName // true means it's a write operation
+	for _, stmt := range statements {
+		if stmt.Category != CategoryRead {
+			return true, stmt.Keyword
 		}
 	}
-
-	// If no write operation pattern matches, it's a read operation
 	return false, ""
 }
 
 // ExecuteQuery executes read-only queries using connection pool
-func ExecuteQuery(query string) ([]map[string]interface{}, error) {
-	return ExecuteQueryWithContext(context.Background(), query)
+func ExecuteQuery(query string, args ...interface{}) (*ResultSet, error) {
+	return ExecuteQueryWithContext(context.Background(), query, args...)
+}
+
+// ExecuteQueryWithContext executes queries with context.
+// args are bound to the $1..$n placeholders in query.
+func ExecuteQueryWithContext(ctx context.Context, query string, args ...interface{}) (*ResultSet, error) {
+	return ExecuteQueryWithOptions(ctx, "", query, QueryOptions{}, args...)
 }
 
-// ExecuteQueryWithContext executes queries with context
-func ExecuteQueryWithContext(ctx context.Context, query string) ([]map[string]interface{}, error) {
+// ExecuteQueryWithOptions executes a read-only query with paging options.
+// connectionString selects a tenant database; when empty the default pool is used.
+// The query runs on a read-only connection, see withReadOnly.
+func ExecuteQueryWithOptions(ctx context.Context, connectionString, query string, opts QueryOptions, args ...interface{}) (*ResultSet, error) {
 	// 检查查询类型
-	isWrite, opName := ClassifyQuery(query)
-	if isWrite {
-		return nil, fmt.Errorf("write operation not allowed in read-query: %s", opName)
+	if err := ValidateReadQuery(query); err != nil {
+		return nil, err
 	}
 
-	var result []map[string]interface{}
-
-	poolMgr := GetPoolManager()
-	err := poolMgr.ExecuteWithConnection(ctx, func(db *sql.DB) error {
-		rows, err := db.QueryContext(ctx, query)
+	var result *ResultSet
+	err := withReadOnly(ctx, connectionString, query, func(q querier) error {
+		rows, err := q.QueryContext(ctx, query, args...)
 		if err != nil {
 			return fmt.Errorf("query execution failed: %v", err)
 		}
 		defer rows.Close()
 
-		// 获取列名
-		columns, err := rows.Columns()
-		if err != nil {
-			return fmt.Errorf("failed to get column names: %v", err)
-		}
-
-		// 处理结果集
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-
-		for rows.Next() {
-			// 初始化指针数组
-			for i := range columns {
-				valuePtrs[i] = &values[i]
-			}
-
-			// 扫描行数据
-			if err := rows.Scan(valuePtrs...); err != nil {
-				return fmt.Errorf("failed to scan row: %v", err)
-			}
-
-			// 创建行映射
-			row := make(map[string]interface{})
-			for i, col := range columns {
-				val := values[i]
-				// 处理不同数据类型
-				switch v := val.(type) {
-				case []byte:
-					row[col] = string(v)
-				default:
-					row[col] = v
-				}
-			}
-			result = append(result, row)
-		}
-
-		return rows.Err()
+		result, err = scanResultSet(rows, opts)
+		return err
 	})
 
 	if err != nil {
@@ -215,136 +165,48 @@ func ExecuteQueryWithContext(ctx context.Context, query string) ([]map[string]in
 }
 
 // ExecuteWriteQuery executes write operations using connection pool
-func ExecuteWriteQuery(query string) (int64, error) {
-	return ExecuteWriteQueryWithContext(context.Background(), query)
+func ExecuteWriteQuery(query string, args ...interface{}) (int64, error) {
+	return ExecuteWriteQueryWithContext(context.Background(), query, args...)
 }
 
-// ExecuteWriteQueryWithContext executes write operations with context
-func ExecuteWriteQueryWithContext(ctx context.Context, query string) (int64, error) {
-	// 检查查询类型
-	isWrite, _ := ClassifyQuery(query)
-	if !isWrite {
-		return 0, fmt.Errorf("not a write operation: expected INSERT, UPDATE, DELETE, CREATE, DROP, ALTER, etc")
-	}
-
-	var rowsAffected int64
-
-	poolMgr := GetPoolManager()
-	err := poolMgr.ExecuteWithConnection(ctx, func(db *sql.DB) error {
-		result, err := db.ExecContext(ctx, query)
-		if err != nil {
-			return fmt.Errorf("write operation failed: %v", err)
-		}
-
-		affected, err := result.RowsAffected()
-		if err != nil {
-			return fmt.Errorf("failed to get affected rows: %v", err)
-		}
-
-		rowsAffected = affected
-		return nil
-	})
+// ExecuteWriteQueryWithContext executes write operations with context.
+// args are bound to the $1..$n placeholders in query.
+func ExecuteWriteQueryWithContext(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	return executeWriteQuery(ctx, "", query, args...)
+}
 
-	return rowsAffected, err
+// executeWriteQuery runs a single write statement and returns the number of affected rows.
+func executeWriteQuery(ctx context.Context, connectionString, query string, args ...interface{}) (int64, error) {
+	result, err := ExecuteWriteQueryWithOptions(ctx, connectionString, query, WriteOptions{}, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.AffectedRows, nil
 }
 
 // ExecuteQueryWithURI 使用指定数据库 URI 执行只读查询。
 // 该方法用于无状态多租户场景，每次调用都必须提供完整的数据库 URI。
-func ExecuteQueryWithURI(ctx context.Context, connectionString, query string) ([]map[string]interface{}, error) {
-	// 检查查询类型
-	isWrite, opName := ClassifyQuery(query)
-	if isWrite {
-		return nil, fmt.Errorf("write operation not allowed in read-query: %s", opName)
+func ExecuteQueryWithURI(ctx context.Context, connectionString, query string, args ...interface{}) (*ResultSet, error) {
+	if connectionString == "" {
+		return nil, fmt.Errorf("connection string cannot be empty")
 	}
-
-	var result []map[string]interface{}
-
-	multiPoolMgr := GetMultiPoolManager()
-	err := multiPoolMgr.ExecuteWithURI(ctx, connectionString, func(db *sql.DB) error {
-		rows, err := db.QueryContext(ctx, query)
-		if err != nil {
-			return fmt.Errorf("query execution failed: %v", err)
-		}
-		defer rows.Close()
-
-		// 获取列名
-		columns, err := rows.Columns()
-		if err != nil {
-			return fmt.Errorf("failed to get column names: %v", err)
-		}
-
-		// 处理结果集
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-
-		for rows.Next() {
-			// 初始化指针数组
-			for i := range columns {
-				valuePtrs[i] = &values[i]
-			}
-
-			// 扫描行数据
-			if err := rows.Scan(valuePtrs...); err != nil {
-				return fmt.Errorf("failed to scan row: %v", err)
-			}
-
-			// 创建行映射
-			row := make(map[string]interface{})
-			for i, col := range columns {
-				val := values[i]
-				// 处理不同数据类型
-				switch v := val.(type) {
-				case []byte:
-					row[col] = string(v)
-				default:
-					row[col] = v
-				}
-			}
-			result = append(result, row)
-		}
-
-		return rows.Err()
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	return result, nil
+	return ExecuteQueryWithOptions(ctx, connectionString, query, QueryOptions{}, args...)
 }
 
 // ExecuteWriteQueryWithURI 使用指定数据库 URI 执行写操作。
 // 该方法用于无状态多租户场景，每次调用都必须提供完整的数据库 URI。
-func ExecuteWriteQueryWithURI(ctx context.Context, connectionString, query string) (int64, error) {
-	// 检查查询类型
-	isWrite, _ := ClassifyQuery(query)
-	if !isWrite {
-		return 0, fmt.Errorf("not a write operation: expected INSERT, UPDATE, DELETE, CREATE, DROP, ALTER, etc")
+func ExecuteWriteQueryWithURI(ctx context.Context, connectionString, query string, args ...interface{}) (int64, error) {
+	if connectionString == "" {
+		return 0, fmt.Errorf("connection string cannot be empty")
 	}
-
-	var rowsAffected int64
-
-	multiPoolMgr := GetMultiPoolManager()
-	err := multiPoolMgr.ExecuteWithURI(ctx, connectionString, func(db *sql.DB) error {
-		result, err := db.ExecContext(ctx, query)
-		if err != nil {
-			return fmt.Errorf("write operation failed: %v", err)
-		}
-
-		affected, err := result.RowsAffected()
-		if err != nil {
-			return fmt.Errorf("failed to get affected rows: %v", err)
-		}
-
-		rowsAffected = affected
-		return nil
-	})
-
-	return rowsAffected, err
+	return executeWriteQuery(ctx, connectionString, query, args...)
 }
 
 // Close closes the connection pool
 func Close() {
+	// 先回滚会话事务，释放它们占用的连接
+	GetTxManager().RollbackAll()
+
 	poolMgr := GetPoolManager()
 	_ = poolMgr.Close()
 
@@ -467,35 +329,15 @@ func getDatabaseInfoByNameWithExecutor(ctx context.Context, connectionString, db
 					}
 
 					// Extract database name and engine type from the scanned values
-					var dbNameResult, engineTypeResult string
-					if dbVal := values[dbNameIdx]; dbVal != nil {
-						if byteVal, ok := dbVal.([]byte); ok {
-							dbNameResult = string(byteVal)
-						} else if strVal, ok := dbVal.(string); ok {
-							dbNameResult = strVal
-						}
-					}
-
-					if engineVal := values[engineTypeIdx]; engineVal != nil {
-						if byteVal, ok := engineVal.([]byte); ok {
-							engineTypeResult = string(byteVal)
-						} else if strVal, ok := engineVal.(string); ok {
-							engineTypeResult = strVal
-						}
-					}
+					dbNameResult := textValue(values[dbNameIdx])
+					engineTypeResult := textValue(values[engineTypeIdx])
 
 					// Extract comment if available
 					if commentIdx != -1 && dbNameResult == dbName {
-						if commentVal := values[commentIdx]; commentVal != nil {
-							if byteVal, ok := commentVal.([]byte); ok {
-								comment = string(byteVal)
-							} else if strVal, ok := commentVal.(string); ok {
-								comment = strVal
-							}
-							// Handle NULL comment values
-							if comment == "NULL" {
-								comment = ""
-							}
+						comment = textValue(values[commentIdx])
+						// Handle NULL comment values
+						if comment == "NULL" {
+							comment = ""
 						}
 					}
 
@@ -1066,13 +908,10 @@ func GetTableMetadata(tableName string) (map[string]interface{}, error) {
 
 			// Extract create statement
 			val := values[createColumnIndex]
-			if byteVal, ok := val.([]byte); ok {
-				createTableSQL = string(byteVal)
-			} else if strVal, ok := val.(string); ok {
-				createTableSQL = strVal
-			} else {
+			if val == nil {
 				return fmt.Errorf("unexpected type for create_statement column")
 			}
+			createTableSQL = textValue(val)
 		} else {
 			return fmt.Errorf("no rows returned by SHOW CREATE TABLE %s", tableName)
 		}
@@ -1223,13 +1062,10 @@ func GetTableIndexes(tableName string, createTableSQL string, tableType string)
 
 				// Extract create statement
 				val := values[createColumnIndex]
-				if byteVal, ok := val.([]byte); ok {
-					createTableSQL = string(byteVal)
-				} else if strVal, ok := val.(string); ok {
-					createTableSQL = strVal
-				} else {
+				if val == nil {
 					return fmt.Errorf("unexpected type for create_statement column")
 				}
+				createTableSQL = textValue(val)
 			} else {
 				return fmt.Errorf("no rows returned by SHOW CREATE TABLE %s", tableName)
 			}
@@ -137,21 +137,22 @@ func TestExecuteQuery(t *testing.T) {
 	}
 
 	// Check result structure
-	if len(result) != 1 {
-		t.Fatalf("Expected 1 row, got %d", len(result))
+	if len(result.Rows) != 1 {
+		t.Fatalf("Expected 1 row, got %d", len(result.Rows))
 	}
 
 	// Check for test column existence
-	if val, ok := result[0]["test"]; !ok {
-		t.Fatalf("Expected column 'test' in result, got %v", result[0])
+	if len(result.Columns) != 1 || result.Columns[0].Name != "test" {
+		t.Fatalf("Expected column 'test' in result, got %v", result.Columns)
 	} else {
+		val := result.Rows[0][0]
 		// Log the actual value and type for debugging
 		t.Logf("Value: %v, Type: %T", val, val)
 
 		// Convert to string for comparison if needed
 		valStr := fmt.Sprintf("%v", val)
 		if valStr != "1" {
-			t.Fatalf("Expected result[0]['test'] value to be '1', got '%s'", valStr)
+			t.Fatalf("Expected result.Rows[0][0] value to be '1', got '%s'", valStr)
 		}
 	}
 
@@ -238,3 +239,29 @@ func TestClose(t *testing.T) {
 		t.Fatal("Connection pool still initialized after Close()")
 	}
 }
+
+func TestInitializeReadPool(t *testing.T) {
+	pm := &PoolManager{config: DefaultPoolConfig}
+	if err := pm.InitializeReadPool("postgresql://reader@localhost:26257/db"); err == nil {
+		t.Fatal("expected an error before the main pool is initialized")
+	}
+	if err := pm.InitializePool("postgresql://writer@localhost:26257/db"); err != nil {
+		t.Fatalf("InitializePool failed: %v", err)
+	}
+	defer pm.Close()
+	if err := pm.InitializeReadPool(""); err == nil {
+		t.Fatal("expected an error for an empty read connection string")
+	}
+	if err := pm.InitializeReadPool("postgresql://reader@localhost:26257/db"); err != nil {
+		t.Fatalf("InitializeReadPool failed: %v", err)
+	}
+	if pm.readDB == nil || pm.readConnectionString == "" {
+		t.Fatal("read pool was not configured")
+	}
+	if err := pm.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if pm.readDB != nil {
+		t.Fatal("Close did not release the read pool")
+	}
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// maxPlanRows bounds the EXPLAIN output read for one statement.
+const maxPlanRows = 10000
+
+// ExplainResult is the parsed plan of a statement.
+type ExplainResult struct {
+	// Query is the EXPLAIN statement that ran.
+	Query   string
+	Analyze bool
+	// RolledBack is true when EXPLAIN ANALYZE executed a write inside a transaction that was rolled back.
+	RolledBack bool
+	Plan       *Plan
+}
+
+// ExplainQuery runs EXPLAIN, or EXPLAIN ANALYZE when analyze is set, for a single read or DML
+// statement and parses the output into a Plan.
+// connectionString selects a tenant database; when empty the default pool is used.
+//
+// EXPLAIN ANALYZE executes the statement. Read statements run on a read-only connection like
+// read-query, see withReadOnly. DML runs in a transaction that is always rolled back; that is
+// refused inside a session transaction, which it could not undo.
+func ExplainQuery(ctx context.Context, connectionString, query string, analyze bool, args ...interface{}) (*ExplainResult, error) {
+	stmt, err := singleStatement(query, "explain-query")
+	if err != nil {
+		return nil, err
+	}
+	if toks := SignificantTokens(stmt.Tokens); len(toks) > 0 && toks[0].Is("EXPLAIN") {
+		return nil, fmt.Errorf("pass the statement to explain without the EXPLAIN keyword")
+	}
+	if stmt.Category != CategoryRead && stmt.Category != CategoryDML {
+		return nil, fmt.Errorf("explain-query supports read and DML statements, got %s statement: %s", stmt.Category, stmt.Keyword)
+	}
+
+	explain := "EXPLAIN " + stmt.SQL
+	if analyze {
+		explain = "EXPLAIN ANALYZE " + stmt.SQL
+	}
+	result := &ExplainResult{Query: explain, Analyze: analyze}
+
+	run := func(q querier) error {
+		rows, err := q.QueryContext(ctx, explain, args...)
+		if err != nil {
+			return fmt.Errorf("explain failed: %v", err)
+		}
+		defer rows.Close()
+
+		result.Plan, err = scanPlan(rows)
+		if err != nil {
+			return fmt.Errorf("failed to read plan: %v", err)
+		}
+		return nil
+	}
+
+	if stmt.Category == CategoryRead {
+		// EXPLAIN ANALYZE 会执行语句，读语句与 read-query 一样在只读连接上执行，拒绝函数中隐藏的写操作
+		if err := withReadOnly(ctx, connectionString, stmt.SQL, run); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	if !analyze {
+		if err := withQuerier(ctx, connectionString, run); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	if sessionTxFromContext(ctx) != nil {
+		return nil, fmt.Errorf("EXPLAIN ANALYZE of a write is not supported inside a session transaction")
+	}
+	err = withConn(ctx, connectionString, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %v", err)
+		}
+		// EXPLAIN ANALYZE 会真正执行写操作，事务必须回滚
+		defer tx.Rollback()
+
+		result.RolledBack = true
+		return run(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// scanPlan reads EXPLAIN output as text and parses it.
+func scanPlan(rows *sql.Rows) (*Plan, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var lines [][]string
+	for rows.Next() && len(lines) < maxPlanRows {
+		values := make([]sql.NullString, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+
+		line := make([]string, len(columns))
+		for i, v := range values {
+			line[i] = v.String
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ParsePlan(columns, lines), nil
+}
@@ -0,0 +1,179 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Column types inferred for values of imported files.
+const (
+	ImportTypeBool        = "BOOL"
+	ImportTypeInt         = "INT8"
+	ImportTypeFloat       = "FLOAT8"
+	ImportTypeDate        = "DATE"
+	ImportTypeTimestamp   = "TIMESTAMP"
+	ImportTypeTimestampTZ = "TIMESTAMPTZ"
+	ImportTypeJSON        = "JSONB"
+	ImportTypeString      = "STRING"
+)
+
+// Layouts of the date and time strings recognized in imported files.
+var (
+	importTimestampTZLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999Z07:00", "2006-01-02 15:04:05.999999999-07"}
+	importTimestampLayouts   = []string{"2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05.999999999"}
+	importDateLayouts        = []string{"2006-01-02"}
+)
+
+// InferValueType returns the narrowest column type that holds value, or "" for NULL.
+// value is a CSV field or a value decoded from JSON with UseNumber.
+func InferValueType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case bool:
+		return ImportTypeBool
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return ImportTypeInt
+		}
+		return ImportTypeFloat
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return ImportTypeInt
+		}
+		return ImportTypeFloat
+	case int, int32, int64:
+		return ImportTypeInt
+	case map[string]interface{}, []interface{}:
+		return ImportTypeJSON
+	case string:
+		return inferStringType(v)
+	}
+	return ImportTypeString
+}
+
+// inferStringType recognizes booleans, numbers, dates and timestamps written as text.
+func inferStringType(s string) string {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.EqualFold(s, "true") || strings.EqualFold(s, "false"):
+		return ImportTypeBool
+	case isInt(s):
+		return ImportTypeInt
+	case isFloat(s):
+		return ImportTypeFloat
+	case parsesAs(s, importDateLayouts):
+		return ImportTypeDate
+	case parsesAs(s, importTimestampLayouts):
+		return ImportTypeTimestamp
+	case parsesAs(s, importTimestampTZLayouts):
+		return ImportTypeTimestampTZ
+	}
+	return ImportTypeString
+}
+
+func isInt(s string) bool {
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
+
+// isFloat accepts decimal numbers only; strconv also parses Inf and NaN, which are kept as text.
+func isFloat(s string) bool {
+	if strings.ContainsAny(s, "iInN") {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+func parsesAs(s string, layouts []string) bool {
+	for _, layout := range layouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// WidenType returns a column type that holds values of both types; "" stands for NULL.
+func WidenType(a, b string) string {
+	switch {
+	case a == "" || a == b:
+		return b
+	case b == "":
+		return a
+	}
+	pair := map[string]bool{a: true, b: true}
+	switch {
+	case pair[ImportTypeInt] && pair[ImportTypeFloat]:
+		return ImportTypeFloat
+	case pair[ImportTypeDate] && pair[ImportTypeTimestamp]:
+		return ImportTypeTimestamp
+	case pair[ImportTypeTimestampTZ] && (pair[ImportTypeDate] || pair[ImportTypeTimestamp]):
+		return ImportTypeTimestampTZ
+	}
+	return ImportTypeString
+}
+
+// ValueFitsColumn reports whether value can be stored in a column of dataType, as reported by
+// SHOW COLUMNS. NULL always fits; nullability is checked separately. Types the check does not
+// know, such as arrays and geometry, accept any value and are left to the database.
+func ValueFitsColumn(value interface{}, dataType string) bool {
+	if value == nil {
+		return true
+	}
+	inferred := InferValueType(value)
+	family := strings.ToUpper(strings.TrimSpace(dataType))
+	if i := strings.IndexByte(family, '('); i >= 0 {
+		family = strings.TrimSpace(family[:i])
+	}
+	if strings.HasSuffix(family, "[]") {
+		return true
+	}
+
+	switch family {
+	case "INT", "INT2", "INT4", "INT8", "INT64", "INTEGER", "SMALLINT", "BIGINT", "SERIAL", "SERIAL2", "SERIAL4", "SERIAL8":
+		return inferred == ImportTypeInt
+	case "FLOAT", "FLOAT4", "FLOAT8", "REAL", "DOUBLE PRECISION", "DECIMAL", "NUMERIC", "DEC":
+		return inferred == ImportTypeInt || inferred == ImportTypeFloat
+	case "BOOL", "BOOLEAN":
+		if s, ok := value.(string); ok {
+			return isBoolLiteral(s)
+		}
+		return inferred == ImportTypeBool
+	case "DATE":
+		return inferred == ImportTypeDate
+	case "TIMESTAMP", "TIMESTAMPTZ", "TIMESTAMP WITH TIME ZONE", "TIMESTAMP WITHOUT TIME ZONE":
+		return inferred == ImportTypeDate || inferred == ImportTypeTimestamp || inferred == ImportTypeTimestampTZ
+	case "JSON", "JSONB":
+		if s, ok := value.(string); ok {
+			return json.Valid([]byte(s))
+		}
+		return true
+	}
+	return true
+}
+
+// isBoolLiteral accepts the text forms KWDB reads as booleans.
+func isBoolLiteral(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "t", "f", "true", "false", "y", "n", "yes", "no", "on", "off", "1", "0":
+		return true
+	}
+	return false
+}
+
+// IsUndefinedTable reports whether err says that a table does not exist.
+func IsUndefinedTable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "42P01"
+	}
+	return err != nil && strings.Contains(err.Error(), "does not exist")
+}
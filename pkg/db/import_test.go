@@ -0,0 +1,85 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestInferValueType(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, ""},
+		{true, ImportTypeBool},
+		{"FALSE", ImportTypeBool},
+		{"42", ImportTypeInt},
+		{json.Number("42"), ImportTypeInt},
+		{json.Number("4.2"), ImportTypeFloat},
+		{"-1.5e3", ImportTypeFloat},
+		{"NaN", ImportTypeString},
+		{"2025-01-01", ImportTypeDate},
+		{"2025-01-01 08:00:00.123", ImportTypeTimestamp},
+		{"2025-01-01T08:00:00Z", ImportTypeTimestampTZ},
+		{map[string]interface{}{"a": 1}, ImportTypeJSON},
+		{"hello", ImportTypeString},
+	}
+	for _, tt := range tests {
+		if got := InferValueType(tt.value); got != tt.want {
+			t.Errorf("InferValueType(%#v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWidenType(t *testing.T) {
+	tests := [][3]string{
+		{"", ImportTypeInt, ImportTypeInt},
+		{ImportTypeInt, ImportTypeFloat, ImportTypeFloat},
+		{ImportTypeDate, ImportTypeTimestamp, ImportTypeTimestamp},
+		{ImportTypeTimestamp, ImportTypeTimestampTZ, ImportTypeTimestampTZ},
+		{ImportTypeInt, ImportTypeBool, ImportTypeString},
+	}
+	for _, tt := range tests {
+		if got := WidenType(tt[0], tt[1]); got != tt[2] {
+			t.Errorf("WidenType(%q, %q) = %q, want %q", tt[0], tt[1], got, tt[2])
+		}
+	}
+}
+
+func TestValueFitsColumn(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		dataType string
+		want     bool
+	}{
+		{nil, "INT8", true},
+		{"12", "INT4", true},
+		{"1.5", "INT8", false},
+		{"1.5", "DECIMAL(10,2)", true},
+		{"yes", "BOOL", true},
+		{"maybe", "BOOL", false},
+		{"2025-01-01", "TIMESTAMPTZ", true},
+		{"tomorrow", "TIMESTAMP", false},
+		{`{"a": 1}`, "JSONB", true},
+		{"{", "JSONB", false},
+		{"12", "VARCHAR(10)", true},
+		{"anything", "INT8[]", true},
+	}
+	for _, tt := range tests {
+		if got := ValueFitsColumn(tt.value, tt.dataType); got != tt.want {
+			t.Errorf("ValueFitsColumn(%#v, %q) = %v, want %v", tt.value, tt.dataType, got, tt.want)
+		}
+	}
+}
+
+func TestIsUndefinedTable(t *testing.T) {
+	if !IsUndefinedTable(fmt.Errorf("failed to get table columns: %w", &pq.Error{Code: "42P01"})) {
+		t.Fatal("a wrapped undefined_table error should be detected")
+	}
+	if IsUndefinedTable(&pq.Error{Code: "42501"}) || IsUndefinedTable(nil) {
+		t.Fatal("other errors are not undefined_table")
+	}
+}
@@ -0,0 +1,253 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TokenKind identifies the lexical class of a Token.
+type TokenKind int
+
+const (
+	// TokenWord is a keyword or an unquoted identifier.
+	TokenWord TokenKind = iota
+	// TokenQuotedIdent is a double-quoted identifier.
+	TokenQuotedIdent
+	// TokenString is a string literal: '...', E'...', B'...', X'...' or $tag$...$tag$.
+	TokenString
+	// TokenNumber is a numeric literal.
+	TokenNumber
+	// TokenPlaceholder is a $n parameter placeholder.
+	TokenPlaceholder
+	// TokenOperator is any other punctuation or operator character.
+	TokenOperator
+	// TokenSemicolon separates statements.
+	TokenSemicolon
+	// TokenComment is a -- line comment or a /* block */ comment.
+	TokenComment
+)
+
+// Token is one lexical element of a SQL text. Start and End are byte offsets into the source.
+type Token struct {
+	Kind  TokenKind
+	Text  string
+	Start int
+	End   int
+}
+
+// Keyword returns the upper-cased text of a word token, or "" for any other token.
+func (t Token) Keyword() string {
+	if t.Kind != TokenWord {
+		return ""
+	}
+	return strings.ToUpper(t.Text)
+}
+
+// Is reports whether t is a word token matching one of keywords (case-insensitive).
+func (t Token) Is(keywords ...string) bool {
+	kw := t.Keyword()
+	if kw == "" {
+		return false
+	}
+	for _, k := range keywords {
+		if kw == k {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOperator reports whether t is the operator token op.
+func (t Token) IsOperator(op string) bool {
+	return t.Kind == TokenOperator && t.Text == op
+}
+
+// Tokenize splits a KWDB SQL text into tokens, keeping comments.
+// It understands -- and nested /* */ comments, '...' and E'...' string literals, dollar-quoted
+// strings, double-quoted identifiers and $n placeholders. On an unterminated literal or
+// comment it returns the tokens read so far together with an error.
+func Tokenize(sql string) ([]Token, error) {
+	var tokens []Token
+	emit := func(kind TokenKind, start, end int) {
+		tokens = append(tokens, Token{Kind: kind, Text: sql[start:end], Start: start, End: end})
+	}
+
+	i := 0
+	for i < len(sql) {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			emit(TokenComment, i, i+end)
+			i += end
+
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end, ok := scanBlockComment(sql, i)
+			if !ok {
+				return tokens, fmt.Errorf("unterminated block comment at position %d", i)
+			}
+			emit(TokenComment, i, end)
+			i = end
+
+		case c == '\'':
+			end, ok := scanQuoted(sql, i, '\'', false)
+			if !ok {
+				return tokens, fmt.Errorf("unterminated string literal at position %d", i)
+			}
+			emit(TokenString, i, end)
+			i = end
+
+		case c == '"':
+			end, ok := scanQuoted(sql, i, '"', false)
+			if !ok {
+				return tokens, fmt.Errorf("unterminated quoted identifier at position %d", i)
+			}
+			emit(TokenQuotedIdent, i, end)
+			i = end
+
+		case c == '$':
+			if i+1 < len(sql) && isDigit(sql[i+1]) {
+				end := i + 1
+				for end < len(sql) && isDigit(sql[end]) {
+					end++
+				}
+				emit(TokenPlaceholder, i, end)
+				i = end
+				continue
+			}
+			if tag, ok := dollarTag(sql, i); ok {
+				closing := strings.Index(sql[i+len(tag):], tag)
+				if closing < 0 {
+					return tokens, fmt.Errorf("unterminated dollar-quoted string at position %d", i)
+				}
+				end := i + len(tag) + closing + len(tag)
+				emit(TokenString, i, end)
+				i = end
+				continue
+			}
+			emit(TokenOperator, i, i+1)
+			i++
+
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			end := i
+			for end < len(sql) {
+				ch := sql[end]
+				if isWordChar(ch) || ch == '.' {
+					end++
+					continue
+				}
+				// Signed exponent, as in 1e-5.
+				if (ch == '+' || ch == '-') && (sql[end-1] == 'e' || sql[end-1] == 'E') {
+					end++
+					continue
+				}
+				break
+			}
+			emit(TokenNumber, i, end)
+			i = end
+
+		case isWordStart(c):
+			// Prefixed string literals: E'...' and B'...' accept backslash escapes, X'...' does not.
+			if i+1 < len(sql) && sql[i+1] == '\'' && strings.ContainsRune("eEbBxX", rune(c)) {
+				backslash := c != 'x' && c != 'X'
+				end, ok := scanQuoted(sql, i+1, '\'', backslash)
+				if !ok {
+					return tokens, fmt.Errorf("unterminated string literal at position %d", i)
+				}
+				emit(TokenString, i, end)
+				i = end
+				continue
+			}
+			end := i + 1
+			for end < len(sql) && (isWordChar(sql[end]) || sql[end] == '$') {
+				end++
+			}
+			emit(TokenWord, i, end)
+			i = end
+
+		case c == ';':
+			emit(TokenSemicolon, i, i+1)
+			i++
+
+		default:
+			emit(TokenOperator, i, i+1)
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// scanBlockComment returns the end offset of the (possibly nested) block comment starting at start.
+func scanBlockComment(sql string, start int) (int, bool) {
+	depth := 0
+	for i := start; i+1 < len(sql); i++ {
+		switch {
+		case sql[i] == '/' && sql[i+1] == '*':
+			depth++
+			i++
+		case sql[i] == '*' && sql[i+1] == '/':
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1, true
+			}
+		}
+	}
+	return len(sql), false
+}
+
+// scanQuoted returns the end offset of the quoted text starting at start.
+// A doubled quote is an escaped quote; backslash escapes are honoured when backslash is true.
+func scanQuoted(sql string, start int, quote byte, backslash bool) (int, bool) {
+	for i := start + 1; i < len(sql); i++ {
+		switch sql[i] {
+		case '\\':
+			if backslash {
+				i++
+			}
+		case quote:
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1, true
+		}
+	}
+	return len(sql), false
+}
+
+// dollarTag returns the opening tag ($$ or $name$) of a dollar-quoted string starting at start.
+func dollarTag(sql string, start int) (string, bool) {
+	i := start + 1
+	if i < len(sql) && sql[i] == '$' {
+		return "$$", true
+	}
+	if i >= len(sql) || !isWordStart(sql[i]) {
+		return "", false
+	}
+	for i < len(sql) && isWordChar(sql[i]) {
+		i++
+	}
+	if i < len(sql) && sql[i] == '$' {
+		return sql[start : i+1], true
+	}
+	return "", false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isWordChar(c byte) bool {
+	return isWordStart(c) || isDigit(c)
+}
@@ -0,0 +1,220 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ApplyRowLimit rewrites a single SELECT, VALUES, TABLE or WITH query so that it returns at
+// most limit rows, and reports whether the SQL changed.
+//
+// The top-level statement is parsed with the SQL lexer, so subqueries, parenthesized UNION
+// branches, string literals and comments are never touched. The LIMIT clause is placed
+// before a trailing locking clause (FOR UPDATE/SHARE) and before a KWDB time-series FILL(...)
+// clause, and trailing comments and the semicolon are kept in place. An existing top-level
+// LIMIT or FETCH FIRST that is already at most limit is kept; a larger one is lowered, and a
+// non-literal one is wrapped in least(...). Other statements are returned unchanged.
+func ApplyRowLimit(sql string, limit int) (string, bool) {
+	stmt, ok := limitTarget(sql)
+	if !ok || limit <= 0 {
+		return sql, false
+	}
+
+	// Token offsets are relative to the statement; stmt.Start maps them back into sql.
+	base := stmt.Start
+	toks := SignificantTokens(stmt.Tokens)
+	clauses := topLevelClauses(toks)
+
+	replace := func(start, end int, text string) (string, bool) {
+		return sql[:base+start] + text + sql[base+end:], true
+	}
+
+	if i, ok := clauses["LIMIT"]; ok && i+1 < len(toks) {
+		count := toks[i+1]
+		if count.Is("ALL") {
+			return replace(count.Start, count.End, strconv.Itoa(limit))
+		}
+		if count.Kind == TokenNumber {
+			if n, err := strconv.ParseInt(count.Text, 10, 64); err == nil && n <= int64(limit) {
+				return sql, false
+			}
+			return replace(count.Start, count.End, strconv.Itoa(limit))
+		}
+		// A placeholder or expression: bound it without evaluating it here.
+		end := limitExpressionEnd(toks, i+1)
+		expr := stmt.SQL[toks[i+1].Start:toks[end-1].End]
+		return replace(toks[i+1].Start, toks[end-1].End, fmt.Sprintf("least(%s, %d)", expr, limit))
+	}
+
+	if i, ok := clauses["FETCH"]; ok && i+2 < len(toks) {
+		// FETCH { FIRST | NEXT } [ count ] { ROW | ROWS } ONLY
+		count := toks[i+2]
+		if count.Is("ROW", "ROWS") {
+			return sql, false
+		}
+		if count.Kind == TokenNumber {
+			if n, err := strconv.ParseInt(count.Text, 10, 64); err == nil && n <= int64(limit) {
+				return sql, false
+			}
+		}
+		return replace(count.Start, count.End, strconv.Itoa(limit))
+	}
+
+	insertAt := clauseInsertPoint(toks, clauses)
+	if insertAt == 0 {
+		return sql, false
+	}
+	pos := toks[insertAt-1].End
+	return replace(pos, pos, fmt.Sprintf(" LIMIT %d", limit))
+}
+
+// ApplyRowPage rewrites a query like ApplyRowLimit so that it returns at most rows rows after
+// skipping offset rows. The offset is pushed into the SQL as an OFFSET clause when the query
+// has no OFFSET or FETCH of its own and its LIMIT, if any, is a literal or ALL; pushed reports
+// whether it was. Otherwise the query is limited to offset+rows rows and the caller skips the
+// first offset rows itself.
+func ApplyRowPage(sql string, offset, rows int) (rewritten string, changed, pushed bool) {
+	if offset > 0 && rows > 0 {
+		if rewritten, ok := pushRowOffset(sql, offset, rows); ok {
+			return rewritten, true, true
+		}
+	}
+	rewritten, changed = ApplyRowLimit(sql, offset+rows)
+	return rewritten, changed, false
+}
+
+// pushRowOffset adds OFFSET offset to the query and bounds it to rows rows, keeping the rows
+// its own LIMIT selects.
+func pushRowOffset(sql string, offset, rows int) (string, bool) {
+	stmt, ok := limitTarget(sql)
+	if !ok {
+		return sql, false
+	}
+	base := stmt.Start
+	toks := SignificantTokens(stmt.Tokens)
+	clauses := topLevelClauses(toks)
+	if _, ok := clauses["OFFSET"]; ok {
+		return sql, false
+	}
+	if _, ok := clauses["FETCH"]; ok {
+		return sql, false
+	}
+
+	if i, ok := clauses["LIMIT"]; ok {
+		if i+1 >= len(toks) || limitExpressionEnd(toks, i+1) != i+2 {
+			return sql, false
+		}
+		count := toks[i+1]
+		limit := int64(rows)
+		switch {
+		case count.Is("ALL"):
+		case count.Kind == TokenNumber:
+			n, err := strconv.ParseInt(count.Text, 10, 64)
+			if err != nil {
+				return sql, false
+			}
+			// LIMIT n 只允许读取前 n 行，跳过 offset 行后剩余的行数可能少于 rows
+			if remaining := n - int64(offset); remaining < limit {
+				limit = max(remaining, 0)
+			}
+		default:
+			return sql, false
+		}
+		return sql[:base+count.Start] + fmt.Sprintf("%d OFFSET %d", limit, offset) + sql[base+count.End:], true
+	}
+
+	insertAt := clauseInsertPoint(toks, clauses)
+	if insertAt == 0 {
+		return sql, false
+	}
+	pos := base + toks[insertAt-1].End
+	return sql[:pos] + fmt.Sprintf(" LIMIT %d OFFSET %d", rows, offset) + sql[pos:], true
+}
+
+// limitTarget returns the single statement of sql if a LIMIT can be applied to it.
+func limitTarget(sql string) (Statement, bool) {
+	statements, err := SplitStatements(sql)
+	if err != nil || len(statements) != 1 {
+		return Statement{}, false
+	}
+	stmt := statements[0]
+	if stmt.Category != CategoryRead {
+		return Statement{}, false
+	}
+	switch stmt.Keyword {
+	case "SELECT", "VALUES", "TABLE", "WITH":
+		return stmt, true
+	}
+	return Statement{}, false
+}
+
+// clauseInsertPoint returns the token index a new LIMIT clause goes before: FOR UPDATE/SHARE
+// or FILL(...), otherwise the end of the statement.
+func clauseInsertPoint(toks []Token, clauses map[string]int) int {
+	insertAt := len(toks)
+	for _, clause := range []string{"FOR", "FILL"} {
+		if i, ok := clauses[clause]; ok && i < insertAt {
+			insertAt = i
+		}
+	}
+	return insertAt
+}
+
+// topLevelClauses returns the index of the first LIMIT, OFFSET, FETCH, FOR (locking) and FILL
+// keywords at parenthesis depth 0.
+func topLevelClauses(toks []Token) map[string]int {
+	clauses := make(map[string]int)
+	depth := 0
+	for i, tok := range toks {
+		switch {
+		case tok.IsOperator("(") || tok.IsOperator("["):
+			depth++
+			continue
+		case tok.IsOperator(")") || tok.IsOperator("]"):
+			depth--
+			continue
+		}
+		if depth != 0 || tok.Kind != TokenWord {
+			continue
+		}
+
+		keyword := tok.Keyword()
+		switch keyword {
+		case "LIMIT", "OFFSET":
+		case "FETCH":
+			if i+1 >= len(toks) || !toks[i+1].Is("FIRST", "NEXT") {
+				continue
+			}
+		case "FOR":
+			if i+1 >= len(toks) || !toks[i+1].Is("UPDATE", "SHARE", "NO", "KEY") {
+				continue
+			}
+		case "FILL":
+			if i+1 >= len(toks) || !toks[i+1].IsOperator("(") {
+				continue
+			}
+		default:
+			continue
+		}
+		if _, seen := clauses[keyword]; !seen {
+			clauses[keyword] = i
+		}
+	}
+	return clauses
+}
+
+// limitExpressionEnd returns the index just past the LIMIT expression starting at start.
+func limitExpressionEnd(toks []Token, start int) int {
+	depth := 0
+	for i := start; i < len(toks); i++ {
+		switch {
+		case toks[i].IsOperator("("):
+			depth++
+		case toks[i].IsOperator(")"):
+			depth--
+		case depth == 0 && toks[i].Is("OFFSET", "FOR", "FILL"):
+			return i
+		}
+	}
+	return len(toks)
+}
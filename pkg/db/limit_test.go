@@ -0,0 +1,149 @@
+package db
+
+import "testing"
+
+func TestApplyRowLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "plain select without limit is auto-limited",
+			sql:  "SELECT * FROM test_table",
+			want: "SELECT * FROM test_table LIMIT 20",
+		},
+		{
+			name: "semicolon stays at the end",
+			sql:  "SELECT * FROM test_table;",
+			want: "SELECT * FROM test_table LIMIT 20;",
+		},
+		{
+			name: "trailing comments stay after the limit",
+			sql:  "SELECT * FROM test_table -- recent rows\n/* done */;",
+			want: "SELECT * FROM test_table LIMIT 20 -- recent rows\n/* done */;",
+		},
+		{
+			name: "select with smaller explicit limit is left alone",
+			sql:  "SELECT * FROM test_table LIMIT 10",
+			want: "SELECT * FROM test_table LIMIT 10",
+		},
+		{
+			name: "select with explicit limit on next line is left alone",
+			sql:  "SELECT * FROM test_table\nLIMIT\n10",
+			want: "SELECT * FROM test_table\nLIMIT\n10",
+		},
+		{
+			name: "larger explicit limit is lowered",
+			sql:  "SELECT * FROM test_table LIMIT 1000 OFFSET 5",
+			want: "SELECT * FROM test_table LIMIT 20 OFFSET 5",
+		},
+		{
+			name: "placeholder limit is bounded",
+			sql:  "SELECT * FROM test_table LIMIT $1 OFFSET $2",
+			want: "SELECT * FROM test_table LIMIT least($1, 20) OFFSET $2",
+		},
+		{
+			name: "limit all is replaced",
+			sql:  "SELECT * FROM test_table LIMIT ALL",
+			want: "SELECT * FROM test_table LIMIT 20",
+		},
+		{
+			name: "select with fetch first clause is left alone",
+			sql:  "SELECT * FROM test_table ORDER BY id FETCH FIRST 10 ROW ONLY",
+			want: "SELECT * FROM test_table ORDER BY id FETCH FIRST 10 ROW ONLY",
+		},
+		{
+			name: "larger fetch count is lowered",
+			sql:  "SELECT * FROM test_table ORDER BY id OFFSET 5 ROWS FETCH NEXT 50 ROWS ONLY",
+			want: "SELECT * FROM test_table ORDER BY id OFFSET 5 ROWS FETCH NEXT 20 ROWS ONLY",
+		},
+		{
+			name: "limit in a subquery does not count",
+			sql:  "SELECT * FROM (SELECT * FROM t LIMIT 5) AS s WHERE s.note = 'LIMIT 1'",
+			want: "SELECT * FROM (SELECT * FROM t LIMIT 5) AS s WHERE s.note = 'LIMIT 1' LIMIT 20",
+		},
+		{
+			name: "union with parenthesized branches is limited as a whole",
+			sql:  "(SELECT a FROM t LIMIT 5) UNION ALL (SELECT a FROM u)",
+			want: "(SELECT a FROM t LIMIT 5) UNION ALL (SELECT a FROM u) LIMIT 20",
+		},
+		{
+			name: "limit goes before locking clause",
+			sql:  "SELECT * FROM t WHERE id = 1 FOR UPDATE",
+			want: "SELECT * FROM t WHERE id = 1 LIMIT 20 FOR UPDATE",
+		},
+		{
+			name: "limit goes before fill clause",
+			sql: `SELECT k_timestamp, data_int8 FROM test_fill_db.metric_numeric
+WHERE device_id = 'device1' AND k_timestamp = '2025-12-19 10:00:03'
+FILL(EXACT);`,
+			want: `SELECT k_timestamp, data_int8 FROM test_fill_db.metric_numeric
+WHERE device_id = 'device1' AND k_timestamp = '2025-12-19 10:00:03' LIMIT 20
+FILL(EXACT);`,
+		},
+		{
+			name: "time bucket group by",
+			sql:  "SELECT time_bucket(k_timestamp, '10s') AS tb, avg(v) FROM m GROUP BY tb ORDER BY tb",
+			want: "SELECT time_bucket(k_timestamp, '10s') AS tb, avg(v) FROM m GROUP BY tb ORDER BY tb LIMIT 20",
+		},
+		{
+			name: "with query",
+			sql:  "WITH x AS (SELECT * FROM t LIMIT 100) SELECT * FROM x",
+			want: "WITH x AS (SELECT * FROM t LIMIT 100) SELECT * FROM x LIMIT 20",
+		},
+		{
+			name: "show is left alone",
+			sql:  "SHOW TABLES",
+			want: "SHOW TABLES",
+		},
+		{
+			name: "explain is left alone",
+			sql:  "EXPLAIN SELECT * FROM t",
+			want: "EXPLAIN SELECT * FROM t",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := ApplyRowLimit(tt.sql, 20)
+			if got != tt.want {
+				t.Fatalf("ApplyRowLimit(%q, 20) = %q, want %q", tt.sql, got, tt.want)
+			}
+			if changed != (tt.sql != tt.want) {
+				t.Fatalf("ApplyRowLimit(%q, 20) reported changed = %v", tt.sql, changed)
+			}
+		})
+	}
+}
+
+func TestApplyRowPage(t *testing.T) {
+	tests := []struct {
+		sql    string
+		offset int
+		want   string
+		pushed bool
+	}{
+		{"SELECT * FROM t", 0, "SELECT * FROM t LIMIT 21", false},
+		{"SELECT * FROM t ORDER BY id;", 40, "SELECT * FROM t ORDER BY id LIMIT 21 OFFSET 40;", true},
+		{"SELECT * FROM t FOR UPDATE", 40, "SELECT * FROM t LIMIT 21 OFFSET 40 FOR UPDATE", true},
+		{"SELECT * FROM t LIMIT ALL", 40, "SELECT * FROM t LIMIT 21 OFFSET 40", true},
+		// The statement's own LIMIT still bounds the rows after the offset.
+		{"SELECT * FROM t LIMIT 50", 40, "SELECT * FROM t LIMIT 10 OFFSET 40", true},
+		{"SELECT * FROM t LIMIT 30", 40, "SELECT * FROM t LIMIT 0 OFFSET 40", true},
+		{"SELECT * FROM t LIMIT 1000", 40, "SELECT * FROM t LIMIT 21 OFFSET 40", true},
+		// Statements with their own OFFSET, FETCH or a non-literal LIMIT are skipped client-side.
+		{"SELECT * FROM t LIMIT 1000 OFFSET 5", 40, "SELECT * FROM t LIMIT 61 OFFSET 5", false},
+		{"SELECT * FROM t OFFSET 5 ROWS FETCH FIRST 1000 ROWS ONLY", 40, "SELECT * FROM t OFFSET 5 ROWS FETCH FIRST 61 ROWS ONLY", false},
+		{"SELECT * FROM t LIMIT $1", 40, "SELECT * FROM t LIMIT least($1, 61)", false},
+		{"SELECT * FROM (SELECT * FROM t OFFSET 5) AS s", 40, "SELECT * FROM (SELECT * FROM t OFFSET 5) AS s LIMIT 21 OFFSET 40", true},
+		{"SHOW TABLES", 40, "SHOW TABLES", false},
+	}
+
+	for _, tt := range tests {
+		got, _, pushed := ApplyRowPage(tt.sql, tt.offset, 21)
+		if got != tt.want || pushed != tt.pushed {
+			t.Errorf("ApplyRowPage(%q, %d) = %q, %v; want %q, %v", tt.sql, tt.offset, got, pushed, tt.want, tt.pushed)
+		}
+	}
+}
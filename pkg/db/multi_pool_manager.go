@@ -72,6 +72,14 @@ func (mm *MultiPoolManager) ExecuteWithURI(ctx context.Context, connectionString
 	return pool.ExecuteWithConnection(ctx, fn)
 }
 
+// ExecuteReadWithURI 执行只读操作：提供了只读连接串时使用其对应的连接池，否则使用 connectionString 的连接池。
+func (mm *MultiPoolManager) ExecuteReadWithURI(ctx context.Context, connectionString, readConnectionString string, fn func(*sql.DB) error) error {
+	if readConnectionString != "" {
+		connectionString = readConnectionString
+	}
+	return mm.ExecuteWithURI(ctx, connectionString, fn)
+}
+
 // CloseAll 关闭所有已创建的连接池。
 func (mm *MultiPoolManager) CloseAll() {
 	mm.mu.Lock()
@@ -0,0 +1,268 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// decimalPattern matches the exact decimal literals accepted for DECIMAL/NUMERIC parameters.
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?$`)
+
+// timestampLayouts lists the layouts accepted for timestamp parameters, in order of preference.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// BuildQueryArgs converts JSON tool parameters into driver arguments bound to $1..$n.
+// A parameter is either a plain JSON value or a typed hint object such as
+// {"type": "timestamptz", "value": "2025-01-01T00:00:00Z"}.
+func BuildQueryArgs(params []interface{}) ([]interface{}, error) {
+	args := make([]interface{}, 0, len(params))
+	for i, param := range params {
+		arg, err := convertParam(param)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameter $%d: %v", i+1, err)
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// ValidatePlaceholders checks that the $n placeholders referenced by query match the number of arguments.
+func ValidatePlaceholders(query string, argCount int) error {
+	placeholders := CountPlaceholders(query)
+	if placeholders != argCount {
+		return fmt.Errorf("query references %d parameter(s) but %d were provided", placeholders, argCount)
+	}
+	return nil
+}
+
+// CountPlaceholders returns the highest $n placeholder index referenced by query.
+// Placeholders inside string literals, quoted identifiers and comments are ignored.
+func CountPlaceholders(query string) int {
+	// Tokens read before a lexing error are still counted; the statement fails at execution.
+	tokens, _ := Tokenize(query)
+
+	highest := 0
+	for _, tok := range tokens {
+		if tok.Kind != TokenPlaceholder {
+			continue
+		}
+		if n, err := strconv.Atoi(tok.Text[1:]); err == nil && n > highest {
+			highest = n
+		}
+	}
+	return highest
+}
+
+// convertParam converts a single JSON value or typed hint into a driver argument.
+func convertParam(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string, bool:
+		return v, nil
+	case float64:
+		return normalizeNumber(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.String(), nil
+	case []interface{}:
+		return convertArrayParam(v, "")
+	case map[string]interface{}:
+		hint, ok := v["type"].(string)
+		if !ok {
+			// Objects without a type hint are bound as JSON documents.
+			return marshalJSONParam(v)
+		}
+		return convertTypedParam(strings.ToLower(strings.TrimSpace(hint)), v)
+	default:
+		return nil, fmt.Errorf("unsupported parameter type %T", value)
+	}
+}
+
+// convertTypedParam applies an explicit type hint such as timestamp, decimal, bytes or array.
+func convertTypedParam(hint string, param map[string]interface{}) (interface{}, error) {
+	value, ok := param["value"]
+	if !ok {
+		return nil, fmt.Errorf("typed parameter %q is missing value", hint)
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	switch hint {
+	case "string", "text", "varchar", "char", "nchar", "nvarchar":
+		return fmt.Sprint(value), nil
+	case "int", "integer", "int2", "int4", "int8", "smallint", "bigint":
+		return parseIntParam(value)
+	case "float", "float4", "float8", "double", "real":
+		return parseFloatParam(value)
+	case "bool", "boolean":
+		return parseBoolParam(value)
+	case "timestamp", "timestamptz", "date":
+		return parseTimestampParam(value)
+	case "decimal", "numeric":
+		return parseDecimalParam(value)
+	case "bytes", "bytea", "varbytes":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("bytes value must be a base64 string")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("bytes value is not valid base64: %v", err)
+		}
+		return decoded, nil
+	case "json", "jsonb":
+		return marshalJSONParam(value)
+	case "array":
+		elements, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("array value must be a JSON array")
+		}
+		elementType, _ := param["element_type"].(string)
+		return convertArrayParam(elements, strings.ToLower(strings.TrimSpace(elementType)))
+	default:
+		return nil, fmt.Errorf("unsupported type hint %q", hint)
+	}
+}
+
+// convertArrayParam converts a JSON array into a PostgreSQL array literal.
+func convertArrayParam(elements []interface{}, elementType string) (interface{}, error) {
+	converted := make([]interface{}, 0, len(elements))
+	for i, element := range elements {
+		var (
+			arg interface{}
+			err error
+		)
+		if elementType != "" && element != nil {
+			arg, err = convertTypedParam(elementType, map[string]interface{}{"value": element})
+		} else {
+			arg, err = convertParam(element)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("array element %d: %v", i, err)
+		}
+		if t, ok := arg.(time.Time); ok {
+			arg = t.Format(time.RFC3339Nano)
+		}
+		converted = append(converted, arg)
+	}
+	return pq.GenericArray{A: converted}, nil
+}
+
+func normalizeNumber(v float64) interface{} {
+	if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+		return int64(v)
+	}
+	return v
+}
+
+func parseIntParam(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case float64:
+		if v != math.Trunc(v) {
+			return nil, fmt.Errorf("%v is not an integer", v)
+		}
+		if math.Abs(v) >= 1<<63 {
+			return nil, fmt.Errorf("%v is out of range for int; pass it as a string", v)
+		}
+		return int64(v), nil
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", v)
+		}
+		return i, nil
+	default:
+		return nil, fmt.Errorf("%v is not an integer", value)
+	}
+}
+
+func parseFloatParam(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", v)
+		}
+		return f, nil
+	default:
+		return nil, fmt.Errorf("%v is not a number", value)
+	}
+}
+
+func parseBoolParam(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", v)
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("%v is not a boolean", value)
+	}
+}
+
+// parseTimestampParam accepts RFC3339 strings, "YYYY-MM-DD hh:mm:ss" strings and Unix milliseconds.
+func parseTimestampParam(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case float64:
+		return time.UnixMilli(int64(v)).UTC(), nil
+	case string:
+		s := strings.TrimSpace(v)
+		for _, layout := range timestampLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a valid timestamp", v)
+	default:
+		return nil, fmt.Errorf("%v is not a valid timestamp", value)
+	}
+}
+
+// parseDecimalParam keeps decimals as strings so no precision is lost through float64.
+func parseDecimalParam(value interface{}) (interface{}, error) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = strings.TrimSpace(v)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return nil, fmt.Errorf("%v is not a decimal", value)
+	}
+	if !decimalPattern.MatchString(s) {
+		return nil, fmt.Errorf("%q is not a decimal", s)
+	}
+	return s, nil
+}
+
+func marshalJSONParam(value interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON value: %v", err)
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestCountPlaceholders(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{name: "no placeholders", query: "SELECT 1", want: 0},
+		{name: "sequential placeholders", query: "SELECT * FROM t WHERE a = $1 AND b = $2", want: 2},
+		{name: "reused placeholder", query: "SELECT * FROM t WHERE a = $1 OR b = $1", want: 1},
+		{name: "gap counts highest index", query: "SELECT $3", want: 3},
+		{name: "placeholder in string literal is ignored", query: "SELECT '$1' , $2", want: 2},
+		{name: "placeholder in escaped string literal is ignored", query: "SELECT 'it''s $4', $1", want: 1},
+		{name: "placeholder in quoted identifier is ignored", query: `SELECT "$5" FROM t WHERE a = $1`, want: 1},
+		{name: "placeholder in line comment is ignored", query: "SELECT $1 -- $9\n", want: 1},
+		{name: "placeholder in block comment is ignored", query: "SELECT /* $9 */ $2", want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CountPlaceholders(tt.query); got != tt.want {
+				t.Fatalf("CountPlaceholders(%q) = %d, want %d", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePlaceholders(t *testing.T) {
+	if err := ValidatePlaceholders("SELECT $1, $2", 2); err != nil {
+		t.Fatalf("matching parameter count should pass: %v", err)
+	}
+	if err := ValidatePlaceholders("SELECT $1, $2", 1); err == nil {
+		t.Fatal("too few parameters should fail")
+	}
+	if err := ValidatePlaceholders("SELECT 1", 1); err == nil {
+		t.Fatal("parameters without placeholders should fail")
+	}
+}
+
+func TestBuildQueryArgs(t *testing.T) {
+	args, err := BuildQueryArgs([]interface{}{
+		"text",
+		float64(42),
+		1.5,
+		true,
+		nil,
+		map[string]interface{}{"type": "timestamptz", "value": "2025-01-02T03:04:05.123456Z"},
+		map[string]interface{}{"type": "decimal", "value": "12345678901234567890.123"},
+		map[string]interface{}{"type": "bytes", "value": "AQID"},
+		map[string]interface{}{"type": "array", "element_type": "int", "value": []interface{}{float64(1), "2"}},
+		map[string]interface{}{"a": float64(1)},
+	})
+	if err != nil {
+		t.Fatalf("BuildQueryArgs returned error: %v", err)
+	}
+
+	if args[0] != "text" || args[1] != int64(42) || args[2] != 1.5 || args[3] != true || args[4] != nil {
+		t.Fatalf("unexpected scalar conversion: %#v", args[:5])
+	}
+	ts, ok := args[5].(time.Time)
+	if !ok || ts.Nanosecond() != 123456000 {
+		t.Fatalf("unexpected timestamp conversion: %#v", args[5])
+	}
+	if args[6] != "12345678901234567890.123" {
+		t.Fatalf("decimal should stay an exact string, got %#v", args[6])
+	}
+	if b, ok := args[7].([]byte); !ok || len(b) != 3 || b[2] != 3 {
+		t.Fatalf("unexpected bytes conversion: %#v", args[7])
+	}
+	array, ok := args[8].(pq.GenericArray)
+	if !ok {
+		t.Fatalf("array should convert to pq.GenericArray, got %T", args[8])
+	}
+	if value, err := array.Value(); err != nil || value != "{1,2}" {
+		t.Fatalf("unexpected array literal %v (err %v)", value, err)
+	}
+	if args[9] != `{"a":1}` {
+		t.Fatalf("untyped object should bind as JSON, got %#v", args[9])
+	}
+}
+
+func TestBuildQueryArgsInvalid(t *testing.T) {
+	invalid := []interface{}{
+		map[string]interface{}{"type": "decimal", "value": "1.2.3"},
+		map[string]interface{}{"type": "timestamp", "value": "yesterday"},
+		map[string]interface{}{"type": "bytes", "value": "not base64!"},
+		map[string]interface{}{"type": "int", "value": 1.5},
+		map[string]interface{}{"type": "int", "value": 1e19},
+		map[string]interface{}{"type": "int", "value": -1e19},
+		map[string]interface{}{"type": "int", "value": float64(1 << 63)},
+		map[string]interface{}{"type": "unknown", "value": 1},
+		map[string]interface{}{"type": "int"},
+	}
+	for _, param := range invalid {
+		if _, err := BuildQueryArgs([]interface{}{param}); err == nil {
+			t.Fatalf("BuildQueryArgs(%v) should fail", param)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Plan issue kinds reported by ParsePlan.
+const (
+	// IssueFullScan marks an operator that reads a whole table or index.
+	IssueFullScan = "full_scan"
+	// IssueMissingIndex marks a full scan whose rows are filtered afterwards, so an index
+	// on the filtered columns could avoid reading the whole table.
+	IssueMissingIndex = "missing_index"
+)
+
+// PlanNode is one operator of a query plan.
+type PlanNode struct {
+	Operator string `json:"operator"`
+	// EstimatedRows is the optimizer's row estimate, when the plan reports one.
+	EstimatedRows *float64 `json:"estimated_rows,omitempty"`
+	// ActualRows is the row count measured by EXPLAIN ANALYZE.
+	ActualRows *int64 `json:"actual_rows,omitempty"`
+	Table      string `json:"table,omitempty"`
+	Index      string `json:"index,omitempty"`
+	Spans      string `json:"spans,omitempty"`
+	// Nodes lists the cluster nodes the operator ran on (EXPLAIN ANALYZE).
+	Nodes    string `json:"nodes,omitempty"`
+	FullScan bool   `json:"full_scan"`
+	// TimeSeries is true for KWDB time-series operators such as ts scan.
+	TimeSeries bool `json:"time_series"`
+	// Attributes holds every field printed for the operator, including the ones above.
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Children   []*PlanNode       `json:"children,omitempty"`
+}
+
+// PlanIssue is a finding about a plan that an agent may want to act on.
+type PlanIssue struct {
+	Kind     string `json:"kind"`
+	Operator string `json:"operator"`
+	Table    string `json:"table,omitempty"`
+	Index    string `json:"index,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Plan is the operator tree parsed from EXPLAIN or EXPLAIN ANALYZE output.
+type Plan struct {
+	// Properties holds plan-wide fields such as vectorized, planning time or execution time.
+	Properties map[string]string `json:"properties"`
+	// Distribution is "full" or "local" when the plan reports it.
+	Distribution string      `json:"distribution,omitempty"`
+	Root         *PlanNode   `json:"root"`
+	Issues       []PlanIssue `json:"issues"`
+	// Text is the raw output, one line per row, for plans the parser does not fully understand.
+	Text []string `json:"text"`
+}
+
+// HasIssue reports whether the plan has an issue of the given kind.
+func (p *Plan) HasIssue(kind string) bool {
+	for _, issue := range p.Issues {
+		if issue.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePlan builds a Plan from the rows of an EXPLAIN statement.
+// Both output layouts of KWDB are understood: the tabular (tree, field, description) form,
+// and the single-column text form where operators are lines starting with "•".
+// Unknown layouts yield a plan with only Text filled in.
+func ParsePlan(columns []string, rows [][]string) *Plan {
+	plan := &Plan{
+		Properties: map[string]string{},
+		Issues:     []PlanIssue{},
+		Text:       []string{},
+	}
+	b := &planBuilder{plan: plan}
+
+	tree, field, desc := columnIndex(columns, "tree"), columnIndex(columns, "field"), columnIndex(columns, "description")
+	switch {
+	case tree >= 0 && field >= 0 && desc >= 0:
+		for _, row := range rows {
+			plan.Text = append(plan.Text, strings.TrimRight(strings.Join(row, " | "), " |"))
+			if name := strings.TrimSpace(strings.TrimLeft(row[tree], " │├└─")); name != "" {
+				indent := utf8.RuneCountInString(row[tree]) - utf8.RuneCountInString(strings.TrimLeft(row[tree], " │├└─"))
+				b.open(indent, name)
+			}
+			if key := strings.TrimSpace(row[field]); key != "" {
+				b.attr(key, strings.TrimSpace(row[desc]))
+			}
+		}
+	case len(columns) == 1:
+		for _, row := range rows {
+			for _, line := range strings.Split(row[0], "\n") {
+				plan.Text = append(plan.Text, line)
+				b.line(line)
+			}
+		}
+	default:
+		for _, row := range rows {
+			plan.Text = append(plan.Text, strings.Join(row, " | "))
+		}
+	}
+
+	b.finish()
+	return plan
+}
+
+type planFrame struct {
+	indent int
+	node   *PlanNode
+}
+
+// planBuilder assembles operators into a tree by their indentation.
+type planBuilder struct {
+	plan    *Plan
+	stack   []planFrame
+	current *PlanNode
+}
+
+// line parses one line of the text layout.
+func (b *planBuilder) line(line string) {
+	if i := strings.Index(line, "•"); i >= 0 {
+		b.open(utf8.RuneCountInString(line[:i]), strings.TrimSpace(line[i+len("•"):]))
+		return
+	}
+	content := strings.TrimSpace(strings.TrimLeft(line, " │"))
+	if key, value, ok := strings.Cut(content, ":"); ok {
+		b.attr(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+}
+
+// open starts an operator at the given indentation; deeper operators are its children.
+func (b *planBuilder) open(indent int, operator string) {
+	node := &PlanNode{
+		Operator:   operator,
+		TimeSeries: isTimeSeriesOperator(operator),
+		Attributes: map[string]string{},
+	}
+	for len(b.stack) > 0 && b.stack[len(b.stack)-1].indent >= indent {
+		b.stack = b.stack[:len(b.stack)-1]
+	}
+	switch {
+	case len(b.stack) > 0:
+		parent := b.stack[len(b.stack)-1].node
+		parent.Children = append(parent.Children, node)
+	case b.plan.Root == nil:
+		b.plan.Root = node
+	default:
+		// Subqueries and postqueries are printed as further top-level operators.
+		b.plan.Root.Children = append(b.plan.Root.Children, node)
+	}
+	b.stack = append(b.stack, planFrame{indent: indent, node: node})
+	b.current = node
+}
+
+// attr records a field of the current operator, or a plan property before the first operator.
+func (b *planBuilder) attr(key, value string) {
+	key = strings.ToLower(key)
+	if b.current == nil {
+		b.plan.Properties[key] = value
+		return
+	}
+
+	node := b.current
+	if prev, ok := node.Attributes[key]; ok {
+		value = prev + ", " + value
+	}
+	node.Attributes[key] = value
+
+	switch key {
+	case "estimated row count":
+		if n, err := strconv.ParseFloat(leadingNumber(value), 64); err == nil {
+			node.EstimatedRows = &n
+		}
+	case "actual row count":
+		if n, err := strconv.ParseInt(leadingNumber(value), 10, 64); err == nil {
+			node.ActualRows = &n
+		}
+	case "table":
+		node.Table, node.Index, _ = strings.Cut(value, "@")
+	case "spans":
+		node.Spans = value
+		node.FullScan = strings.HasPrefix(strings.ToUpper(value), "FULL SCAN") || value == "ALL"
+	case "nodes":
+		node.Nodes = value
+	}
+}
+
+// finish derives the distribution and the plan issues.
+func (b *planBuilder) finish() {
+	plan := b.plan
+	if d, ok := plan.Properties["distribution"]; ok {
+		plan.Distribution = d
+	} else if d, ok := plan.Properties["distributed"]; ok {
+		// Older releases print distributed: true|false.
+		if d == "true" {
+			plan.Distribution = "full"
+		} else {
+			plan.Distribution = "local"
+		}
+	}
+	if plan.Root != nil {
+		collectIssues(plan, plan.Root, nil)
+	}
+}
+
+// collectIssues flags full scans, and full scans that are filtered afterwards.
+func collectIssues(plan *Plan, node, parent *PlanNode) {
+	if node.FullScan {
+		target := node.Table
+		if node.Index != "" {
+			target += "@" + node.Index
+		}
+		plan.Issues = append(plan.Issues, PlanIssue{
+			Kind:     IssueFullScan,
+			Operator: node.Operator,
+			Table:    node.Table,
+			Index:    node.Index,
+			Message:  fmt.Sprintf("%s reads all of %s", node.Operator, target),
+		})
+
+		filter := node.Attributes["filter"]
+		if filter == "" && parent != nil && parent.Operator == "filter" {
+			filter = parent.Attributes["filter"]
+		}
+		if filter != "" {
+			plan.Issues = append(plan.Issues, PlanIssue{
+				Kind:     IssueMissingIndex,
+				Operator: node.Operator,
+				Table:    node.Table,
+				Index:    node.Index,
+				Message:  fmt.Sprintf("rows of %s are filtered by %s after a full scan; an index on the filtered columns could avoid it", node.Table, filter),
+			})
+		}
+	}
+	for _, child := range node.Children {
+		collectIssues(plan, child, node)
+	}
+}
+
+// isTimeSeriesOperator reports whether operator is a KWDB time-series operator.
+func isTimeSeriesOperator(operator string) bool {
+	op := strings.ToLower(operator)
+	return strings.HasPrefix(op, "ts ") || strings.HasPrefix(op, "ts-") || strings.HasPrefix(op, "ts_") ||
+		strings.HasPrefix(op, "synchronizer")
+}
+
+// leadingNumber returns the number at the start of value without thousands separators,
+// e.g. "1000" for "1,000 (100% of the table)".
+func leadingNumber(value string) string {
+	end := strings.IndexAny(value, " (")
+	if end < 0 {
+		end = len(value)
+	}
+	return strings.ReplaceAll(value[:end], ",", "")
+}
+
+// columnIndex returns the position of the named column, or -1.
+func columnIndex(columns []string, name string) int {
+	for i, c := range columns {
+		if strings.EqualFold(c, name) {
+			return i
+		}
+	}
+	return -1
+}
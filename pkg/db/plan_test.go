@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParsePlanText(t *testing.T) {
+	output := []string{
+		"planning time: 312µs",
+		"execution time: 2ms",
+		"distribution: full",
+		"vectorized: true",
+		"",
+		"• filter",
+		"│ nodes: n1",
+		"│ actual row count: 2",
+		"│ estimated row count: 3",
+		"│ filter: status = 'open'",
+		"│",
+		"└── • scan",
+		"      nodes: n1, n2",
+		"      actual row count: 1,500",
+		"      estimated row count: 1,000 (100% of the table; stats collected 2 minutes ago)",
+		"      table: orders@primary",
+		"      spans: FULL SCAN",
+	}
+	rows := make([][]string, len(output))
+	for i, line := range output {
+		rows[i] = []string{line}
+	}
+
+	plan := ParsePlan([]string{"info"}, rows)
+	if plan.Distribution != "full" || plan.Properties["execution time"] != "2ms" {
+		t.Fatalf("unexpected plan properties: %+v, distribution %q", plan.Properties, plan.Distribution)
+	}
+	if plan.Root == nil || plan.Root.Operator != "filter" || len(plan.Root.Children) != 1 {
+		t.Fatalf("unexpected root: %+v", plan.Root)
+	}
+	if *plan.Root.EstimatedRows != 3 || *plan.Root.ActualRows != 2 {
+		t.Fatalf("root rows: estimated %v, actual %v", *plan.Root.EstimatedRows, *plan.Root.ActualRows)
+	}
+
+	scan := plan.Root.Children[0]
+	if scan.Operator != "scan" || scan.Table != "orders" || scan.Index != "primary" || !scan.FullScan || scan.Nodes != "n1, n2" {
+		t.Fatalf("unexpected scan: %+v", scan)
+	}
+	if *scan.EstimatedRows != 1000 || *scan.ActualRows != 1500 {
+		t.Fatalf("scan rows: estimated %v, actual %v", *scan.EstimatedRows, *scan.ActualRows)
+	}
+	if !plan.HasIssue(IssueFullScan) || !plan.HasIssue(IssueMissingIndex) {
+		t.Fatalf("expected full_scan and missing_index issues, got %+v", plan.Issues)
+	}
+	if len(plan.Text) != len(output) {
+		t.Fatalf("raw text has %d lines, want %d", len(plan.Text), len(output))
+	}
+}
+
+func TestParsePlanTabular(t *testing.T) {
+	rows := [][]string{
+		{"", "distributed", "true"},
+		{"", "vectorized", "false"},
+		{"render", "", ""},
+		{" └── lookup join", "", ""},
+		{"      │", "table", "orders@orders_user_idx"},
+		{"      ├── scan", "", ""},
+		{"      │", "table", "users@primary"},
+		{"      │", "spans", "/1-/2"},
+		{"      └── synchronizer", "", ""},
+		{"           └── ts scan", "", ""},
+		{"", "ts-table", "sensors"},
+	}
+
+	plan := ParsePlan([]string{"tree", "field", "description"}, rows)
+	if plan.Distribution != "full" {
+		t.Fatalf("distribution = %q, want full", plan.Distribution)
+	}
+	join := plan.Root.Children[0]
+	if join.Operator != "lookup join" || join.Index != "orders_user_idx" || len(join.Children) != 2 {
+		t.Fatalf("unexpected join: %+v", join)
+	}
+	if scan := join.Children[0]; scan.FullScan || scan.Spans != "/1-/2" {
+		t.Fatalf("unexpected scan: %+v", scan)
+	}
+	sync := join.Children[1]
+	if !sync.TimeSeries || len(sync.Children) != 1 || !sync.Children[0].TimeSeries {
+		t.Fatalf("time-series operators not marked: %+v", sync)
+	}
+	if sync.Children[0].Attributes["ts-table"] != "sensors" {
+		t.Fatalf("ts scan attributes = %+v", sync.Children[0].Attributes)
+	}
+	if len(plan.Issues) != 0 {
+		t.Fatalf("unexpected issues: %+v", plan.Issues)
+	}
+}
+
+func TestParsePlanUnknownLayout(t *testing.T) {
+	plan := ParsePlan([]string{"automatic", "url"}, [][]string{{"true", "https://example"}})
+	if plan.Root != nil || len(plan.Text) != 1 {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+}
+
+func TestExplainQueryValidation(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"EXPLAIN SELECT 1", "without the EXPLAIN keyword"},
+		{"DROP TABLE t", "supports read and DML statements"},
+		{"SELECT 1; SELECT 2", "accepts a single statement"},
+	}
+	for _, tt := range tests {
+		_, err := ExplainQuery(context.Background(), "", tt.sql, false)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("ExplainQuery(%q) error = %v, want %q", tt.sql, err, tt.want)
+		}
+	}
+}
+
+func TestExplainReadUsesReadOnlyPath(t *testing.T) {
+	// Only the read-only path checks function calls inside a read-write session transaction,
+	// and it does so before touching the transaction, so no connection is needed.
+	ctx := WithSessionTx(context.Background(), &SessionTx{SessionID: "s1"})
+	for _, analyze := range []bool{false, true} {
+		_, err := ExplainQuery(ctx, "", "SELECT purge_old_rows(30)", analyze)
+		if err == nil || !strings.Contains(err.Error(), "purge_old_rows") {
+			t.Fatalf("analyze=%v: a read explain should run on the read-only path, got %v", analyze, err)
+		}
+	}
+}
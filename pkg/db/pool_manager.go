@@ -18,6 +18,10 @@ type PoolManager struct {
 	connectionString string
 	initialized      bool
 	config           PoolConfig
+
+	// readDB is an optional pool for read-only queries, e.g. a read-only role or a follower endpoint.
+	readDB               *sql.DB
+	readConnectionString string
 }
 
 // PoolConfig represents connection pool configuration
@@ -69,17 +73,11 @@ func (pm *PoolManager) InitializePool(connectionString string, config ...PoolCon
 
 	// Create database connection pool with lazy loading
 	var err error
-	pm.db, err = sql.Open("postgres", pm.connectionString)
+	pm.db, err = pm.openPool(pm.connectionString)
 	if err != nil {
 		return fmt.Errorf("failed to create database pool: %v", err)
 	}
 
-	// Configure connection pool parameters
-	pm.db.SetMaxOpenConns(pm.config.MaxOpenConns)
-	pm.db.SetMaxIdleConns(pm.config.MaxIdleConns)
-	pm.db.SetConnMaxLifetime(pm.config.ConnMaxLifetime)
-	pm.db.SetConnMaxIdleTime(pm.config.ConnMaxIdleTime)
-
 	pm.initialized = true
 	log.Printf("Database connection pool initialized (max_open: %d, max_idle: %d)",
 		pm.config.MaxOpenConns, pm.config.MaxIdleConns)
@@ -87,6 +85,47 @@ func (pm *PoolManager) InitializePool(connectionString string, config ...PoolCon
 	return nil
 }
 
+// InitializeReadPool configures a separate pool used by read-only queries.
+// It must be called after InitializePool.
+func (pm *PoolManager) InitializeReadPool(readConnectionString string) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	if readConnectionString == "" {
+		return fmt.Errorf("read connection string cannot be empty")
+	}
+	if !pm.initialized {
+		return fmt.Errorf("database pool not initialized")
+	}
+
+	readDB, err := pm.openPool(readConnectionString)
+	if err != nil {
+		return fmt.Errorf("failed to create read database pool: %v", err)
+	}
+	if pm.readDB != nil {
+		pm.readDB.Close()
+	}
+	pm.readDB = readDB
+	pm.readConnectionString = readConnectionString
+	log.Println("Read-only database connection pool initialized")
+	return nil
+}
+
+// openPool creates a lazily connecting pool configured with pm.config.
+func (pm *PoolManager) openPool(connectionString string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Configure connection pool parameters
+	db.SetMaxOpenConns(pm.config.MaxOpenConns)
+	db.SetMaxIdleConns(pm.config.MaxIdleConns)
+	db.SetConnMaxLifetime(pm.config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pm.config.ConnMaxIdleTime)
+	return db, nil
+}
+
 // GetConnection retrieves database connection from the pool
 func (pm *PoolManager) GetConnection(ctx context.Context) (*sql.DB, error) {
 	pm.mu.RLock()
@@ -130,17 +169,11 @@ func (pm *PoolManager) reinitializePool() error {
 
 	// Recreate connection pool
 	var err error
-	pm.db, err = sql.Open("postgres", pm.connectionString)
+	pm.db, err = pm.openPool(pm.connectionString)
 	if err != nil {
 		return fmt.Errorf("failed to recreate database pool: %v", err)
 	}
 
-	// Reconfigure connection pool parameters
-	pm.db.SetMaxOpenConns(pm.config.MaxOpenConns)
-	pm.db.SetMaxIdleConns(pm.config.MaxIdleConns)
-	pm.db.SetConnMaxLifetime(pm.config.ConnMaxLifetime)
-	pm.db.SetConnMaxIdleTime(pm.config.ConnMaxIdleTime)
-
 	log.Println("Database connection pool reinitialized")
 	return nil
 }
@@ -155,6 +188,24 @@ func (pm *PoolManager) ExecuteWithConnection(ctx context.Context, fn func(*sql.D
 	return fn(db)
 }
 
+// ExecuteWithReadConnection executes read-only operations on the read pool, or on the main
+// pool when no read pool is configured.
+func (pm *PoolManager) ExecuteWithReadConnection(ctx context.Context, fn func(*sql.DB) error) error {
+	pm.mu.RLock()
+	readDB := pm.readDB
+	pm.mu.RUnlock()
+	if readDB == nil {
+		return pm.ExecuteWithConnection(ctx, fn)
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, pm.config.ConnTimeout)
+	defer cancel()
+	if err := readDB.PingContext(pingCtx); err != nil {
+		return fmt.Errorf("failed to get read database connection: %v", err)
+	}
+	return fn(readDB)
+}
+
 // GetStats returns connection pool statistics
 func (pm *PoolManager) GetStats() sql.DBStats {
 	pm.mu.RLock()
@@ -172,6 +223,12 @@ func (pm *PoolManager) Close() error {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
+	if pm.readDB != nil {
+		pm.readDB.Close()
+		pm.readDB = nil
+		pm.readConnectionString = ""
+	}
+
 	if pm.db != nil {
 		err := pm.db.Close()
 		pm.db = nil
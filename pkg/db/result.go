@@ -0,0 +1,290 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Column describes one column of a result set, in the order produced by the statement.
+type Column struct {
+	Name string `json:"name"`
+	// Type is the database type name reported by the driver (e.g. INT8, TIMESTAMPTZ).
+	Type string `json:"type"`
+	// Nullable reports whether the column may hold NULL; it is null when the driver cannot tell.
+	Nullable *bool `json:"nullable"`
+}
+
+// ResultSet holds query results with column order and duplicate column names preserved.
+// Each row is a positional array aligned with Columns.
+type ResultSet struct {
+	Columns []Column        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+	// Truncated reports that MaxRows or MaxBytes stopped the scan before the last row,
+	// so the statement produced rows beyond the returned ones.
+	Truncated bool `json:"-"`
+	// RowsScanned counts the rows read from the server, including skipped ones.
+	RowsScanned int `json:"-"`
+	// Bytes is the serialized JSON size of the returned rows.
+	Bytes int `json:"-"`
+	// Oversize reports that the first row alone is larger than MaxBytes. It is returned anyway,
+	// so that paging can move past it.
+	Oversize bool `json:"-"`
+}
+
+// QueryOptions controls which part of a result set is returned.
+type QueryOptions struct {
+	// Offset is the number of leading rows to skip.
+	Offset int
+	// MaxRows limits the number of returned rows; 0 returns all rows.
+	MaxRows int
+	// MaxBytes limits the serialized JSON size of the returned rows; 0 disables the limit.
+	MaxBytes int
+	// CountAll keeps reading past the limits so RowsScanned counts every row of the result.
+	CountAll bool
+}
+
+// ColumnNames returns the column names in result order.
+func (rs *ResultSet) ColumnNames() []string {
+	names := make([]string, len(rs.Columns))
+	for i, col := range rs.Columns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+// withDB runs fn on the default pool, or on the pool for connectionString when it is non-empty.
+func withDB(ctx context.Context, connectionString string, fn func(*sql.DB) error) error {
+	if connectionString != "" {
+		return GetMultiPoolManager().ExecuteWithURI(ctx, connectionString, fn)
+	}
+	return GetPoolManager().ExecuteWithConnection(ctx, fn)
+}
+
+// querier is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// withQuerier runs fn on the session transaction attached to ctx, or else on the pool
+// selected by connectionString. A session transaction is bounded by the deadline of ctx only,
+// because a SET inside it would outlive the statement.
+func withQuerier(ctx context.Context, connectionString string, fn func(querier) error) error {
+	if stx := sessionTxFromContext(ctx); stx != nil {
+		return stx.run(ctx, func(tx *sql.Tx) error {
+			return fn(tx)
+		})
+	}
+	if statementTimeoutFromContext(ctx) > 0 {
+		// statement_timeout is a session setting, so it needs a dedicated connection.
+		return withConn(ctx, connectionString, func(conn *sql.Conn) error {
+			return fn(conn)
+		})
+	}
+	return withDB(ctx, connectionString, func(db *sql.DB) error {
+		return fn(db)
+	})
+}
+
+// withReadOnly runs fn on a connection from the read pool with default_transaction_read_only
+// set, so KWDB itself rejects writes that statement classification cannot see, such as writes
+// inside functions. Inside a session transaction fn runs in that transaction instead; unless
+// the transaction is read-only, query may then only call known read-only built-in functions.
+func withReadOnly(ctx context.Context, connectionString, query string, fn func(querier) error) error {
+	if stx := sessionTxFromContext(ctx); stx != nil {
+		if !stx.ReadOnly {
+			// 读写事务中 KWDB 不会拒绝函数内部的写操作，只允许调用已知的只读内置函数
+			if stmt, err := singleStatement(query, "read-query"); err == nil {
+				if name := stmt.UnknownFunctionCall(); name != "" {
+					return fmt.Errorf("a read statement cannot call function %s inside a read-write transaction, where its writes would not be rejected; "+
+						"run it outside the transaction or begin the transaction with read_only", name)
+				}
+			}
+		}
+		return stx.run(ctx, func(tx *sql.Tx) error {
+			return fn(tx)
+		})
+	}
+	return withReadDB(ctx, connectionString, func(db *sql.DB) error {
+		return useConn(ctx, db, func(conn *sql.Conn) error {
+			reset, err := setSessionVar(ctx, conn, "default_transaction_read_only", "on")
+			if err != nil {
+				return fmt.Errorf("failed to enable read-only mode: %v", err)
+			}
+			defer reset()
+
+			return fn(conn)
+		})
+	})
+}
+
+// withConn runs fn on a single connection taken from the pool selected by connectionString.
+// Statements that depend on each other, such as a transaction, must share one connection.
+func withConn(ctx context.Context, connectionString string, fn func(*sql.Conn) error) error {
+	return withDB(ctx, connectionString, func(db *sql.DB) error {
+		return useConn(ctx, db, fn)
+	})
+}
+
+// useConn runs fn on a single connection taken from db.
+// The statement timeout attached to ctx is set on the connection for the duration of fn.
+func useConn(ctx context.Context, db *sql.DB, fn func(*sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %v", err)
+	}
+	defer conn.Close()
+
+	reset, err := applyStatementTimeout(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer reset()
+
+	return fn(conn)
+}
+
+// setSessionVar sets a session variable on conn and returns a function that resets it before
+// the connection goes back to the pool. A connection that cannot be reset is discarded.
+func setSessionVar(ctx context.Context, conn *sql.Conn, name, value string) (func(), error) {
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET %s = %s", name, value)); err != nil {
+		return nil, err
+	}
+	return func() {
+		// 请求的 context 可能已超时或被取消，使用独立的 context 复位
+		resetCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := conn.ExecContext(resetCtx, "RESET "+name); err != nil {
+			log.Printf("Failed to reset %s, discarding connection: %v", name, err)
+			_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+	}, nil
+}
+
+type readURIKey struct{}
+
+// WithReadURI returns a context whose read-only queries use the pool for readConnectionString,
+// e.g. a connection string with a read-only role. An empty string leaves ctx unchanged.
+func WithReadURI(ctx context.Context, readConnectionString string) context.Context {
+	if readConnectionString == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, readURIKey{}, readConnectionString)
+}
+
+// withReadDB runs fn on the pool used for reads: the read URI attached to ctx, else the pool for
+// connectionString, else the read pool of the default PoolManager.
+func withReadDB(ctx context.Context, connectionString string, fn func(*sql.DB) error) error {
+	readURI, _ := ctx.Value(readURIKey{}).(string)
+	if connectionString == "" && readURI == "" {
+		return GetPoolManager().ExecuteWithReadConnection(ctx, fn)
+	}
+	return GetMultiPoolManager().ExecuteReadWithURI(ctx, connectionString, readURI, fn)
+}
+
+// resultColumns reads column metadata from rows.
+func resultColumns(rows *sql.Rows) ([]Column, error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get column types: %v", err)
+	}
+
+	columns := make([]Column, len(columnTypes))
+	for i, ct := range columnTypes {
+		typeName := ct.DatabaseTypeName()
+		if typeName == "" {
+			typeName = "UNKNOWN"
+		}
+		columns[i] = Column{
+			Name: ct.Name(),
+			Type: typeName,
+		}
+		if nullable, ok := ct.Nullable(); ok {
+			columns[i].Nullable = &nullable
+		}
+	}
+	return columns, nil
+}
+
+// scanRow scans the current row and encodes each value with codec.
+func scanRow(rows *sql.Rows, columns []Column, codec ValueCodec) ([]interface{}, error) {
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	if err := rows.Scan(valuePtrs...); err != nil {
+		return nil, err
+	}
+
+	for i, val := range values {
+		values[i] = codec.Encode(val, columns[i].Type)
+	}
+	return values, nil
+}
+
+// scanResultSet reads the rows selected by opts into a ResultSet.
+// Skipped rows are not decoded. Unless CountAll is set, the scan stops at the first row
+// that would exceed MaxRows or MaxBytes, so the driver never buffers more than one extra row.
+// The first row is always returned, marked Oversize when it alone exceeds MaxBytes.
+func scanResultSet(rows *sql.Rows, opts QueryOptions) (*ResultSet, error) {
+	columns, err := resultColumns(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ResultSet{
+		Columns: columns,
+		Rows:    [][]interface{}{},
+	}
+
+	codec := GetValueCodec()
+	for rows.Next() {
+		result.RowsScanned++
+		if result.RowsScanned <= opts.Offset || result.Truncated {
+			continue
+		}
+		if opts.MaxRows > 0 && len(result.Rows) >= opts.MaxRows {
+			result.Truncated = true
+			if opts.CountAll {
+				continue
+			}
+			break
+		}
+
+		values, err := scanRow(rows, columns, codec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+
+		encoded, err := json.Marshal(values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode row: %v", err)
+		}
+		if opts.MaxBytes > 0 && result.Bytes+len(encoded) > opts.MaxBytes {
+			if len(result.Rows) > 0 {
+				result.Truncated = true
+				if opts.CountAll {
+					continue
+				}
+				break
+			}
+			// 单行超过字节预算时仍返回该行并标记，否则分页无法越过它
+			result.Oversize = true
+		}
+
+		result.Bytes += len(encoded)
+		result.Rows = append(result.Rows, values)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,198 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeResult is the result set the fake driver returns for a query.
+type fakeResult struct {
+	columns []string
+	types   []string
+	rows    [][]driver.Value
+	// nullable holds the NOT NULL information the driver reports per column; nil means unknown.
+	nullable []*bool
+	// affected is the row count of the statement when it runs through Exec.
+	affected int64
+	err      error
+}
+
+var (
+	fakeResultsMu sync.Mutex
+	fakeResults   = map[string]fakeResult{}
+	// fakeQueries records the statements the fake driver ran.
+	fakeQueries []string
+)
+
+func init() {
+	sql.Register("kwdb-fake", fakeDriver{})
+}
+
+// openFakeDB returns a database whose queries return results, keyed by the SQL text.
+func openFakeDB(t *testing.T, results map[string]fakeResult) *sql.DB {
+	t.Helper()
+	fakeResultsMu.Lock()
+	fakeResults = results
+	fakeQueries = nil
+	fakeResultsMu.Unlock()
+	conn, err := sql.Open("kwdb-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// useFakePool registers conn as the pool of connectionString.
+func useFakePool(t *testing.T, connectionString string, conn *sql.DB) {
+	t.Helper()
+	mm := GetMultiPoolManager()
+	mm.mu.Lock()
+	mm.pools[connectionString] = &PoolManager{db: conn, connectionString: connectionString, initialized: true, config: DefaultPoolConfig}
+	mm.mu.Unlock()
+	t.Cleanup(func() {
+		mm.mu.Lock()
+		delete(mm.pools, connectionString)
+		mm.mu.Unlock()
+	})
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct{ query string }
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	result, err := s.lookup()
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(result.affected), nil
+}
+
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	result, err := s.lookup()
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{result: result}, nil
+}
+
+func (s fakeStmt) lookup() (fakeResult, error) {
+	fakeResultsMu.Lock()
+	defer fakeResultsMu.Unlock()
+	fakeQueries = append(fakeQueries, s.query)
+	result, ok := fakeResults[s.query]
+	if !ok {
+		return fakeResult{}, fmt.Errorf("unexpected query %q", s.query)
+	}
+	return result, result.err
+}
+
+type fakeRows struct {
+	result fakeResult
+	next   int
+}
+
+func (r *fakeRows) Columns() []string { return r.result.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) ColumnTypeDatabaseTypeName(i int) string { return r.result.types[i] }
+
+func (r *fakeRows) ColumnTypeNullable(i int) (bool, bool) {
+	if i >= len(r.result.nullable) || r.result.nullable[i] == nil {
+		return false, false
+	}
+	return *r.result.nullable[i], true
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
+
+func TestScanResultSetOversizeRow(t *testing.T) {
+	big := strings.Repeat("x", 100)
+	conn := openFakeDB(t, map[string]fakeResult{
+		"SELECT v FROM t": {
+			columns: []string{"v"},
+			types:   []string{"TEXT"},
+			rows:    [][]driver.Value{{big}, {"small"}, {"small"}},
+		},
+	})
+	scan := func(opts QueryOptions) *ResultSet {
+		t.Helper()
+		rows, err := conn.Query("SELECT v FROM t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		result, err := scanResultSet(rows, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	// A row larger than max_bytes is still returned as the first row of a page, so paging can move on.
+	result := scan(QueryOptions{MaxRows: 10, MaxBytes: 20})
+	if len(result.Rows) != 1 || result.Rows[0][0] != big || !result.Oversize || !result.Truncated {
+		t.Fatalf("unexpected result rows=%d oversize=%v truncated=%v", len(result.Rows), result.Oversize, result.Truncated)
+	}
+
+	// Later rows stay within the budget.
+	result = scan(QueryOptions{Offset: 1, MaxRows: 10, MaxBytes: 12})
+	if len(result.Rows) != 1 || result.Rows[0][0] != "small" || result.Oversize || !result.Truncated {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestResultColumnsNullable(t *testing.T) {
+	notNull := false
+	conn := openFakeDB(t, map[string]fakeResult{
+		"SELECT id, v FROM t": {
+			columns:  []string{"id", "v"},
+			types:    []string{"INT8", "TEXT"},
+			nullable: []*bool{&notNull, nil},
+		},
+	})
+	rows, err := conn.Query("SELECT id, v FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	columns, err := resultColumns(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if columns[0].Nullable == nil || *columns[0].Nullable {
+		t.Fatalf("id should be reported NOT NULL, got %v", columns[0].Nullable)
+	}
+	if columns[1].Nullable != nil {
+		t.Fatalf("v should have unknown nullability, got %v", *columns[1].Nullable)
+	}
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StatementResult reports the outcome of one statement of a script.
+type StatementResult struct {
+	Index        int               `json:"index"`
+	SQL          string            `json:"sql"`
+	Keyword      string            `json:"keyword"`
+	Category     StatementCategory `json:"category"`
+	AffectedRows int64             `json:"affected_rows"`
+	DurationMS   float64           `json:"duration_ms"`
+}
+
+// ScriptResult reports the statements of a script that ran, in order.
+type ScriptResult struct {
+	Atomic     bool              `json:"atomic"`
+	Statements []StatementResult `json:"statements"`
+	// DurationMS is the wall time of the whole script, including commit.
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// ScriptError is returned by ExecuteScript when a statement fails.
+type ScriptError struct {
+	// Index is the zero-based position of the failed statement in the script.
+	Index int
+	SQL   string
+	Err   error
+	// RolledBack is true when the script ran atomically and earlier statements were undone.
+	RolledBack bool
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("statement %d failed: %v", e.Index, e.Err)
+}
+
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// ExecuteScript splits script into statements and runs them in order on one connection.
+// connectionString selects a tenant database; when empty the default pool is used.
+// With atomic set, all statements run in a single transaction that is rolled back on failure.
+// When ctx carries a session transaction the statements run in it instead.
+//
+// Session statements (SET, BEGIN, COMMIT, ...) are refused because the connection returns to
+// the pool afterwards. When a statement fails, the returned ScriptResult lists the statements
+// that completed and the error is a *ScriptError naming the failed statement.
+// A DML statement over the limit set with WithMaxAffectedRows fails the script the same way;
+// its changes are only undone when the script runs atomically or in a session transaction.
+func ExecuteScript(ctx context.Context, connectionString, script string, atomic bool) (*ScriptResult, error) {
+	statements, err := SplitStatements(script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SQL: %v", err)
+	}
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("no SQL statement given")
+	}
+	for i, stmt := range statements {
+		if stmt.Category == CategorySession || stmt.Category == CategoryUnknown {
+			return nil, fmt.Errorf("statement %d: %s statement not allowed in a script: %s", i, stmt.Category, stmt.Keyword)
+		}
+	}
+
+	result := &ScriptResult{
+		Atomic:     atomic,
+		Statements: []StatementResult{},
+	}
+	started := time.Now()
+
+	if stx := sessionTxFromContext(ctx); stx != nil {
+		// Inside a session transaction the script joins it; the caller decides whether to commit.
+		err = stx.run(ctx, func(tx *sql.Tx) error {
+			return runStatements(ctx, tx.ExecContext, statements, result)
+		})
+		var limitErr *AffectedRowsError
+		var scriptErr *ScriptError
+		if errors.As(err, &limitErr) && errors.As(err, &scriptErr) {
+			// The statement over the limit already ran in the transaction, so it cannot be kept.
+			GetTxManager().abort(stx, "rolled back because a statement exceeded the affected rows limit")
+			scriptErr.RolledBack = true
+		}
+	} else {
+		err = withConn(ctx, connectionString, func(conn *sql.Conn) error {
+			if !atomic {
+				return runStatements(ctx, conn.ExecContext, statements, result)
+			}
+
+			tx, err := conn.BeginTx(ctx, nil)
+			if err != nil {
+				return fmt.Errorf("failed to begin transaction: %v", err)
+			}
+			if err := runStatements(ctx, tx.ExecContext, statements, result); err != nil {
+				_ = tx.Rollback()
+				if scriptErr, ok := err.(*ScriptError); ok {
+					scriptErr.RolledBack = true
+				}
+				return err
+			}
+			if err := tx.Commit(); err != nil {
+				return fmt.Errorf("failed to commit transaction: %v", err)
+			}
+			return nil
+		})
+	}
+
+	result.DurationMS = durationMS(time.Since(started))
+	return result, err
+}
+
+// runStatements executes statements in order with exec and appends their results.
+func runStatements(ctx context.Context, exec func(context.Context, string, ...interface{}) (sql.Result, error), statements []Statement, result *ScriptResult) error {
+	for i, stmt := range statements {
+		started := time.Now()
+		res, err := exec(ctx, stmt.SQL)
+		if err != nil {
+			return &ScriptError{Index: i, SQL: stmt.SQL, Err: err}
+		}
+
+		// DDL and admin statements may not report a row count.
+		affected, _ := res.RowsAffected()
+		if err := checkAffectedRows(ctx, stmt, affected); err != nil {
+			return &ScriptError{Index: i, SQL: stmt.SQL, Err: err}
+		}
+		result.Statements = append(result.Statements, StatementResult{
+			Index:        i,
+			SQL:          stmt.SQL,
+			Keyword:      stmt.Keyword,
+			Category:     stmt.Category,
+			AffectedRows: affected,
+			DurationMS:   durationMS(time.Since(started)),
+		})
+	}
+	return nil
+}
+
+// durationMS converts d to fractional milliseconds.
+func durationMS(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
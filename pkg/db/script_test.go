@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExecuteScriptValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{name: "empty script", script: " ; -- nothing\n", want: "no SQL statement"},
+		{name: "transaction control", script: "BEGIN; INSERT INTO t VALUES (1); COMMIT", want: "statement 0: session statement"},
+		{name: "set", script: "CREATE TABLE t (id INT); SET database = other", want: "statement 1: session statement"},
+		{name: "unterminated literal", script: "INSERT INTO t VALUES ('open", want: "failed to parse SQL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Validation happens before any connection is taken.
+			_, err := ExecuteScript(context.Background(), "", tt.script, true)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ExecuteScript(%q) error = %v, want %q", tt.script, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptError(t *testing.T) {
+	cause := errors.New("relation \"t\" does not exist")
+	err := error(&ScriptError{Index: 2, SQL: "INSERT INTO t VALUES (1)", Err: cause})
+
+	var scriptErr *ScriptError
+	if !errors.As(err, &scriptErr) || scriptErr.Index != 2 {
+		t.Fatalf("errors.As should find the ScriptError, got %v", err)
+	}
+	if !errors.Is(err, cause) {
+		t.Fatal("ScriptError should unwrap to its cause")
+	}
+	if err.Error() != `statement 2 failed: relation "t" does not exist` {
+		t.Fatalf("unexpected message: %s", err.Error())
+	}
+}
@@ -0,0 +1,350 @@
+package db
+
+import "fmt"
+
+// StatementCategory classifies what a statement does.
+type StatementCategory string
+
+const (
+	// CategoryRead covers SELECT, SHOW, EXPLAIN and other statements that only read data.
+	CategoryRead StatementCategory = "read"
+	// CategoryDML covers INSERT, UPSERT, UPDATE and DELETE, including data-modifying WITH statements.
+	CategoryDML StatementCategory = "dml"
+	// CategoryDDL covers CREATE, ALTER, DROP, TRUNCATE and other schema changes.
+	CategoryDDL StatementCategory = "ddl"
+	// CategoryDCL covers GRANT, REVOKE and user or role management.
+	CategoryDCL StatementCategory = "dcl"
+	// CategoryAdmin covers cluster operations such as IMPORT, EXPORT, BACKUP, RESTORE and SET CLUSTER SETTING.
+	CategoryAdmin StatementCategory = "admin"
+	// CategorySession covers SET, transaction control and other statements that change session state.
+	CategorySession StatementCategory = "session"
+	// CategoryUnknown is used for statements the classifier does not recognize.
+	CategoryUnknown StatementCategory = "unknown"
+)
+
+// IsWrite reports whether statements of this category may change data, schema, privileges or cluster state.
+func (c StatementCategory) IsWrite() bool {
+	switch c {
+	case CategoryDML, CategoryDDL, CategoryDCL, CategoryAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// Statement is one statement of a SQL text.
+type Statement struct {
+	// SQL is the statement text without the separating semicolon.
+	SQL string
+	// Start is the byte offset of SQL in the text passed to SplitStatements.
+	Start int
+	// Keyword names the operation, e.g. SELECT, DELETE or SET CLUSTER SETTING.
+	// For WITH and EXPLAIN ANALYZE statements that modify data it is the modifying verb.
+	Keyword string
+	// Category is the statement class used by the tools and the policy layer.
+	Category StatementCategory
+	// Tokens are the statement's tokens, comments included, with offsets relative to SQL.
+	Tokens []Token
+}
+
+// SplitStatements tokenizes sql and splits it into statements at top-level semicolons.
+// Empty statements (only whitespace or comments) are dropped.
+func SplitStatements(sql string) ([]Statement, error) {
+	tokens, err := Tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	var statements []Statement
+	flush := func(group []Token) {
+		if len(SignificantTokens(group)) == 0 {
+			return
+		}
+		start, end := group[0].Start, group[len(group)-1].End
+		stmt := Statement{SQL: sql[start:end], Start: start}
+		for _, tok := range group {
+			tok.Start -= start
+			tok.End -= start
+			stmt.Tokens = append(stmt.Tokens, tok)
+		}
+		stmt.Keyword, stmt.Category = classifyTokens(SignificantTokens(stmt.Tokens))
+		statements = append(statements, stmt)
+	}
+
+	begin := 0
+	for i, tok := range tokens {
+		if tok.Kind == TokenSemicolon {
+			flush(tokens[begin:i])
+			begin = i + 1
+		}
+	}
+	flush(tokens[begin:])
+	return statements, nil
+}
+
+// HasReturning reports whether the statement has a top-level RETURNING clause.
+func (s Statement) HasReturning() bool {
+	depth := 0
+	for _, tok := range s.Tokens {
+		switch {
+		case tok.IsOperator("(") || tok.IsOperator("["):
+			depth++
+		case tok.IsOperator(")") || tok.IsOperator("]"):
+			depth--
+		case depth == 0 && tok.Is("RETURNING"):
+			return true
+		}
+	}
+	return false
+}
+
+// WithReturningAll returns the statement text with RETURNING * appended, and false when the
+// statement is not a plain INSERT, UPSERT, UPDATE or DELETE or already returns rows.
+func (s Statement) WithReturningAll() (string, bool) {
+	toks := SignificantTokens(s.Tokens)
+	if len(toks) == 0 || !toks[0].Is("INSERT", "UPSERT", "UPDATE", "DELETE") || s.HasReturning() {
+		return s.SQL, false
+	}
+	return s.SQL[:toks[len(toks)-1].End] + " RETURNING *", true
+}
+
+// UnknownFunctionCall returns the name of the first function the statement calls that is not a
+// known read-only built-in, or "" when there is none. A function can write even when the
+// statement calling it is a read. Schema-qualified calls are never treated as built-ins.
+func (s Statement) UnknownFunctionCall() string {
+	toks := SignificantTokens(s.Tokens)
+	for i := 0; i+1 < len(toks); i++ {
+		tok := toks[i]
+		if (tok.Kind != TokenWord && tok.Kind != TokenQuotedIdent) || !toks[i+1].IsOperator("(") {
+			continue
+		}
+		// Column alias lists (AS t(a, b)) and type modifiers (::VARCHAR(10)) are not calls.
+		if (i > 0 && toks[i-1].Is("AS")) || (i > 1 && toks[i-1].IsOperator(":") && toks[i-2].IsOperator(":")) {
+			continue
+		}
+		qualified := i > 0 && toks[i-1].IsOperator(".")
+		if !qualified && (parenKeywords[tok.Keyword()] || readOnlyBuiltins[tok.Keyword()]) {
+			continue
+		}
+		return tok.Text
+	}
+	return ""
+}
+
+// parenKeywords are keywords that may be followed by "(" without calling a function.
+var parenKeywords = setOf(
+	"ALL", "AND", "ANY", "ARRAY", "AS", "BETWEEN", "BY", "CASE", "CAST", "CUBE", "DISTINCT", "ELSE",
+	"EXCEPT", "EXISTS", "EXPLAIN", "FILTER", "FROM", "GROUPING", "HAVING", "ILIKE", "IN", "INTERSECT",
+	"IS", "JOIN", "LATERAL", "LIKE", "LIMIT", "NOT", "OFFSET", "ON", "OR", "OVER", "ROLLUP", "ROW",
+	"SELECT", "SETS", "SIMILAR", "SOME", "TABLE", "THEN", "UNION", "USING", "VALUES", "WHEN", "WHERE",
+	"WITH", "WITHIN",
+)
+
+// readOnlyBuiltins are the built-in functions that read-query may call inside a read-write
+// session transaction.
+var readOnlyBuiltins = setOf(
+	// Aggregates and window functions.
+	"COUNT", "SUM", "AVG", "MIN", "MAX", "ARRAY_AGG", "STRING_AGG", "JSON_AGG", "JSONB_AGG",
+	"BOOL_AND", "BOOL_OR", "EVERY", "STDDEV", "VARIANCE", "FIRST", "LAST", "FIRST_TS", "LAST_TS",
+	"ROW_NUMBER", "RANK", "DENSE_RANK", "PERCENT_RANK", "CUME_DIST", "NTILE", "LAG", "LEAD",
+	"FIRST_VALUE", "LAST_VALUE", "NTH_VALUE",
+	// Conditional and math functions.
+	"COALESCE", "NULLIF", "IFNULL", "GREATEST", "LEAST", "ABS", "CEIL", "CEILING", "FLOOR",
+	"ROUND", "TRUNC", "MOD", "POWER", "POW", "SQRT", "EXP", "LN", "LOG", "SIGN",
+	// String functions.
+	"LENGTH", "CHAR_LENGTH", "OCTET_LENGTH", "LOWER", "UPPER", "SUBSTR", "SUBSTRING", "TRIM",
+	"LTRIM", "RTRIM", "REPLACE", "CONCAT", "CONCAT_WS", "SPLIT_PART", "LEFT", "RIGHT", "LPAD",
+	"RPAD", "POSITION", "STRPOS", "REVERSE", "REPEAT", "MD5", "SHA256", "TO_HEX", "ENCODE",
+	"DECODE", "FORMAT",
+	// Time functions.
+	"NOW", "CURRENT_TIMESTAMP", "CURRENT_DATE", "DATE_TRUNC", "DATE_PART", "EXTRACT", "AGE",
+	"TIME_BUCKET", "TO_CHAR", "TO_TIMESTAMP", "TO_DATE", "EXPERIMENTAL_STRFTIME",
+	// Arrays, JSON and set-returning functions.
+	"ARRAY_LENGTH", "UNNEST", "GENERATE_SERIES", "TO_JSON", "TO_JSONB", "JSON_BUILD_OBJECT",
+	"JSONB_BUILD_OBJECT", "JSON_EXTRACT_PATH", "JSONB_EXTRACT_PATH", "JSON_TYPEOF", "JSONB_TYPEOF",
+)
+
+func setOf(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+// ValidateReadQuery checks that query is exactly one read statement.
+func ValidateReadQuery(query string) error {
+	stmt, err := singleStatement(query, "read-query")
+	if err != nil {
+		return err
+	}
+	switch stmt.Category {
+	case CategoryRead:
+		return nil
+	case CategorySession, CategoryUnknown:
+		return fmt.Errorf("%s statement not allowed in read-query: %s", stmt.Category, stmt.Keyword)
+	default:
+		return fmt.Errorf("write operation not allowed in read-query: %s", stmt.Keyword)
+	}
+}
+
+// ValidateWriteQuery checks that query is exactly one DML, DDL, DCL or admin statement.
+func ValidateWriteQuery(query string) error {
+	_, err := parseWriteStatement(query)
+	return err
+}
+
+// parseWriteStatement returns the single write statement of query.
+func parseWriteStatement(query string) (*Statement, error) {
+	stmt, err := singleStatement(query, "write-query")
+	if err != nil {
+		return nil, err
+	}
+	if stmt.Category.IsWrite() {
+		return stmt, nil
+	}
+	if stmt.Category == CategoryRead {
+		return nil, fmt.Errorf("not a write operation: %s is a read statement, use read-query", stmt.Keyword)
+	}
+	return nil, fmt.Errorf("%s statement not allowed in write-query: %s", stmt.Category, stmt.Keyword)
+}
+
+func singleStatement(query, tool string) (*Statement, error) {
+	statements, err := SplitStatements(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SQL: %v", err)
+	}
+	switch len(statements) {
+	case 0:
+		return nil, fmt.Errorf("no SQL statement given")
+	case 1:
+		return &statements[0], nil
+	default:
+		return nil, fmt.Errorf("%s accepts a single statement, got %d", tool, len(statements))
+	}
+}
+
+// SignificantTokens returns tokens without comments.
+func SignificantTokens(tokens []Token) []Token {
+	result := make([]Token, 0, len(tokens))
+	for _, tok := range tokens {
+		if tok.Kind != TokenComment {
+			result = append(result, tok)
+		}
+	}
+	return result
+}
+
+// classifyTokens returns the operation keyword and category of one statement's significant tokens.
+func classifyTokens(toks []Token) (string, StatementCategory) {
+	// Parenthesized queries such as (SELECT 1) UNION (SELECT 2).
+	i := 0
+	for i < len(toks) && toks[i].IsOperator("(") {
+		i++
+	}
+	if i >= len(toks) || toks[i].Kind != TokenWord {
+		return "", CategoryUnknown
+	}
+	toks = toks[i:]
+	first := toks[0].Keyword()
+	word := func(n int) string {
+		if n < len(toks) {
+			return toks[n].Keyword()
+		}
+		return ""
+	}
+
+	switch first {
+	case "SELECT", "VALUES", "TABLE", "WITH":
+		if kw := embeddedDML(toks); kw != "" {
+			return kw, CategoryDML
+		}
+		return first, CategoryRead
+	case "SHOW":
+		return first, CategoryRead
+	case "EXPLAIN":
+		return classifyExplain(toks[1:])
+	case "INSERT", "UPSERT", "UPDATE", "DELETE":
+		return first, CategoryDML
+	case "CREATE", "ALTER", "DROP":
+		switch word(1) {
+		case "USER", "ROLE", "OWNED":
+			return first, CategoryDCL
+		case "SCHEDULE":
+			return first, CategoryAdmin
+		}
+		return first, CategoryDDL
+	case "TRUNCATE", "RENAME", "COMMENT", "REFRESH":
+		return first, CategoryDDL
+	case "GRANT", "REVOKE", "REASSIGN":
+		return first, CategoryDCL
+	case "IMPORT", "EXPORT", "BACKUP", "RESTORE", "CANCEL", "PAUSE", "RESUME", "SCRUB":
+		return first, CategoryAdmin
+	case "SET", "RESET":
+		if word(1) == "CLUSTER" && word(2) == "SETTING" {
+			return first + " CLUSTER SETTING", CategoryAdmin
+		}
+		return first, CategorySession
+	case "BEGIN", "START", "COMMIT", "END", "ROLLBACK", "ABORT", "SAVEPOINT", "RELEASE",
+		"PREPARE", "EXECUTE", "DEALLOCATE", "DISCARD", "USE":
+		return first, CategorySession
+	}
+	return first, CategoryUnknown
+}
+
+// embeddedDML returns the modifying verb of a read-shaped statement that still writes:
+// the main statement of WITH ... DELETE, a data-modifying CTE, or a [INSERT ...] data source.
+func embeddedDML(toks []Token) string {
+	for i := 1; i < len(toks); i++ {
+		if !toks[i].Is("INSERT", "UPSERT", "UPDATE", "DELETE") {
+			continue
+		}
+		prev := toks[i-1]
+		if prev.IsOperator("(") || prev.IsOperator("[") || prev.IsOperator(")") {
+			return toks[i].Keyword()
+		}
+	}
+	return ""
+}
+
+// classifyExplain classifies the statement following EXPLAIN. Only EXPLAIN ANALYZE executes
+// the inner statement, so plain EXPLAIN is always a read.
+func classifyExplain(toks []Token) (string, StatementCategory) {
+	analyze := false
+	for len(toks) > 0 {
+		switch {
+		case toks[0].Is("ANALYZE", "ANALYSE"):
+			analyze = true
+			toks = toks[1:]
+		case toks[0].Is("VERBOSE"):
+			toks = toks[1:]
+		case toks[0].IsOperator("(") && len(toks) > 1 && !toks[1].Is("SELECT", "VALUES", "TABLE", "WITH"):
+			// Option list such as (ANALYZE, DISTSQL).
+			depth := 0
+			j := 0
+			for ; j < len(toks); j++ {
+				if toks[j].IsOperator("(") {
+					depth++
+				} else if toks[j].IsOperator(")") {
+					depth--
+					if depth == 0 {
+						break
+					}
+				} else if toks[j].Is("ANALYZE", "ANALYSE") {
+					analyze = true
+				}
+			}
+			if j >= len(toks) {
+				return "EXPLAIN", CategoryRead
+			}
+			toks = toks[j+1:]
+		default:
+			keyword, category := classifyTokens(toks)
+			if analyze && category != CategoryRead {
+				return keyword, category
+			}
+			return "EXPLAIN", CategoryRead
+		}
+	}
+	return "EXPLAIN", CategoryRead
+}
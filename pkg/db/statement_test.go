@@ -0,0 +1,151 @@
+package db
+
+import "testing"
+
+func TestTokenize(t *testing.T) {
+	sql := `SELECT 'a;b', E'it\'s', $tag$ ; $1 $tag$, "x""y", $2 /* c /* nested */ ; */ -- ;` + "\n;"
+	tokens, err := Tokenize(sql)
+	if err != nil {
+		t.Fatalf("Tokenize returned error: %v", err)
+	}
+
+	var kinds []TokenKind
+	for _, tok := range tokens {
+		kinds = append(kinds, tok.Kind)
+	}
+	want := []TokenKind{
+		TokenWord, TokenString, TokenOperator, TokenString, TokenOperator, TokenString, TokenOperator,
+		TokenQuotedIdent, TokenOperator, TokenPlaceholder, TokenComment, TokenComment, TokenSemicolon,
+	}
+	if len(kinds) != len(want) {
+		t.Fatalf("got %d tokens %v, want %d", len(kinds), tokens, len(want))
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("token %d (%q) kind = %d, want %d", i, tokens[i].Text, kinds[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{"SELECT 'open", "SELECT /* open", `SELECT "open`, "SELECT $$ open"} {
+		if _, err := Tokenize(bad); err == nil {
+			t.Fatalf("Tokenize(%q) should fail", bad)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	statements, err := SplitStatements("SELECT ';'; ; -- only a comment\n; DROP TABLE t;")
+	if err != nil {
+		t.Fatalf("SplitStatements returned error: %v", err)
+	}
+	if len(statements) != 2 {
+		t.Fatalf("expected 2 statements, got %d: %+v", len(statements), statements)
+	}
+	if statements[0].SQL != "SELECT ';'" || statements[1].SQL != "DROP TABLE t" {
+		t.Fatalf("unexpected statement text: %q, %q", statements[0].SQL, statements[1].SQL)
+	}
+	if statements[1].Category != CategoryDDL {
+		t.Fatalf("DROP should be ddl, got %s", statements[1].Category)
+	}
+}
+
+func TestClassifyStatements(t *testing.T) {
+	tests := []struct {
+		sql      string
+		keyword  string
+		category StatementCategory
+	}{
+		{"SELECT * FROM t", "SELECT", CategoryRead},
+		{"(SELECT 1) UNION (SELECT 2)", "SELECT", CategoryRead},
+		{"SELECT * FROM t FOR UPDATE", "SELECT", CategoryRead},
+		{"SHOW TABLES", "SHOW", CategoryRead},
+		{"EXPLAIN SELECT 1", "EXPLAIN", CategoryRead},
+		{"EXPLAIN DELETE FROM t", "EXPLAIN", CategoryRead},
+		{"EXPLAIN ANALYZE DELETE FROM t", "DELETE", CategoryDML},
+		{"EXPLAIN (ANALYZE, DISTSQL) UPDATE t SET a = 1", "UPDATE", CategoryDML},
+		{"EXPLAIN ANALYZE SELECT 1", "EXPLAIN", CategoryRead},
+		{"WITH x AS (SELECT 1) DELETE FROM t WHERE id IN (SELECT * FROM x)", "DELETE", CategoryDML},
+		{"WITH d AS (DELETE FROM t RETURNING id) SELECT * FROM d", "DELETE", CategoryDML},
+		{"SELECT * FROM [INSERT INTO t VALUES (1) RETURNING id]", "INSERT", CategoryDML},
+		{"UPSERT INTO t VALUES (1)", "UPSERT", CategoryDML},
+		{"/* comment */ DROP TABLE t", "DROP", CategoryDDL},
+		{"-- comment\nTRUNCATE t", "TRUNCATE", CategoryDDL},
+		{"CREATE TABLE t (id INT)", "CREATE", CategoryDDL},
+		{"CREATE USER bob", "CREATE", CategoryDCL},
+		{"GRANT SELECT ON t TO bob", "GRANT", CategoryDCL},
+		{"IMPORT TABLE t CSV DATA ('nodelocal://1/t.csv')", "IMPORT", CategoryAdmin},
+		{"EXPORT INTO CSV 'nodelocal://1/out' FROM TABLE t", "EXPORT", CategoryAdmin},
+		{"BACKUP DATABASE d TO 'nodelocal://1/b'", "BACKUP", CategoryAdmin},
+		{"RESTORE DATABASE d FROM 'nodelocal://1/b'", "RESTORE", CategoryAdmin},
+		{"SET CLUSTER SETTING ts.dedup.rule = 'merge'", "SET CLUSTER SETTING", CategoryAdmin},
+		{"SET application_name = 'x'", "SET", CategorySession},
+		{"BEGIN", "BEGIN", CategorySession},
+		{"FOO BAR", "FOO", CategoryUnknown},
+	}
+
+	for _, tt := range tests {
+		statements, err := SplitStatements(tt.sql)
+		if err != nil {
+			t.Fatalf("SplitStatements(%q) returned error: %v", tt.sql, err)
+		}
+		if len(statements) != 1 {
+			t.Fatalf("SplitStatements(%q) returned %d statements", tt.sql, len(statements))
+		}
+		if statements[0].Keyword != tt.keyword || statements[0].Category != tt.category {
+			t.Fatalf("classify(%q) = %s/%s, want %s/%s", tt.sql,
+				statements[0].Keyword, statements[0].Category, tt.keyword, tt.category)
+		}
+	}
+}
+
+func TestValidateQueries(t *testing.T) {
+	if err := ValidateReadQuery("SELECT 1;"); err != nil {
+		t.Fatalf("single SELECT should be allowed: %v", err)
+	}
+	for _, sql := range []string{"SELECT 1; DROP TABLE t", "WITH x AS (SELECT 1) DELETE FROM t", "SET CLUSTER SETTING a = 1", "SET x = 1", ""} {
+		if err := ValidateReadQuery(sql); err == nil {
+			t.Fatalf("ValidateReadQuery(%q) should fail", sql)
+		}
+	}
+
+	if err := ValidateWriteQuery("INSERT INTO t VALUES ($1)"); err != nil {
+		t.Fatalf("INSERT should be allowed: %v", err)
+	}
+	for _, sql := range []string{"SELECT 1", "BEGIN", "INSERT INTO t VALUES (1); SELECT 1"} {
+		if err := ValidateWriteQuery(sql); err == nil {
+			t.Fatalf("ValidateWriteQuery(%q) should fail", sql)
+		}
+	}
+
+	if isWrite, op := ClassifyQuery("/* x */ DROP TABLE t"); !isWrite || op != "DROP" {
+		t.Fatalf("ClassifyQuery should report DROP as write, got %v %q", isWrite, op)
+	}
+	if isWrite, _ := ClassifyQuery("SELECT 'DROP TABLE t'"); isWrite {
+		t.Fatal("keywords inside string literals must not count")
+	}
+}
+
+func TestUnknownFunctionCall(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT count(*), max(v) FROM t WHERE id IN (1, 2) AND NOT EXISTS (SELECT 1)", ""},
+		{"SELECT coalesce(a, 0)::DECIMAL(10, 2) FROM (VALUES (1)) AS v(a)", ""},
+		{"SELECT row_number() OVER (PARTITION BY a ORDER BY b) FROM t", ""},
+		{"SELECT 'audit(1)' FROM t -- purge(1)", ""},
+		{"SELECT purge_old_rows(30)", "purge_old_rows"},
+		{"SELECT * FROM t WHERE id = ANY (SELECT id FROM s) AND v > archive(id)", "archive"},
+		{"SELECT public.count(*) FROM t", "count"},
+		{`SELECT "Cleanup"()`, `"Cleanup"`},
+	}
+	for _, tt := range tests {
+		statements, err := SplitStatements(tt.sql)
+		if err != nil || len(statements) != 1 {
+			t.Fatalf("SplitStatements(%q) = %v, %v", tt.sql, statements, err)
+		}
+		if got := statements[0].UnknownFunctionCall(); got != tt.want {
+			t.Errorf("UnknownFunctionCall(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
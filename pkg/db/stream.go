@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// StreamQuery runs a read-only query like ExecuteQueryWithOptions, but hands the columns to
+// start and then every row to row as it is read, so the result is never held in memory.
+// Binary values are not capped, because the rows are not returned to the caller of a tool.
+func StreamQuery(ctx context.Context, connectionString, query string, start func([]Column) error, row func([]interface{}) error, args ...interface{}) error {
+	if err := ValidateReadQuery(query); err != nil {
+		return err
+	}
+
+	return withReadOnly(ctx, connectionString, query, func(q querier) error {
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("query execution failed: %v", err)
+		}
+		defer rows.Close()
+
+		columns, err := resultColumns(rows)
+		if err != nil {
+			return err
+		}
+		if err := start(columns); err != nil {
+			return err
+		}
+
+		codec := GetValueCodec()
+		codec.MaxBytes = 0
+		for rows.Next() {
+			values, err := scanRow(rows, columns, codec)
+			if err != nil {
+				return fmt.Errorf("failed to scan row: %v", err)
+			}
+			if err := row(values); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+}
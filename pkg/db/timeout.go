@@ -0,0 +1,207 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StatementTimeouts bounds how long a statement may run.
+type StatementTimeouts struct {
+	// Default applies to every database without an override; 0 disables it.
+	Default time.Duration
+	// Tenants overrides Default per database. Keys are matched against the
+	// connection string as "host:port/database" first, then as "database".
+	Tenants map[string]time.Duration
+}
+
+var (
+	statementTimeoutsMu sync.RWMutex
+	statementTimeouts   StatementTimeouts
+)
+
+// SetStatementTimeouts replaces the statement timeouts used by StatementTimeoutFor.
+func SetStatementTimeouts(timeouts StatementTimeouts) {
+	statementTimeoutsMu.Lock()
+	defer statementTimeoutsMu.Unlock()
+	statementTimeouts = timeouts
+}
+
+// StatementTimeoutFor returns the statement timeout of the database selected by connectionString;
+// when empty, the default pool's database is used.
+func StatementTimeoutFor(connectionString string) time.Duration {
+	keys := TenantKeys(connectionString)
+
+	statementTimeoutsMu.RLock()
+	defer statementTimeoutsMu.RUnlock()
+	for _, key := range keys {
+		if d, ok := statementTimeouts.Tenants[key]; ok {
+			return d
+		}
+	}
+	return statementTimeouts.Default
+}
+
+// TenantKeys returns the names under which per-tenant settings of the database selected by
+// connectionString are looked up, most specific first: "host:port/database", then "database".
+// When connectionString is empty the default pool's database is used. Both URL and key=value
+// connection strings are understood; nil is returned when the database cannot be determined.
+func TenantKeys(connectionString string) []string {
+	if connectionString == "" {
+		pm := GetPoolManager()
+		pm.mu.RLock()
+		connectionString = pm.connectionString
+		pm.mu.RUnlock()
+	}
+	host, database := connectionTarget(connectionString)
+	if database == "" {
+		return nil
+	}
+	return []string{host + "/" + database, database}
+}
+
+// connectionTarget returns the "host[:port]" and database of a URL or key=value connection
+// string, or empty strings when it cannot be parsed.
+func connectionTarget(connectionString string) (string, string) {
+	if u, err := url.Parse(connectionString); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		return u.Host, strings.TrimPrefix(u.Path, "/")
+	}
+	opts, err := parseKeywordDSN(connectionString)
+	if err != nil {
+		return "", ""
+	}
+	host := opts["host"]
+	if port := opts["port"]; port != "" {
+		host += ":" + port
+	}
+	return host, opts["dbname"]
+}
+
+// parseKeywordDSN parses a key=value connection string as lib/pq does: values may be
+// single-quoted, and a backslash escapes the next character.
+func parseKeywordDSN(dsn string) (map[string]string, error) {
+	opts := make(map[string]string)
+	s := []rune(dsn)
+	i := 0
+	skipSpace := func() {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n' || s[i] == '\r') {
+			i++
+		}
+	}
+	for {
+		skipSpace()
+		if i >= len(s) {
+			return opts, nil
+		}
+		start := i
+		for i < len(s) && s[i] != '=' && s[i] != ' ' && s[i] != '\t' {
+			i++
+		}
+		key := string(s[start:i])
+		skipSpace()
+		if key == "" || i >= len(s) || s[i] != '=' {
+			return nil, fmt.Errorf("missing \"=\" after %q in connection string", key)
+		}
+		i++
+		skipSpace()
+
+		var value []rune
+		quoted := i < len(s) && s[i] == '\''
+		if quoted {
+			i++
+		}
+		for ; i < len(s); i++ {
+			c := s[i]
+			if quoted && c == '\'' {
+				break
+			}
+			if !quoted && (c == ' ' || c == '\t' || c == '\n' || c == '\r') {
+				break
+			}
+			if c == '\\' && i+1 < len(s) {
+				i++
+				c = s[i]
+			}
+			value = append(value, c)
+		}
+		if quoted {
+			if i >= len(s) {
+				return nil, fmt.Errorf("unterminated quoted value of %q in connection string", key)
+			}
+			i++
+		}
+		opts[key] = string(value)
+	}
+}
+
+// ParseTenantTimeouts parses a comma-separated list of key=duration pairs such as
+// "analytics=2m,10.0.0.5:26257/iot=10s".
+func ParseTenantTimeouts(spec string) (map[string]time.Duration, error) {
+	tenants := make(map[string]time.Duration)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid tenant timeout %q: expected database=duration", item)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid tenant timeout %q: %v", item, err)
+		}
+		tenants[key] = d
+	}
+	return tenants, nil
+}
+
+type statementTimeoutKey struct{}
+
+// WithStatementTimeout returns a context that bounds the statements run with it to d, both
+// through a context deadline and through SET statement_timeout on the connection.
+// A non-positive d returns ctx unchanged.
+func WithStatementTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	ctx = context.WithValue(ctx, statementTimeoutKey{}, d)
+	return context.WithTimeout(ctx, d)
+}
+
+// statementTimeoutFromContext returns the statement timeout attached to ctx, or 0.
+func statementTimeoutFromContext(ctx context.Context) time.Duration {
+	d, _ := ctx.Value(statementTimeoutKey{}).(time.Duration)
+	return d
+}
+
+// IsTimeout reports whether err was caused by the statement timeout or the deadline of ctx.
+func IsTimeout(ctx context.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return true
+	}
+	return strings.Contains(err.Error(), "statement timeout")
+}
+
+// applyStatementTimeout sets statement_timeout on conn for the timeout attached to ctx and
+// returns a function that resets it before the connection goes back to the pool.
+func applyStatementTimeout(ctx context.Context, conn *sql.Conn) (func(), error) {
+	d := statementTimeoutFromContext(ctx)
+	if d <= 0 {
+		return func() {}, nil
+	}
+	reset, err := setSessionVar(ctx, conn, "statement_timeout", fmt.Sprintf("'%dms'", d.Milliseconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set statement timeout: %v", err)
+	}
+	return reset, nil
+}
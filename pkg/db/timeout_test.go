@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseTenantTimeouts(t *testing.T) {
+	tenants, err := ParseTenantTimeouts(" analytics=2m, 10.0.0.5:26257/iot=10s ,")
+	if err != nil {
+		t.Fatalf("ParseTenantTimeouts returned error: %v", err)
+	}
+	if tenants["analytics"] != 2*time.Minute || tenants["10.0.0.5:26257/iot"] != 10*time.Second {
+		t.Fatalf("unexpected tenants: %v", tenants)
+	}
+
+	for _, bad := range []string{"analytics", "=1s", "iot=soon", "iot=-1s"} {
+		if _, err := ParseTenantTimeouts(bad); err == nil {
+			t.Fatalf("ParseTenantTimeouts(%q) should fail", bad)
+		}
+	}
+}
+
+func TestStatementTimeoutFor(t *testing.T) {
+	SetStatementTimeouts(StatementTimeouts{
+		Default: 30 * time.Second,
+		Tenants: map[string]time.Duration{
+			"analytics":          2 * time.Minute,
+			"10.0.0.5:26257/iot": 10 * time.Second,
+			"iot":                time.Minute,
+		},
+	})
+	defer SetStatementTimeouts(StatementTimeouts{})
+
+	tests := map[string]time.Duration{
+		"postgresql://u:p@db.local:26257/analytics?sslmode=disable": 2 * time.Minute,
+		"postgresql://u:p@10.0.0.5:26257/iot":                       10 * time.Second,
+		"postgresql://u:p@10.0.0.6:26257/iot":                       time.Minute,
+		"postgresql://u:p@10.0.0.5:26257/other":                     30 * time.Second,
+		"host=10.0.0.5 port=26257 dbname=iot user=root":             10 * time.Second,
+		"host=db.local dbname = 'analytics' password='a b\\'c'":     2 * time.Minute,
+		"user=root host=10.0.0.5":                                   30 * time.Second,
+	}
+	for uri, want := range tests {
+		if got := StatementTimeoutFor(uri); got != want {
+			t.Fatalf("StatementTimeoutFor(%q) = %v, want %v", uri, got, want)
+		}
+	}
+}
+
+func TestTenantKeys(t *testing.T) {
+	tests := map[string][]string{
+		"postgresql://u:p@10.0.0.5:26257/iot?sslmode=disable":   {"10.0.0.5:26257/iot", "iot"},
+		"postgres://u@db.local/analytics":                       {"db.local/analytics", "analytics"},
+		"host=10.0.0.5 port=26257 dbname=iot user=root":         {"10.0.0.5:26257/iot", "iot"},
+		"dbname='my db' host=db.local password='it\\'s secret'": {"db.local/my db", "my db"},
+		"postgresql://u:p@10.0.0.5:26257":                       nil,
+		"host=10.0.0.5 port=26257 user=root":                    nil,
+		"host=10.0.0.5 dbname='iot":                             nil,
+		"not a dsn":                                             nil,
+	}
+	for dsn, want := range tests {
+		if got := TenantKeys(dsn); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("TenantKeys(%q) = %q, want %q", dsn, got, want)
+		}
+	}
+}
+
+func TestWithStatementTimeout(t *testing.T) {
+	ctx := context.Background()
+	if got, cancel := WithStatementTimeout(ctx, 0); got != ctx {
+		t.Fatal("a zero timeout should leave the context unchanged")
+	} else {
+		cancel()
+	}
+
+	ctx, cancel := WithStatementTimeout(ctx, time.Millisecond)
+	defer cancel()
+	if statementTimeoutFromContext(ctx) != time.Millisecond {
+		t.Fatal("statement timeout not attached to the context")
+	}
+	<-ctx.Done()
+	if !IsTimeout(ctx, errors.New("pq: canceling statement due to user request")) {
+		t.Fatal("an error after the deadline should be a timeout")
+	}
+	if !IsTimeout(context.Background(), errors.New("pq: query execution canceled due to statement timeout")) {
+		t.Fatal("a server-side statement timeout should be a timeout")
+	}
+	if IsTimeout(context.Background(), errors.New("relation does not exist")) {
+		t.Fatal("other errors are not timeouts")
+	}
+}
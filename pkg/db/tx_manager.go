@@ -0,0 +1,325 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTxIdleTimeout is how long a session transaction may stay unused before it is rolled back.
+const DefaultTxIdleTimeout = 5 * time.Minute
+
+// SessionTx is an explicit transaction pinned to one MCP session.
+// Statements on the transaction are serialized by its mutex.
+type SessionTx struct {
+	mu   sync.Mutex
+	conn *sql.Conn
+	tx   *sql.Tx
+
+	SessionID   string
+	DatabaseURI string
+	Isolation   string
+	ReadOnly    bool
+	StartedAt   time.Time
+
+	lastUsed time.Time
+	timer    *time.Timer
+}
+
+// TxManager keeps at most one open transaction per MCP session.
+type TxManager struct {
+	mu          sync.Mutex
+	txs         map[string]*SessionTx
+	ended       map[string]string
+	idleTimeout time.Duration
+}
+
+var (
+	txManager     *TxManager
+	txManagerOnce sync.Once
+)
+
+// GetTxManager returns the singleton session transaction manager.
+func GetTxManager() *TxManager {
+	txManagerOnce.Do(func() {
+		txManager = &TxManager{
+			txs:         make(map[string]*SessionTx),
+			ended:       make(map[string]string),
+			idleTimeout: DefaultTxIdleTimeout,
+		}
+	})
+	return txManager
+}
+
+// SetIdleTimeout changes the idle timeout applied to transactions begun afterwards.
+func (m *TxManager) SetIdleTimeout(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if d <= 0 {
+		d = DefaultTxIdleTimeout
+	}
+	m.idleTimeout = d
+}
+
+// ParseIsolationLevel maps an isolation level name such as "serializable" or "read committed".
+// An empty name selects the database default.
+func ParseIsolationLevel(name string) (sql.IsolationLevel, error) {
+	normalized := strings.ToLower(strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return r == ' ' || r == '_' || r == '-'
+	}), " "))
+	switch normalized {
+	case "":
+		return sql.LevelDefault, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	case "repeatable read":
+		return sql.LevelRepeatableRead, nil
+	case "read committed":
+		return sql.LevelReadCommitted, nil
+	case "read uncommitted":
+		return sql.LevelReadUncommitted, nil
+	default:
+		return sql.LevelDefault, fmt.Errorf("unsupported isolation level %q: use serializable, repeatable read, read committed or read uncommitted", name)
+	}
+}
+
+// Begin opens a transaction for sessionID on a dedicated connection.
+// connectionString selects a tenant database; when empty the default pool is used.
+// Acquiring the connection and beginning the transaction are bounded by ctx, but the open
+// transaction outlives it.
+func (m *TxManager) Begin(ctx context.Context, sessionID, connectionString, isolation string, readOnly bool) (*SessionTx, error) {
+	if sessionID == "" {
+		return nil, fmt.Errorf("transactions require an MCP session")
+	}
+	level, err := ParseIsolationLevel(isolation)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if _, exists := m.txs[sessionID]; exists {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("session already has an open transaction")
+	}
+	delete(m.ended, sessionID)
+	idleTimeout := m.idleTimeout
+	m.mu.Unlock()
+
+	var conn *sql.Conn
+	err = withDB(ctx, connectionString, func(db *sql.DB) error {
+		var err error
+		if conn, err = db.Conn(ctx); err != nil {
+			return fmt.Errorf("failed to acquire connection: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 事务在多次工具调用之间存活，而 database/sql 会在 BeginTx 的 context 取消时回滚事务，
+	// 因此事务使用与本次调用脱离的 context；只有 BEGIN 执行期间本次调用被取消时才取消它。
+	txCtx, cancelTx := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, cancelTx)
+	tx, err := conn.BeginTx(txCtx, &sql.TxOptions{Isolation: level, ReadOnly: readOnly})
+	if !stop() {
+		// The call ended while BEGIN ran; the cancelled context has rolled the transaction back.
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	now := time.Now()
+	stx := &SessionTx{
+		conn:        conn,
+		tx:          tx,
+		SessionID:   sessionID,
+		DatabaseURI: connectionString,
+		Isolation:   level.String(),
+		ReadOnly:    readOnly,
+		StartedAt:   now,
+		lastUsed:    now,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.txs[sessionID]; exists {
+		_ = tx.Rollback()
+		conn.Close()
+		return nil, fmt.Errorf("session already has an open transaction")
+	}
+	stx.mu.Lock()
+	stx.timer = time.AfterFunc(idleTimeout, func() {
+		m.expire(stx, idleTimeout)
+	})
+	stx.mu.Unlock()
+	m.txs[sessionID] = stx
+	return stx, nil
+}
+
+// Get returns the open transaction of sessionID, or nil when there is none.
+func (m *TxManager) Get(sessionID string) *SessionTx {
+	if sessionID == "" {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.txs[sessionID]
+}
+
+// Commit commits and closes the transaction of sessionID, which must have been begun on
+// connectionString.
+func (m *TxManager) Commit(sessionID, connectionString string) (*SessionTx, error) {
+	stx, err := m.detach(sessionID, connectionString)
+	if err != nil {
+		return nil, err
+	}
+	return stx, stx.finish(true)
+}
+
+// Rollback rolls back and closes the transaction of sessionID, which must have been begun on
+// connectionString.
+func (m *TxManager) Rollback(sessionID, connectionString string) (*SessionTx, error) {
+	stx, err := m.detach(sessionID, connectionString)
+	if err != nil {
+		return nil, err
+	}
+	return stx, stx.finish(false)
+}
+
+// CloseSession rolls back the transaction of a session that has ended, if it has one.
+func (m *TxManager) CloseSession(sessionID string) {
+	m.mu.Lock()
+	stx := m.txs[sessionID]
+	delete(m.txs, sessionID)
+	delete(m.ended, sessionID)
+	m.mu.Unlock()
+
+	if stx != nil {
+		if err := stx.finish(false); err != nil {
+			log.Printf("Failed to roll back transaction of closed session %s: %v", sessionID, err)
+		}
+	}
+}
+
+// RollbackAll rolls back every open transaction, e.g. on shutdown.
+func (m *TxManager) RollbackAll() {
+	m.mu.Lock()
+	txs := m.txs
+	m.txs = make(map[string]*SessionTx)
+	m.ended = make(map[string]string)
+	m.mu.Unlock()
+
+	for _, stx := range txs {
+		_ = stx.finish(false)
+	}
+}
+
+func (m *TxManager) detach(sessionID, connectionString string) (*SessionTx, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stx, ok := m.txs[sessionID]
+	if !ok {
+		if reason := m.ended[sessionID]; reason != "" {
+			delete(m.ended, sessionID)
+			return nil, fmt.Errorf("no open transaction: the last transaction was %s", reason)
+		}
+		return nil, fmt.Errorf("no open transaction for this session")
+	}
+	// 只能在开启事务的数据库上结束事务，与其他工具的 X-Database-URI 检查一致
+	if stx.DatabaseURI != connectionString {
+		return nil, fmt.Errorf("this session's open transaction is on another database; use the same X-Database-URI as begin-transaction")
+	}
+	delete(m.txs, sessionID)
+	return stx, nil
+}
+
+// expire rolls back stx if it has been idle for idleTimeout.
+func (m *TxManager) expire(stx *SessionTx, idleTimeout time.Duration) {
+	stx.mu.Lock()
+	if idle := time.Since(stx.lastUsed); idle < idleTimeout {
+		stx.timer.Reset(idleTimeout - idle)
+		stx.mu.Unlock()
+		return
+	}
+	stx.mu.Unlock()
+
+	m.abort(stx, fmt.Sprintf("rolled back after being idle for %s", idleTimeout))
+}
+
+// abort rolls back stx if it is still open and remembers why, so that the next commit or
+// rollback of the session can report it.
+func (m *TxManager) abort(stx *SessionTx, reason string) {
+	m.mu.Lock()
+	if m.txs[stx.SessionID] != stx {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.txs, stx.SessionID)
+	m.ended[stx.SessionID] = reason
+	m.mu.Unlock()
+
+	log.Printf("Transaction of session %s %s", stx.SessionID, reason)
+	_ = stx.finish(false)
+}
+
+// finish commits or rolls back the transaction and releases its connection.
+func (stx *SessionTx) finish(commit bool) error {
+	stx.mu.Lock()
+	defer stx.mu.Unlock()
+	if stx.timer != nil {
+		stx.timer.Stop()
+	}
+	defer stx.conn.Close()
+
+	if commit {
+		if err := stx.tx.Commit(); err != nil {
+			return fmt.Errorf("commit failed: %v", err)
+		}
+		return nil
+	}
+	if err := stx.tx.Rollback(); err != nil && err != sql.ErrTxDone {
+		return fmt.Errorf("rollback failed: %v", err)
+	}
+	return nil
+}
+
+// run executes fn on the transaction while holding its lock and refreshes its idle timer.
+// A statement cancelled through ctx leaves the transaction aborted on the server, so the
+// transaction is rolled back and its connection released.
+func (stx *SessionTx) run(ctx context.Context, fn func(*sql.Tx) error) error {
+	err := stx.exec(fn)
+	if ctx.Err() != nil {
+		GetTxManager().abort(stx, "rolled back because a statement was cancelled")
+	}
+	return err
+}
+
+func (stx *SessionTx) exec(fn func(*sql.Tx) error) error {
+	stx.mu.Lock()
+	defer stx.mu.Unlock()
+	stx.lastUsed = time.Now()
+	defer func() { stx.lastUsed = time.Now() }()
+	return fn(stx.tx)
+}
+
+type sessionTxKey struct{}
+
+// WithSessionTx returns a context whose statements run on stx instead of a pooled connection.
+func WithSessionTx(ctx context.Context, stx *SessionTx) context.Context {
+	return context.WithValue(ctx, sessionTxKey{}, stx)
+}
+
+// sessionTxFromContext returns the session transaction attached to ctx, if any.
+func sessionTxFromContext(ctx context.Context) *SessionTx {
+	stx, _ := ctx.Value(sessionTxKey{}).(*SessionTx)
+	return stx
+}
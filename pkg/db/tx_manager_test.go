@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestParseIsolationLevel(t *testing.T) {
+	tests := map[string]sql.IsolationLevel{
+		"":                 sql.LevelDefault,
+		"SERIALIZABLE":     sql.LevelSerializable,
+		"read committed":   sql.LevelReadCommitted,
+		"read_committed":   sql.LevelReadCommitted,
+		"Repeatable-Read":  sql.LevelRepeatableRead,
+		"read uncommitted": sql.LevelReadUncommitted,
+	}
+	for name, want := range tests {
+		got, err := ParseIsolationLevel(name)
+		if err != nil || got != want {
+			t.Fatalf("ParseIsolationLevel(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+
+	if _, err := ParseIsolationLevel("snapshot-ish"); err == nil {
+		t.Fatal("unknown isolation level should fail")
+	}
+}
+
+func TestTxManagerWithoutTransaction(t *testing.T) {
+	m := GetTxManager()
+
+	if _, err := m.Begin(context.Background(), "", "", "", false); err == nil || !strings.Contains(err.Error(), "MCP session") {
+		t.Fatalf("Begin without session should fail, got %v", err)
+	}
+	if _, err := m.Begin(context.Background(), "s1", "", "bogus", false); err == nil {
+		t.Fatal("Begin with an invalid isolation level should fail")
+	}
+	if m.Get("s1") != nil {
+		t.Fatal("no transaction should be open")
+	}
+	if _, err := m.Commit("s1", ""); err == nil {
+		t.Fatal("Commit without a transaction should fail")
+	}
+	if _, err := m.Rollback("s1", ""); err == nil {
+		t.Fatal("Rollback without a transaction should fail")
+	}
+
+	// Closing a session without a transaction is a no-op.
+	m.CloseSession("s1")
+}
+
+func TestReadQueryInReadWriteTransaction(t *testing.T) {
+	// The check runs before the transaction is used, so no connection is needed.
+	ctx := WithSessionTx(context.Background(), &SessionTx{SessionID: "s1"})
+	_, err := ExecuteQueryWithOptions(ctx, "", "SELECT purge_old_rows(30)", QueryOptions{})
+	if err == nil || !strings.Contains(err.Error(), "purge_old_rows") {
+		t.Fatalf("a user function should be refused in a read-write transaction, got %v", err)
+	}
+}
+
+func TestTxManagerEndsOnlyOnItsDatabase(t *testing.T) {
+	const uri = "postgresql://fake/tx"
+	useFakePool(t, uri, openFakeDB(t, nil))
+	m := GetTxManager()
+
+	if _, err := m.Begin(context.Background(), "s2", uri, "", false); err != nil {
+		t.Fatal(err)
+	}
+	defer m.CloseSession("s2")
+	if _, err := m.Commit("s2", "postgresql://fake/other"); err == nil || !strings.Contains(err.Error(), "another database") {
+		t.Fatalf("Commit with another database should fail, got %v", err)
+	}
+	if _, err := m.Rollback("s2", ""); err == nil {
+		t.Fatal("Rollback on the default database should fail")
+	}
+	if m.Get("s2") == nil {
+		t.Fatal("the transaction should stay open after a refused commit")
+	}
+	if _, err := m.Rollback("s2", uri); err != nil {
+		t.Fatalf("Rollback returned error: %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// WriteOptions controls how a write statement is executed.
+type WriteOptions struct {
+	// DryRun executes the statement in a transaction that is always rolled back.
+	DryRun bool
+	// Rows bounds the rows returned by a RETURNING clause or shown as the dry-run preview.
+	Rows QueryOptions
+}
+
+// WriteResult reports the outcome of a write statement.
+type WriteResult struct {
+	AffectedRows int64
+	// Returned holds the rows produced by the RETURNING clause of the statement, if it has one.
+	Returned *ResultSet
+	// DryRun is true when the statement was rolled back.
+	DryRun bool
+	// Preview holds the rows changed by a dry run, when RETURNING * could be appended.
+	Preview *ResultSet
+	// PreviewQuery is the statement that produced Preview.
+	PreviewQuery string
+	// PreviewError explains why a dry run has no preview, when appending RETURNING * failed.
+	PreviewError string
+}
+
+// ExecuteWriteQueryWithOptions executes a single write statement on the session transaction
+// attached to ctx, or else on the pool selected by connectionString.
+//
+// A statement with a RETURNING clause runs through the query path so the returned rows reach
+// the caller; AffectedRows then counts every returned row, even beyond opts.Rows.
+//
+// With DryRun set, only DML is accepted: the statement runs in its own transaction that is
+// always rolled back, with RETURNING * appended where possible to preview the changed rows.
+// DDL, DCL and admin statements are refused because KWDB cannot reliably roll them back.
+func ExecuteWriteQueryWithOptions(ctx context.Context, connectionString, query string, opts WriteOptions, args ...interface{}) (*WriteResult, error) {
+	// 检查查询类型
+	stmt, err := parseWriteStatement(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun {
+		return dryRunWrite(ctx, connectionString, stmt, opts.Rows, args...)
+	}
+
+	result := &WriteResult{}
+	err = withQuerier(ctx, connectionString, func(q querier) error {
+		if stmt.HasReturning() {
+			set, err := queryReturning(ctx, q, query, opts.Rows, args...)
+			if err != nil {
+				return err
+			}
+			result.AffectedRows = int64(set.RowsScanned)
+			result.Returned = set
+			return nil
+		}
+		return execWrite(ctx, q, query, result, args...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// queryReturning runs a write statement with a RETURNING clause and scans every returned row,
+// keeping at most the rows allowed by opts.
+func queryReturning(ctx context.Context, q querier, query string, opts QueryOptions, args ...interface{}) (*ResultSet, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("write operation failed: %v", err)
+	}
+	defer rows.Close()
+
+	opts.CountAll = true
+	set, err := scanResultSet(rows, opts)
+	if err != nil {
+		return nil, fmt.Errorf("write operation failed: %v", err)
+	}
+	return set, nil
+}
+
+// execWrite runs a write statement without a result set and records its affected rows.
+func execWrite(ctx context.Context, q querier, query string, result *WriteResult, args ...interface{}) error {
+	res, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("write operation failed: %v", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %v", err)
+	}
+
+	result.AffectedRows = affected
+	return nil
+}
+
+// dryRunWrite runs stmt in a transaction that is always rolled back.
+func dryRunWrite(ctx context.Context, connectionString string, stmt *Statement, preview QueryOptions, args ...interface{}) (*WriteResult, error) {
+	if stmt.Category != CategoryDML {
+		return nil, fmt.Errorf("dry_run is not supported for %s statements (%s): KWDB cannot roll them back reliably", stmt.Category, stmt.Keyword)
+	}
+	if sessionTxFromContext(ctx) != nil {
+		return nil, fmt.Errorf("dry_run is not supported inside a session transaction; roll back the transaction instead")
+	}
+
+	result := &WriteResult{DryRun: true}
+	previewQuery, appended := stmt.WithReturningAll()
+	withPreview := appended || stmt.HasReturning()
+	rolledBack := func(fn func(tx *sql.Tx) error) error {
+		return withConn(ctx, connectionString, func(conn *sql.Conn) error {
+			tx, err := conn.BeginTx(ctx, nil)
+			if err != nil {
+				return fmt.Errorf("failed to begin transaction: %v", err)
+			}
+			// 无论成功与否，dry run 的事务都必须回滚
+			defer tx.Rollback()
+			return fn(tx)
+		})
+	}
+
+	if !withPreview {
+		if err := rolledBack(func(tx *sql.Tx) error { return execWrite(ctx, tx, stmt.SQL, result, args...) }); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	err := rolledBack(func(tx *sql.Tx) error {
+		set, err := queryReturning(ctx, tx, previewQuery, preview, args...)
+		if err != nil {
+			return err
+		}
+		result.AffectedRows = int64(set.RowsScanned)
+		result.Preview = set
+		result.PreviewQuery = previewQuery
+		return nil
+	})
+	if err == nil {
+		return result, nil
+	}
+	if !appended || ctx.Err() != nil {
+		return nil, err
+	}
+	// 追加的 RETURNING * 可能不被该语句支持：在新事务中不带预览重试，只报告影响行数
+	if retryErr := rolledBack(func(tx *sql.Tx) error { return execWrite(ctx, tx, stmt.SQL, result, args...) }); retryErr != nil {
+		return nil, retryErr
+	}
+	result.PreviewError = fmt.Sprintf("preview with %s failed: %v", previewQuery, err)
+	return result, nil
+}
+
+// precheckSavepoint is the savepoint PrecheckAffectedRows uses inside a session transaction.
+const precheckSavepoint = "kwdb_mcp_precheck"
+
+// PrecheckAffectedRows runs a single DML statement and undoes it, returning the number of rows
+// it would change. Outside a session transaction it runs in its own transaction that is always
+// rolled back; inside one it runs under a savepoint that is rolled back afterwards, and the
+// session transaction is rolled back as a whole if the savepoint cannot be.
+func PrecheckAffectedRows(ctx context.Context, connectionString, query string, args ...interface{}) (int64, error) {
+	stmt, err := parseWriteStatement(query)
+	if err != nil {
+		return 0, err
+	}
+	if stmt.Category != CategoryDML {
+		return 0, fmt.Errorf("affected rows can only be checked for DML statements, got %s", stmt.Keyword)
+	}
+
+	result := &WriteResult{}
+	if stx := sessionTxFromContext(ctx); stx != nil {
+		undone := false
+		err = stx.run(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, "SAVEPOINT "+precheckSavepoint); err != nil {
+				return fmt.Errorf("failed to create savepoint: %v", err)
+			}
+			execErr := execWrite(ctx, tx, stmt.SQL, result, args...)
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+precheckSavepoint); err != nil {
+				return fmt.Errorf("failed to roll back savepoint: %v", err)
+			}
+			undone = true
+			if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+precheckSavepoint); err != nil {
+				return fmt.Errorf("failed to release savepoint: %v", err)
+			}
+			return execErr
+		})
+		if !undone {
+			GetTxManager().abort(stx, "rolled back because an affected rows pre-check could not be undone")
+		}
+		if err != nil {
+			return 0, err
+		}
+		return result.AffectedRows, nil
+	}
+
+	err = withConn(ctx, connectionString, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %v", err)
+		}
+		// 预检查的事务必须回滚
+		defer tx.Rollback()
+		return execWrite(ctx, tx, stmt.SQL, result, args...)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.AffectedRows, nil
+}
+
+// EstimateTableRows returns the row count recorded by the most recent statistics of a table,
+// and false when the table has no statistics. It runs on a pooled connection even when ctx
+// carries a session transaction, so a failed lookup cannot abort that transaction.
+func EstimateTableRows(ctx context.Context, connectionString, database, table string) (int64, bool, error) {
+	name := pq.QuoteIdentifier(table)
+	if database != "" {
+		name = pq.QuoteIdentifier(database) + "." + name
+	}
+	query := fmt.Sprintf("SELECT row_count FROM [SHOW STATISTICS FOR TABLE %s] ORDER BY created DESC LIMIT 1", name)
+
+	var rows sql.NullInt64
+	err := withConn(ctx, connectionString, func(conn *sql.Conn) error {
+		return conn.QueryRowContext(ctx, query).Scan(&rows)
+	})
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read table statistics: %v", err)
+	}
+	return rows.Int64, rows.Valid, nil
+}
+
+// AffectedRowsError is returned when a statement changes more rows than the limit attached to
+// its context with WithMaxAffectedRows.
+type AffectedRowsError struct {
+	Keyword  string
+	Affected int64
+	Limit    int64
+}
+
+func (e *AffectedRowsError) Error() string {
+	return fmt.Sprintf("%s changed %d rows, more than the limit of %d", e.Keyword, e.Affected, e.Limit)
+}
+
+type maxAffectedRowsKey struct{}
+
+// WithMaxAffectedRows returns a context whose script DML statements may change at most limit
+// rows each; a statement over the limit fails the script with an *AffectedRowsError.
+// A non-positive limit returns ctx unchanged.
+func WithMaxAffectedRows(ctx context.Context, limit int64) context.Context {
+	if limit <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, maxAffectedRowsKey{}, limit)
+}
+
+// checkAffectedRows enforces the limit attached to ctx on a DML statement.
+func checkAffectedRows(ctx context.Context, stmt Statement, affected int64) error {
+	limit, _ := ctx.Value(maxAffectedRowsKey{}).(int64)
+	if limit <= 0 || stmt.Category != CategoryDML || affected <= limit {
+		return nil
+	}
+	return &AffectedRowsError{Keyword: stmt.Keyword, Affected: affected, Limit: limit}
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWithReturningAll(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+		ok   bool
+	}{
+		{"DELETE FROM t WHERE id = $1 -- cleanup", "DELETE FROM t WHERE id = $1 RETURNING *", true},
+		{"UPDATE t SET v = (SELECT max(v) FROM u) WHERE id = 1;", "UPDATE t SET v = (SELECT max(v) FROM u) WHERE id = 1 RETURNING *", true},
+		{"INSERT INTO t VALUES (1) RETURNING id", "INSERT INTO t VALUES (1) RETURNING id", false},
+		{"WITH x AS (SELECT 1) DELETE FROM t", "WITH x AS (SELECT 1) DELETE FROM t", false},
+		{"CREATE TABLE t (id INT)", "CREATE TABLE t (id INT)", false},
+	}
+
+	for _, tt := range tests {
+		statements, err := SplitStatements(tt.sql)
+		if err != nil || len(statements) != 1 {
+			t.Fatalf("SplitStatements(%q) = %v, %v", tt.sql, statements, err)
+		}
+		got, ok := statements[0].WithReturningAll()
+		if got != tt.want || ok != tt.ok {
+			t.Fatalf("WithReturningAll(%q) = %q, %v; want %q, %v", tt.sql, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestHasReturning(t *testing.T) {
+	statements, _ := SplitStatements("INSERT INTO t SELECT * FROM (SELECT 'RETURNING' AS returning) AS s")
+	if statements[0].HasReturning() {
+		t.Fatal("RETURNING inside a subquery or literal should not count")
+	}
+	statements, _ = SplitStatements("update t set v = 1 returning v")
+	if !statements[0].HasReturning() {
+		t.Fatal("top-level RETURNING should be detected")
+	}
+}
+
+func TestDryRunRefusesNonDML(t *testing.T) {
+	for _, sql := range []string{"DROP TABLE t", "GRANT SELECT ON t TO bob", "SET CLUSTER SETTING a = 1"} {
+		_, err := ExecuteWriteQueryWithOptions(context.Background(), "", sql, WriteOptions{DryRun: true})
+		if err == nil || !strings.Contains(err.Error(), "dry_run is not supported") {
+			t.Fatalf("dry run of %q should be refused, got %v", sql, err)
+		}
+	}
+}
+
+func TestPrecheckAffectedRowsRefusesNonDML(t *testing.T) {
+	_, err := PrecheckAffectedRows(context.Background(), "", "DROP TABLE t")
+	if err == nil || !strings.Contains(err.Error(), "only be checked for DML") {
+		t.Fatalf("pre-check of DDL should be refused, got %v", err)
+	}
+}
+
+func TestCheckAffectedRows(t *testing.T) {
+	statements, _ := SplitStatements("DELETE FROM t; CREATE TABLE u (a INT)")
+	if err := checkAffectedRows(context.Background(), statements[0], 1000); err != nil {
+		t.Fatalf("no limit should allow any count, got %v", err)
+	}
+
+	ctx := WithMaxAffectedRows(context.Background(), 10)
+	if err := checkAffectedRows(ctx, statements[0], 10); err != nil {
+		t.Fatalf("10 rows should be within the limit, got %v", err)
+	}
+	var limitErr *AffectedRowsError
+	if err := checkAffectedRows(ctx, statements[0], 11); !errors.As(err, &limitErr) || limitErr.Affected != 11 {
+		t.Fatalf("expected AffectedRowsError, got %v", err)
+	}
+	if err := checkAffectedRows(ctx, statements[1], 11); err != nil {
+		t.Fatalf("the limit only applies to DML, got %v", err)
+	}
+}
+
+func TestDryRunWithoutPreview(t *testing.T) {
+	const uri = "postgresql://fake/dryrun"
+	useFakePool(t, uri, openFakeDB(t, map[string]fakeResult{
+		"DELETE FROM t WHERE v < 10 RETURNING *": {err: errors.New("RETURNING is not supported on this table")},
+		"DELETE FROM t WHERE v < 10":             {affected: 7},
+		"DELETE FROM t RETURNING id": {
+			columns: []string{"id"},
+			types:   []string{"INT8"},
+			rows:    [][]driver.Value{{int64(1)}},
+		},
+	}))
+
+	// When the appended RETURNING * fails, the statement is retried without it and only counted.
+	result, err := ExecuteWriteQueryWithOptions(context.Background(), uri, "DELETE FROM t WHERE v < 10", WriteOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.AffectedRows != 7 || result.Preview != nil || !strings.Contains(result.PreviewError, "RETURNING is not supported") {
+		t.Fatalf("unexpected dry run result %+v", result)
+	}
+
+	// A RETURNING clause of the statement's own is used as the preview.
+	result, err = ExecuteWriteQueryWithOptions(context.Background(), uri, "DELETE FROM t RETURNING id", WriteOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.AffectedRows != 1 || result.Preview == nil || result.PreviewError != "" {
+		t.Fatalf("unexpected dry run result %+v", result)
+	}
+}
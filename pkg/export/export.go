@@ -0,0 +1,412 @@
+// Package export writes query results to files in the export directory and finds them again
+// by ID, so clients can fetch them as MCP resources.
+package export
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+)
+
+// Export file formats.
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+// Formats lists the supported formats, the default first.
+var Formats = []string{FormatCSV, FormatNDJSON, FormatParquet}
+
+// mimeTypes maps each format to the MIME type of its files.
+var mimeTypes = map[string]string{
+	FormatCSV:     "text/csv",
+	FormatNDJSON:  "application/x-ndjson",
+	FormatParquet: "application/vnd.apache.parquet",
+}
+
+// URIPrefix starts the resource URI of an export; the export ID follows it.
+const URIPrefix = "kwdb://export/"
+
+// validID matches the IDs generated by Create, so an ID can be used as a file name.
+var validID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// MIMEType returns the MIME type of files in format.
+func MIMEType(format string) string {
+	return mimeTypes[format]
+}
+
+// IsText reports whether files in format are text.
+func IsText(format string) bool {
+	return format == FormatCSV || format == FormatNDJSON
+}
+
+// Options bounds the files of a store.
+type Options struct {
+	// TTL is how long an export is kept after it is written (default 24 hours).
+	TTL time.Duration
+	// MaxBytes caps the size of one export file; the export fails when it is reached (default 1 GiB).
+	MaxBytes int64
+}
+
+func (o Options) withDefaults() Options {
+	if o.TTL <= 0 {
+		o.TTL = 24 * time.Hour
+	}
+	if o.MaxBytes <= 0 {
+		o.MaxBytes = 1 << 30
+	}
+	return o
+}
+
+// Store keeps exported files in one directory. Files are named by export ID and format, so
+// they can be found again after a restart. Each export belongs to the database it was read
+// from, recorded in a hidden owner file next to it, and is deleted once it expires.
+type Store struct {
+	dir  string
+	opts Options
+	now  func() time.Time
+}
+
+// NewStore returns a store for dir, which must be an existing directory.
+func NewStore(dir string, opts Options) (*Store, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid export directory: %v", err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("export directory %s is not a directory", dir)
+	}
+	return &Store{dir: abs, opts: opts.withDefaults(), now: time.Now}, nil
+}
+
+// File describes a finished export.
+type File struct {
+	ID      string      `json:"id"`
+	URI     string      `json:"uri"`
+	Path    string      `json:"path"`
+	Format  string      `json:"format"`
+	Columns []db.Column `json:"columns"`
+	Rows    int64       `json:"row_count"`
+	Bytes   int64       `json:"bytes"`
+	// SHA256 is the hex digest of the file content.
+	SHA256   string `json:"sha256"`
+	MIMEType string `json:"mime_type"`
+	// ExpiresAt is when the store deletes the file.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Export is a file being written. It is written under a temporary name and only appears
+// under its final name after Commit.
+type Export struct {
+	store   *Store
+	owner   string
+	file    *File
+	tmp     *os.File
+	buf     *bufio.Writer
+	counter *countingWriter
+	hash    hash.Hash
+	writer  rowWriter
+}
+
+// Create starts a new export in format for owner, the database URI the rows are read from.
+// Expired exports are deleted first.
+func (s *Store) Create(format, owner string) (*Export, error) {
+	if _, ok := mimeTypes[format]; !ok {
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+	s.removeExpired()
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate export ID: %v", err)
+	}
+	id := hex.EncodeToString(raw)
+
+	tmp, err := os.CreateTemp(s.dir, "."+id+"-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export file: %v", err)
+	}
+	e := &Export{
+		store: s,
+		owner: owner,
+		file: &File{
+			ID:       id,
+			URI:      URIPrefix + id,
+			Path:     filepath.Join(s.dir, id+"."+format),
+			Format:   format,
+			MIMEType: mimeTypes[format],
+		},
+		tmp:  tmp,
+		hash: sha256.New(),
+	}
+	e.counter = &countingWriter{w: io.MultiWriter(tmp, e.hash)}
+	e.buf = bufio.NewWriterSize(e.counter, 64*1024)
+	return e, nil
+}
+
+// Begin writes the start of the file for columns. It must be called before WriteRow.
+func (e *Export) Begin(columns []db.Column) error {
+	e.file.Columns = columns
+	switch e.file.Format {
+	case FormatCSV:
+		e.writer = newCSVWriter(e.buf, columns)
+	case FormatNDJSON:
+		e.writer = &ndjsonWriter{w: e.buf, columns: columns}
+	case FormatParquet:
+		e.writer = newParquetWriter(e.buf, columns)
+	}
+	return e.writer.begin()
+}
+
+// WriteRow appends one row of values encoded by db.ValueCodec.
+func (e *Export) WriteRow(values []interface{}) error {
+	if err := e.writer.writeRow(values); err != nil {
+		return fmt.Errorf("failed to write export row: %v", err)
+	}
+	e.file.Rows++
+	// Parquet 按行组缓冲，此处只能发现已写出的超限，Commit 时再检查最终大小
+	if e.counter.n+int64(e.buf.Buffered()) > e.store.opts.MaxBytes {
+		return e.tooLarge()
+	}
+	return nil
+}
+
+func (e *Export) tooLarge() error {
+	return fmt.Errorf("export exceeds the %d-byte file size limit", e.store.opts.MaxBytes)
+}
+
+// Commit finishes the file and moves it to its final name.
+func (e *Export) Commit() (*File, error) {
+	if e.writer == nil {
+		e.Abort()
+		return nil, fmt.Errorf("export was not started")
+	}
+	if err := e.writer.close(); err != nil {
+		e.Abort()
+		return nil, fmt.Errorf("failed to finish export file: %v", err)
+	}
+	if err := e.buf.Flush(); err != nil {
+		e.Abort()
+		return nil, fmt.Errorf("failed to write export file: %v", err)
+	}
+	if e.counter.n > e.store.opts.MaxBytes {
+		e.Abort()
+		return nil, e.tooLarge()
+	}
+	if err := e.tmp.Close(); err != nil {
+		os.Remove(e.tmp.Name())
+		return nil, fmt.Errorf("failed to write export file: %v", err)
+	}
+	// 先写归属文件：没有归属文件的导出无法被读取
+	ownerPath := e.store.ownerPath(e.file.ID)
+	if err := os.WriteFile(ownerPath, []byte(ownerDigest(e.owner)), 0o600); err != nil {
+		os.Remove(e.tmp.Name())
+		return nil, fmt.Errorf("failed to write export owner: %v", err)
+	}
+	if err := os.Rename(e.tmp.Name(), e.file.Path); err != nil {
+		os.Remove(e.tmp.Name())
+		os.Remove(ownerPath)
+		return nil, fmt.Errorf("failed to move export file: %v", err)
+	}
+	e.file.Bytes = e.counter.n
+	e.file.SHA256 = hex.EncodeToString(e.hash.Sum(nil))
+	e.file.ExpiresAt = e.store.now().Add(e.store.opts.TTL)
+	return e.file, nil
+}
+
+// Abort discards the export.
+func (e *Export) Abort() {
+	e.tmp.Close()
+	os.Remove(e.tmp.Name())
+}
+
+// Open finds the export with id and returns its path and format. Only the owner the export
+// was created for can open it, and only until it expires.
+func (s *Store) Open(id, owner string) (string, string, error) {
+	if !validID.MatchString(id) {
+		return "", "", fmt.Errorf("invalid export ID %q", id)
+	}
+	for _, format := range Formats {
+		path := filepath.Join(s.dir, id+"."+format)
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if s.expired(info) {
+			s.remove(id, path)
+			break
+		}
+		digest, err := os.ReadFile(s.ownerPath(id))
+		if err != nil || string(digest) != ownerDigest(owner) {
+			break
+		}
+		return path, format, nil
+	}
+	return "", "", fmt.Errorf("export %s not found or expired", id)
+}
+
+// ownerPath is the hidden file holding the owner digest of the export with id.
+func (s *Store) ownerPath(id string) string {
+	return filepath.Join(s.dir, "."+id+".owner")
+}
+
+// ownerDigest hashes owner, so that database URIs and their passwords are not written to disk.
+func ownerDigest(owner string) string {
+	sum := sha256.Sum256([]byte(owner))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Store) expired(info os.FileInfo) bool {
+	return !s.now().Before(info.ModTime().Add(s.opts.TTL))
+}
+
+func (s *Store) remove(id, path string) {
+	os.Remove(path)
+	os.Remove(s.ownerPath(id))
+}
+
+// removeExpired deletes the exports whose TTL has passed.
+func (s *Store) removeExpired() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		id, format, ok := strings.Cut(entry.Name(), ".")
+		if !ok || !validID.MatchString(id) || mimeTypes[format] == "" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || !s.expired(info) {
+			continue
+		}
+		s.remove(id, filepath.Join(s.dir, entry.Name()))
+	}
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// rowWriter encodes rows in one format.
+type rowWriter interface {
+	begin() error
+	writeRow(values []interface{}) error
+	close() error
+}
+
+// csvWriter writes a header row and then one record per row. NULL is an empty field.
+type csvWriter struct {
+	w       *csv.Writer
+	columns []db.Column
+	record  []string
+}
+
+func newCSVWriter(w io.Writer, columns []db.Column) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w), columns: columns, record: make([]string, len(columns))}
+}
+
+func (c *csvWriter) begin() error {
+	for i, col := range c.columns {
+		c.record[i] = col.Name
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) writeRow(values []interface{}) error {
+	for i, value := range values {
+		c.record[i] = TextValue(value)
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonWriter writes one JSON object per row, keeping column order and duplicate names.
+type ndjsonWriter struct {
+	w       io.Writer
+	columns []db.Column
+	names   [][]byte
+}
+
+func (n *ndjsonWriter) begin() error {
+	n.names = make([][]byte, len(n.columns))
+	for i, col := range n.columns {
+		name, err := json.Marshal(col.Name)
+		if err != nil {
+			return err
+		}
+		n.names[i] = name
+	}
+	return nil
+}
+
+func (n *ndjsonWriter) writeRow(values []interface{}) error {
+	line := []byte{'{'}
+	for i, value := range values {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		line = append(line, n.names[i]...)
+		line = append(line, ':')
+		line = append(line, encoded...)
+	}
+	line = append(line, '}', '\n')
+	_, err := n.w.Write(line)
+	return err
+}
+
+func (n *ndjsonWriter) close() error {
+	return nil
+}
+
+// TextValue renders an encoded value as text: strings as they are, JSON values and arrays
+// as JSON, and NULL as the empty string.
+func TextValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.RawMessage:
+		return string(v)
+	case []interface{}, map[string]interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(value)
+}
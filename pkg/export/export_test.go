@@ -0,0 +1,183 @@
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+)
+
+var testColumns = []db.Column{
+	{Name: "id", Type: "INT8"},
+	{Name: "name", Type: "VARCHAR"},
+	{Name: "attrs", Type: "JSONB"},
+}
+
+// testOwner is the database URI the test exports belong to.
+const testOwner = "postgresql://root@localhost:26257/defaultdb"
+
+var testRows = [][]interface{}{
+	{int64(1), "a,b", json.RawMessage(`{"k":1}`)},
+	{int64(2), nil, nil},
+}
+
+// writeExport writes testRows in format and returns the finished file.
+func writeExport(t *testing.T, store *Store, format string) *File {
+	t.Helper()
+	e, err := store.Create(format, testOwner)
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if err := e.Begin(testColumns); err != nil {
+		t.Fatalf("Begin returned error: %v", err)
+	}
+	for _, row := range testRows {
+		if err := e.WriteRow(row); err != nil {
+			t.Fatalf("WriteRow returned error: %v", err)
+		}
+	}
+	file, err := e.Commit()
+	if err != nil {
+		t.Fatalf("Commit returned error: %v", err)
+	}
+	return file
+}
+
+func TestExportCSVAndNDJSON(t *testing.T) {
+	store, err := NewStore(t.TempDir(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		FormatCSV:    "id,name,attrs\n1,\"a,b\",\"{\"\"k\"\":1}\"\n2,,\n",
+		FormatNDJSON: "{\"id\":1,\"name\":\"a,b\",\"attrs\":{\"k\":1}}\n{\"id\":2,\"name\":null,\"attrs\":null}\n",
+	}
+	for format, want := range tests {
+		file := writeExport(t, store, format)
+		content, err := os.ReadFile(file.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != want {
+			t.Errorf("%s export:\n%s\nwant:\n%s", format, content, want)
+		}
+		sum := sha256.Sum256(content)
+		if file.Rows != 2 || file.Bytes != int64(len(content)) || file.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("unexpected %s file metadata %+v", format, file)
+		}
+		if file.URI != URIPrefix+file.ID || file.MIMEType != MIMEType(format) {
+			t.Errorf("unexpected %s file URI or MIME type %+v", format, file)
+		}
+
+		path, gotFormat, err := store.Open(file.ID, testOwner)
+		if err != nil || path != file.Path || gotFormat != format {
+			t.Errorf("Open(%s) = %q, %q, %v", file.ID, path, gotFormat, err)
+		}
+	}
+}
+
+func TestStoreOpenAndAbort(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"", "../secret", "0123456789abcdef0123456789abcdeg", "0123456789abcdef0123456789abcdef"} {
+		if _, _, err := store.Open(id, testOwner); err == nil {
+			t.Errorf("Open(%q) should fail", id)
+		}
+	}
+
+	e, err := store.Create(FormatCSV, testOwner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Abort()
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Fatalf("an aborted export should leave no file, found %v", entries)
+	}
+
+	if _, err := store.Create("xlsx", testOwner); err == nil {
+		t.Fatal("an unknown format should fail")
+	}
+	if _, err := NewStore(filepath.Join(dir, "missing"), Options{}); err == nil {
+		t.Fatal("a missing directory should fail")
+	}
+}
+
+func TestStoreOwnerAndExpiry(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, Options{TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	file := writeExport(t, store, FormatCSV)
+	if _, _, err := store.Open(file.ID, "postgresql://root@localhost:26257/other"); err == nil {
+		t.Fatal("an export should not open for another database")
+	}
+	if _, _, err := store.Open(file.ID, testOwner); err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	if !file.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("ExpiresAt = %v, want %v", file.ExpiresAt, now.Add(time.Hour))
+	}
+
+	// Creating an export after the TTL deletes the expired one and its owner file.
+	now = now.Add(2 * time.Hour)
+	e, err := store.Create(FormatCSV, testOwner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Abort()
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expired exports should be deleted, found %v", entries)
+	}
+	if _, _, err := store.Open(file.ID, testOwner); err == nil {
+		t.Fatal("an expired export should not open")
+	}
+}
+
+func TestExportMaxBytes(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, Options{MaxBytes: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []string{FormatCSV, FormatParquet} {
+		e, err := store.Create(format, testOwner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Begin(testColumns); err != nil {
+			t.Fatal(err)
+		}
+		err = nil
+		for _, row := range testRows {
+			if err = e.WriteRow(row); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			_, err = e.Commit()
+		} else {
+			e.Abort()
+		}
+		if err == nil || !strings.Contains(err.Error(), "file size limit") {
+			t.Fatalf("%s export over MaxBytes should fail, got %v", format, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("a failed export should leave no file, found %v", entries)
+	}
+}
@@ -0,0 +1,403 @@
+package export
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+)
+
+// parquetRowGroupRows is the number of rows buffered before a row group is written.
+const parquetRowGroupRows = 50000
+
+// Parquet physical types, encodings and other enum values from parquet.thrift.
+const (
+	parquetBoolean   int32 = 0
+	parquetInt64     int32 = 2
+	parquetDouble    int32 = 5
+	parquetByteArray int32 = 6
+
+	parquetOptional int32 = 1
+	parquetUTF8     int32 = 0
+
+	parquetPlain int32 = 0
+	parquetRLE   int32 = 3
+
+	parquetUncompressed int32 = 0
+	parquetDataPage     int32 = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+// parquetWriter writes a Parquet file with uncompressed PLAIN pages, one page per column
+// chunk and one row group per parquetRowGroupRows rows. Every column is OPTIONAL. Integer,
+// float and boolean columns keep their type; other values are stored as UTF8 text in the
+// same form as the CSV export.
+type parquetWriter struct {
+	w         io.Writer
+	offset    int64
+	columns   []*parquetColumn
+	rows      int64
+	groupRows int
+	groups    []parquetRowGroup
+}
+
+// parquetColumn buffers the values of one column for the current row group.
+type parquetColumn struct {
+	name     string
+	physical int32
+	defs     []byte
+	ints     []int64
+	floats   []float64
+	bools    []bool
+	texts    []string
+}
+
+// parquetRowGroup is the footer metadata of a written row group.
+type parquetRowGroup struct {
+	rows   int64
+	bytes  int64
+	chunks []parquetChunk
+}
+
+type parquetChunk struct {
+	offset int64
+	size   int64
+	values int64
+}
+
+func newParquetWriter(w io.Writer, columns []db.Column) *parquetWriter {
+	p := &parquetWriter{w: w}
+	for _, col := range columns {
+		p.columns = append(p.columns, &parquetColumn{name: col.Name, physical: parquetType(col.Type)})
+	}
+	return p
+}
+
+// parquetType maps a database type name to a Parquet physical type.
+func parquetType(dbType string) int32 {
+	switch strings.ToUpper(dbType) {
+	case "INT2", "INT4", "INT8":
+		return parquetInt64
+	case "FLOAT4", "FLOAT8":
+		return parquetDouble
+	case "BOOL":
+		return parquetBoolean
+	}
+	return parquetByteArray
+}
+
+func (p *parquetWriter) begin() error {
+	return p.write(parquetMagic)
+}
+
+func (p *parquetWriter) write(b []byte) error {
+	n, err := p.w.Write(b)
+	p.offset += int64(n)
+	return err
+}
+
+func (p *parquetWriter) writeRow(values []interface{}) error {
+	for i, value := range values {
+		if err := p.columns[i].add(value); err != nil {
+			return fmt.Errorf("column %s: %v", p.columns[i].name, err)
+		}
+	}
+	p.rows++
+	p.groupRows++
+	if p.groupRows >= parquetRowGroupRows {
+		return p.flushRowGroup()
+	}
+	return nil
+}
+
+// add appends a value, converting it to the column's physical type.
+func (c *parquetColumn) add(value interface{}) error {
+	if value == nil {
+		c.defs = append(c.defs, 0)
+		return nil
+	}
+	switch c.physical {
+	case parquetInt64:
+		var n int64
+		switch v := value.(type) {
+		case int64:
+			n = v
+		case int:
+			n = int64(v)
+		default:
+			parsed, err := strconv.ParseInt(TextValue(value), 10, 64)
+			if err != nil {
+				return fmt.Errorf("%v is not an integer", value)
+			}
+			n = parsed
+		}
+		c.ints = append(c.ints, n)
+	case parquetDouble:
+		var f float64
+		switch v := value.(type) {
+		case float64:
+			f = v
+		case int64:
+			f = float64(v)
+		default:
+			// NaN and ±Infinity are encoded as strings.
+			parsed, err := strconv.ParseFloat(TextValue(value), 64)
+			if err != nil {
+				return fmt.Errorf("%v is not a number", value)
+			}
+			f = parsed
+		}
+		c.floats = append(c.floats, f)
+	case parquetBoolean:
+		b, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("%v is not a boolean", value)
+		}
+		c.bools = append(c.bools, b)
+	default:
+		c.texts = append(c.texts, TextValue(value))
+	}
+	c.defs = append(c.defs, 1)
+	return nil
+}
+
+// flushRowGroup writes the buffered rows as one row group.
+func (p *parquetWriter) flushRowGroup() error {
+	group := parquetRowGroup{rows: int64(p.groupRows)}
+	for _, col := range p.columns {
+		data := col.page()
+		var header thriftWriter
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(len(data)))
+		header.i32(3, int32(len(data)))
+		header.beginStruct(5)
+		header.i32(1, int32(len(col.defs)))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.endStruct()
+		header.stop()
+
+		chunk := parquetChunk{offset: p.offset, size: int64(len(header.buf) + len(data)), values: int64(len(col.defs))}
+		if err := p.write(header.buf); err != nil {
+			return err
+		}
+		if err := p.write(data); err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, chunk)
+		group.bytes += chunk.size
+		col.reset()
+	}
+	p.groups = append(p.groups, group)
+	p.groupRows = 0
+	return nil
+}
+
+// page encodes the definition levels and the PLAIN values of the buffered rows.
+func (c *parquetColumn) page() []byte {
+	levels := rleLevels(c.defs)
+	data := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+	data = append(data, levels...)
+
+	switch c.physical {
+	case parquetInt64:
+		for _, v := range c.ints {
+			data = binary.LittleEndian.AppendUint64(data, uint64(v))
+		}
+	case parquetDouble:
+		for _, v := range c.floats {
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+		}
+	case parquetBoolean:
+		packed := make([]byte, (len(c.bools)+7)/8)
+		for i, v := range c.bools {
+			if v {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		data = append(data, packed...)
+	default:
+		for _, v := range c.texts {
+			data = binary.LittleEndian.AppendUint32(data, uint32(len(v)))
+			data = append(data, v...)
+		}
+	}
+	return data
+}
+
+func (c *parquetColumn) reset() {
+	c.defs = c.defs[:0]
+	c.ints = c.ints[:0]
+	c.floats = c.floats[:0]
+	c.bools = c.bools[:0]
+	c.texts = c.texts[:0]
+}
+
+// rleLevels encodes definition levels of bit width 1 as RLE runs of the hybrid encoding.
+func rleLevels(levels []byte) []byte {
+	var out []byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		out = append(out, levels[i])
+		i = j
+	}
+	return out
+}
+
+// close writes the last row group and the footer.
+func (p *parquetWriter) close() error {
+	if p.groupRows > 0 {
+		if err := p.flushRowGroup(); err != nil {
+			return err
+		}
+	}
+
+	var meta thriftWriter
+	meta.i32(1, 1)
+	meta.listHeader(2, thriftStruct, len(p.columns)+1)
+	meta.beginElement()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(p.columns)))
+	meta.endStruct()
+	for _, col := range p.columns {
+		meta.beginElement()
+		meta.i32(1, col.physical)
+		meta.i32(3, parquetOptional)
+		meta.binary(4, col.name)
+		if col.physical == parquetByteArray {
+			meta.i32(6, parquetUTF8)
+		}
+		meta.endStruct()
+	}
+	meta.i64(3, p.rows)
+	meta.listHeader(4, thriftStruct, len(p.groups))
+	for _, group := range p.groups {
+		meta.beginElement()
+		meta.listHeader(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			col := p.columns[i]
+			meta.beginElement()
+			meta.i64(2, chunk.offset)
+			meta.beginStruct(3)
+			meta.i32(1, col.physical)
+			meta.listHeader(2, thriftI32, 2)
+			meta.i32Element(parquetPlain)
+			meta.i32Element(parquetRLE)
+			meta.listHeader(3, thriftBinary, 1)
+			meta.binaryElement(col.name)
+			meta.i32(4, parquetUncompressed)
+			meta.i64(5, chunk.values)
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.endStruct()
+			meta.endStruct()
+		}
+		meta.i64(2, group.bytes)
+		meta.i64(3, group.rows)
+		meta.endStruct()
+	}
+	meta.binary(6, "kwdb-mcp-server")
+	meta.stop()
+
+	if err := p.write(meta.buf); err != nil {
+		return err
+	}
+	if err := p.write(binary.LittleEndian.AppendUint32(nil, uint32(len(meta.buf)))); err != nil {
+		return err
+	}
+	return p.write(parquetMagic)
+}
+
+// Thrift compact protocol type IDs.
+const (
+	thriftI32    byte = 5
+	thriftI64    byte = 6
+	thriftBinary byte = 8
+	thriftList   byte = 9
+	thriftStruct byte = 12
+)
+
+// thriftWriter encodes structs with the Thrift compact protocol, as Parquet metadata is.
+type thriftWriter struct {
+	buf  []byte
+	last int16
+	// stack holds the last field ID of each enclosing struct.
+	stack []int16
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.buf = binary.AppendVarint(t.buf, int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.buf = binary.AppendVarint(t.buf, int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.buf = binary.AppendVarint(t.buf, v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.binaryElement(s)
+}
+
+func (t *thriftWriter) listHeader(id int16, elemType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf = append(t.buf, byte(size)<<4|elemType)
+		return
+	}
+	t.buf = append(t.buf, 0xf0|elemType)
+	t.buf = binary.AppendUvarint(t.buf, uint64(size))
+}
+
+func (t *thriftWriter) i32Element(v int32) {
+	t.buf = binary.AppendVarint(t.buf, int64(v))
+}
+
+func (t *thriftWriter) binaryElement(s string) {
+	t.buf = binary.AppendUvarint(t.buf, uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+// beginStruct starts a struct field; beginElement starts a struct in a list.
+func (t *thriftWriter) beginStruct(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.beginElement()
+}
+
+func (t *thriftWriter) beginElement() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) endStruct() {
+	t.stop()
+	t.last = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+// stop ends the current struct.
+func (t *thriftWriter) stop() {
+	t.buf = append(t.buf, 0)
+}
@@ -0,0 +1,250 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+)
+
+// thriftReader decodes the Thrift compact protocol into maps keyed by field ID, lists and
+// scalars, enough to check the metadata parquetWriter produces.
+type thriftReader struct {
+	r *bytes.Reader
+}
+
+func (t thriftReader) readStruct() (map[int16]interface{}, error) {
+	fields := map[int16]interface{}{}
+	var last int16
+	for {
+		header, err := t.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if header == 0 {
+			return fields, nil
+		}
+		typ := header & 0x0f
+		if delta := int16(header >> 4); delta != 0 {
+			last += delta
+		} else {
+			id, err := binary.ReadVarint(t.r)
+			if err != nil {
+				return nil, err
+			}
+			last = int16(id)
+		}
+		value, err := t.readValue(typ)
+		if err != nil {
+			return nil, err
+		}
+		fields[last] = value
+	}
+}
+
+func (t thriftReader) readValue(typ byte) (interface{}, error) {
+	switch typ {
+	case thriftI32, thriftI64:
+		return binary.ReadVarint(t.r)
+	case thriftBinary:
+		n, err := binary.ReadUvarint(t.r)
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, n)
+		_, err = t.r.Read(b)
+		return string(b), err
+	case thriftStruct:
+		return t.readStruct()
+	case thriftList:
+		header, err := t.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		size := uint64(header >> 4)
+		if size == 15 {
+			if size, err = binary.ReadUvarint(t.r); err != nil {
+				return nil, err
+			}
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			if list[i], err = t.readValue(header & 0x0f); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("unsupported thrift type %d", typ)
+}
+
+// writeParquetExport exports rows to a Parquet file and returns its path.
+func writeParquetExport(t *testing.T, columns []db.Column, rows [][]interface{}) string {
+	t.Helper()
+	store, err := NewStore(t.TempDir(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := store.Create(FormatParquet, testOwner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Begin(columns); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := e.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file, err := e.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file.Path
+}
+
+func TestParquetExport(t *testing.T) {
+	columns := []db.Column{{Name: "id", Type: "INT8"}, {Name: "v", Type: "FLOAT8"}, {Name: "ok", Type: "BOOL"}, {Name: "s", Type: "TIMESTAMPTZ"}}
+	rows := [][]interface{}{
+		{int64(1), 1.5, true, "2025-01-01T00:00:00Z"},
+		{int64(2), nil, false, nil},
+		{int64(3), "NaN", nil, "x"},
+	}
+	content, err := os.ReadFile(writeParquetExport(t, columns, rows))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(content, parquetMagic) || !bytes.HasSuffix(content, parquetMagic) {
+		t.Fatal("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(content[len(content)-8:]))
+	footer := content[len(content)-8-footerLen : len(content)-8]
+	meta, err := thriftReader{bytes.NewReader(footer)}.readStruct()
+	if err != nil {
+		t.Fatalf("failed to decode footer: %v", err)
+	}
+
+	if meta[3] != int64(3) {
+		t.Fatalf("num_rows = %v, want 3", meta[3])
+	}
+	schema := meta[2].([]interface{})
+	if len(schema) != 5 || schema[0].(map[int16]interface{})[5] != int64(4) {
+		t.Fatalf("unexpected schema %v", schema)
+	}
+	wantTypes := []int64{int64(parquetInt64), int64(parquetDouble), int64(parquetBoolean), int64(parquetByteArray)}
+	for i, want := range wantTypes {
+		element := schema[i+1].(map[int16]interface{})
+		if element[1] != want || element[4] != columns[i].Name {
+			t.Errorf("schema element %d = %v, want type %d", i+1, element, want)
+		}
+	}
+
+	groups := meta[4].([]interface{})
+	chunks := groups[0].(map[int16]interface{})[1].([]interface{})
+	if len(groups) != 1 || len(chunks) != 4 {
+		t.Fatalf("unexpected row groups %v", groups)
+	}
+
+	// Read back the DOUBLE column: its definition levels and PLAIN values.
+	chunkMeta := chunks[1].(map[int16]interface{})[3].(map[int16]interface{})
+	offset := chunkMeta[9].(int64)
+	page := bytes.NewReader(content[offset:])
+	header, err := thriftReader{page}.readStruct()
+	if err != nil {
+		t.Fatalf("failed to decode page header: %v", err)
+	}
+	if dataHeader := header[5].(map[int16]interface{}); dataHeader[1] != int64(3) {
+		t.Fatalf("page num_values = %v, want 3", dataHeader[1])
+	}
+	data := make([]byte, header[3].(int64))
+	page.Read(data)
+	levelsLen := binary.LittleEndian.Uint32(data)
+	// Runs: one 1, one 0, one 1.
+	if !bytes.Equal(data[4:4+levelsLen], []byte{2, 1, 2, 0, 2, 1}) {
+		t.Fatalf("unexpected definition levels %v", data[4:4+levelsLen])
+	}
+	values := data[4+levelsLen:]
+	first := math.Float64frombits(binary.LittleEndian.Uint64(values))
+	second := math.Float64frombits(binary.LittleEndian.Uint64(values[8:]))
+	if len(values) != 16 || first != 1.5 || !math.IsNaN(second) {
+		t.Fatalf("unexpected values %v %v", first, second)
+	}
+}
+
+// TestParquetReadByPyArrow checks the writer against pyarrow, the reference Parquet
+// implementation, through testdata/read_parquet.py. It is skipped when python3 or pyarrow
+// is not installed.
+func TestParquetReadByPyArrow(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not installed")
+	}
+	columns := []db.Column{
+		{Name: "id", Type: "INT8"}, {Name: "v", Type: "FLOAT8"}, {Name: "ok", Type: "BOOL"},
+		{Name: "ts", Type: "TIMESTAMPTZ"}, {Name: "doc", Type: "JSONB"}, {Name: "n", Type: "INT4"},
+	}
+	rows := [][]interface{}{
+		{int64(1), 1.5, true, "2025-01-01T00:00:00Z", json.RawMessage(`{"a":1}`), nil},
+		{int64(2), nil, false, nil, nil, nil},
+		{int64(3), "NaN", nil, "x", "传感器", nil},
+		{int64(4), -2.25, true, "", nil, int64(-7)},
+		{int64(5), 0.0, true, "y", []interface{}{"a", nil}, int64(8)},
+	}
+	path := writeParquetExport(t, columns, rows)
+
+	out, err := exec.Command(python, filepath.Join("testdata", "read_parquet.py"), path).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 3 {
+		t.Skip("pyarrow is not installed")
+	}
+	if exitErr != nil {
+		t.Fatalf("pyarrow could not read the file: %v\n%s", err, exitErr.Stderr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Schema    [][2]string              `json:"schema"`
+		NumRows   int                      `json:"num_rows"`
+		RowGroups int                      `json:"row_groups"`
+		Rows      []map[string]interface{} `json:"rows"`
+	}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("unexpected output %s: %v", out, err)
+	}
+	wantSchema := [][2]string{{"id", "int64"}, {"v", "double"}, {"ok", "bool"}, {"ts", "string"}, {"doc", "string"}, {"n", "int64"}}
+	if fmt.Sprint(got.Schema) != fmt.Sprint(wantSchema) || got.NumRows != len(rows) || got.RowGroups != 1 {
+		t.Fatalf("schema %v, %d rows in %d row groups", got.Schema, got.NumRows, got.RowGroups)
+	}
+	want := []map[string]interface{}{
+		{"id": 1.0, "v": 1.5, "ok": true, "ts": "2025-01-01T00:00:00Z", "doc": `{"a":1}`, "n": nil},
+		{"id": 2.0, "v": nil, "ok": false, "ts": nil, "doc": nil, "n": nil},
+		{"id": 3.0, "v": "NaN", "ok": nil, "ts": "x", "doc": "传感器", "n": nil},
+		{"id": 4.0, "v": -2.25, "ok": true, "ts": "", "doc": nil, "n": -7.0},
+		{"id": 5.0, "v": 0.0, "ok": true, "ts": "y", "doc": `["a",null]`, "n": 8.0},
+	}
+	if !reflect.DeepEqual(got.Rows, want) {
+		t.Fatalf("pyarrow read\n%v\nwant\n%v", got.Rows, want)
+	}
+}
+
+func TestParquetRejectsMismatchedValues(t *testing.T) {
+	w := newParquetWriter(&bytes.Buffer{}, []db.Column{{Name: "n", Type: "INT8"}})
+	if err := w.begin(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.writeRow([]interface{}{"seven"}); err == nil {
+		t.Fatal("a non-integer value in an INT8 column should fail")
+	}
+}
@@ -0,0 +1,30 @@
+"""Reads a Parquet file with pyarrow, the reference implementation, and prints it as JSON.
+
+Used by TestParquetReadByPyArrow. Exits with status 3 when pyarrow is not installed, so the
+test is skipped instead of failing.
+"""
+import json
+import math
+import sys
+
+try:
+    import pyarrow.parquet as pq
+except ImportError:
+    sys.exit(3)
+
+
+def plain(value):
+    # JSON has no NaN; report it as text.
+    if isinstance(value, float) and math.isnan(value):
+        return "NaN"
+    return value
+
+
+table = pq.read_table(sys.argv[1])
+metadata = pq.ParquetFile(sys.argv[1]).metadata
+print(json.dumps({
+    "schema": [[field.name, str(field.type)] for field in table.schema],
+    "num_rows": metadata.num_rows,
+    "row_groups": metadata.num_row_groups,
+    "rows": [{k: plain(v) for k, v in row.items()} for row in table.to_pylist()],
+}))
@@ -0,0 +1,465 @@
+// Package jobs runs long read-only queries in the background and keeps their results, in memory
+// and then in spill files, until the client fetches them.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+)
+
+// Job states.
+const (
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	StateCancelled = "cancelled"
+)
+
+const (
+	defaultMemoryBytes = 8 << 20
+	defaultMaxBytes    = 256 << 20
+	defaultMaxRunning  = 4
+	defaultMaxJobs     = 32
+	// 所有数据库共享的上限：客户端可以任意选择 X-Database-URI，按库的上限无法约束整个服务器
+	defaultMaxTotalRunning = 16
+	defaultMaxTotalBytes   = 1 << 30
+	defaultTTL             = time.Hour
+	// notifyInterval is the shortest time between two progress updates of a job.
+	notifyInterval = time.Second
+)
+
+// Options bounds the jobs of a Manager. Zero values use the defaults.
+type Options struct {
+	// Dir holds the spill files (default os.TempDir()).
+	Dir string
+	// MemoryBytes is the size of the rows a job keeps in memory before it spills to disk (default 8 MiB).
+	MemoryBytes int
+	// MaxBytes caps the result of one job; the job stops reading when it is reached (default 256 MiB).
+	MaxBytes int64
+	// MaxRunning is the number of jobs that may run at once for one database (default 4).
+	MaxRunning int
+	// MaxJobs is the number of jobs kept for one database; the oldest finished job is dropped first (default 32).
+	MaxJobs int
+	// MaxTotalRunning is the number of jobs that may run at once for all databases together (default 16).
+	MaxTotalRunning int
+	// MaxTotalBytes caps the results of all jobs together, in memory and in spill files (default 1 GiB).
+	// Submit drops the oldest finished jobs until MemoryBytes are free for the new job, and is
+	// refused when that is not enough; a running job stops reading and is truncated when it would
+	// exceed it.
+	MaxTotalBytes int64
+	// TTL is how long a finished job and its result are kept (default 1 hour).
+	TTL time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.Dir == "" {
+		o.Dir = os.TempDir()
+	}
+	if o.MemoryBytes <= 0 {
+		o.MemoryBytes = defaultMemoryBytes
+	}
+	if o.MaxBytes <= 0 {
+		o.MaxBytes = defaultMaxBytes
+	}
+	if o.MaxRunning <= 0 {
+		o.MaxRunning = defaultMaxRunning
+	}
+	if o.MaxJobs <= 0 {
+		o.MaxJobs = defaultMaxJobs
+	}
+	if o.MaxTotalRunning <= 0 {
+		o.MaxTotalRunning = defaultMaxTotalRunning
+	}
+	if o.MaxTotalBytes <= 0 {
+		o.MaxTotalBytes = defaultMaxTotalBytes
+	}
+	if o.TTL <= 0 {
+		o.TTL = defaultTTL
+	}
+	return o
+}
+
+// RunFunc runs the statement of a job. It hands the result columns to start and then every
+// row to row, and must stop when ctx is cancelled or row returns an error.
+type RunFunc func(ctx context.Context, start func([]db.Column) error, row func([]interface{}) error) error
+
+// Status is a snapshot of a job.
+type Status struct {
+	ID          string      `json:"job_id"`
+	State       string      `json:"state"`
+	Query       string      `json:"query"`
+	Columns     []db.Column `json:"columns"`
+	RowCount    int         `json:"row_count"`
+	Bytes       int64       `json:"bytes"`
+	Spilled     bool        `json:"spilled"`
+	Truncated   bool        `json:"truncated"`
+	Error       string      `json:"error,omitempty"`
+	SubmittedAt time.Time   `json:"submitted_at"`
+	FinishedAt  *time.Time  `json:"finished_at,omitempty"`
+	ElapsedMS   int64       `json:"elapsed_ms"`
+	ExpiresAt   *time.Time  `json:"expires_at,omitempty"`
+}
+
+// Done reports whether the job has finished.
+func (s Status) Done() bool {
+	return s.State != StateRunning
+}
+
+// Page is a slice of a job result.
+type Page struct {
+	Status Status
+	Offset int
+	Rows   [][]interface{}
+	Bytes  int
+	// More reports rows after the page, stored or still to come.
+	More bool
+}
+
+type job struct {
+	id          string
+	databaseURI string
+	query       string
+	submittedAt time.Time
+	cancel      context.CancelFunc
+
+	mu          sync.Mutex
+	state       string
+	columns     []db.Column
+	results     *resultStore
+	truncated   bool
+	cancelled   bool
+	err         string
+	finishedAt  time.Time
+	lastNotify  time.Time
+	notifiedRow int
+}
+
+// Manager runs jobs and keeps their results. Jobs belong to the database they run on: a job
+// is only visible to calls for the same database URI.
+type Manager struct {
+	opts Options
+	now  func() time.Time
+
+	mu   sync.Mutex
+	jobs map[string]*job
+	// bytes is the size of the results of all jobs; it changes under the job's lock.
+	bytes atomic.Int64
+}
+
+// NewManager returns a manager with opts.
+func NewManager(opts Options) *Manager {
+	return &Manager{
+		opts: opts.withDefaults(),
+		now:  time.Now,
+		jobs: make(map[string]*job),
+	}
+}
+
+// Submit starts a job for databaseURI that runs run in the background. The job is cancelled
+// with ctx, which should carry no deadline of the submitting call. notify, when not nil,
+// receives the status of the job as rows arrive, at most once per second, and when it ends.
+func (m *Manager) Submit(ctx context.Context, databaseURI, query string, run RunFunc, notify func(Status)) (Status, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return Status{}, fmt.Errorf("failed to generate job ID: %v", err)
+	}
+
+	m.mu.Lock()
+	now := m.now()
+	m.evictExpiredLocked(now)
+
+	var running, totalRunning int
+	var finished, allFinished []*job
+	for _, j := range m.jobs {
+		j.mu.Lock()
+		isRunning := j.state == StateRunning
+		j.mu.Unlock()
+		if isRunning {
+			totalRunning++
+		} else {
+			allFinished = append(allFinished, j)
+		}
+		if j.databaseURI != databaseURI {
+			continue
+		}
+		if isRunning {
+			running++
+		} else {
+			finished = append(finished, j)
+		}
+	}
+	if totalRunning >= m.opts.MaxTotalRunning {
+		m.mu.Unlock()
+		return Status{}, fmt.Errorf("too many running jobs on this server (max %d); wait for one to finish or cancel it", m.opts.MaxTotalRunning)
+	}
+	if running >= m.opts.MaxRunning {
+		m.mu.Unlock()
+		return Status{}, fmt.Errorf("too many running jobs for this database (max %d); wait for one to finish or cancel it", m.opts.MaxRunning)
+	}
+	// 达到保留上限时，淘汰最早结束的作业
+	sort.Slice(finished, func(a, b int) bool { return finished[a].finishedAt.Before(finished[b].finishedAt) })
+	for i := 0; running+len(finished)-i >= m.opts.MaxJobs && i < len(finished); i++ {
+		m.removeLocked(finished[i])
+	}
+	// 为新作业留出 MemoryBytes 的空间：不足时淘汰所有数据库中最早结束的作业
+	full := func() bool { return m.bytes.Load()+int64(m.opts.MemoryBytes) > m.opts.MaxTotalBytes }
+	sort.Slice(allFinished, func(a, b int) bool { return allFinished[a].finishedAt.Before(allFinished[b].finishedAt) })
+	for _, j := range allFinished {
+		if !full() {
+			break
+		}
+		if _, ok := m.jobs[j.id]; ok {
+			m.removeLocked(j)
+		}
+	}
+	if full() {
+		m.mu.Unlock()
+		return Status{}, fmt.Errorf("running jobs use the job storage of this server (%d bytes); wait for a job to finish or cancel it", m.opts.MaxTotalBytes)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	j := &job{
+		id:          hex.EncodeToString(raw),
+		databaseURI: databaseURI,
+		query:       query,
+		submittedAt: now,
+		cancel:      cancel,
+		state:       StateRunning,
+		results:     newResultStore(m.opts.Dir, m.opts.MemoryBytes, m.opts.MaxBytes),
+	}
+	m.jobs[j.id] = j
+	m.mu.Unlock()
+
+	go m.run(ctx, j, run, notify)
+	return m.status(j), nil
+}
+
+func (m *Manager) run(ctx context.Context, j *job, run RunFunc, notify func(Status)) {
+	defer j.cancel()
+
+	start := func(columns []db.Column) error {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.columns = columns
+		return nil
+	}
+	row := func(values []interface{}) error {
+		encoded, err := json.Marshal(values)
+		if err != nil {
+			return fmt.Errorf("failed to encode row: %v", err)
+		}
+		j.mu.Lock()
+		if m.bytes.Load()+int64(len(encoded)) > m.opts.MaxTotalBytes {
+			err = errResultFull
+		} else if err = j.results.append(encoded); err == nil {
+			m.bytes.Add(int64(len(encoded)))
+		}
+		if err == errResultFull {
+			j.truncated = true
+		}
+		due := notify != nil && m.now().Sub(j.lastNotify) >= notifyInterval
+		j.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		if due {
+			m.notify(j, notify)
+		}
+		return nil
+	}
+
+	err := run(ctx, start, row)
+
+	j.mu.Lock()
+	switch {
+	case j.truncated:
+		j.state = StateSucceeded
+	case j.cancelled:
+		j.state = StateCancelled
+	case err != nil:
+		j.state = StateFailed
+		j.err = err.Error()
+	default:
+		j.state = StateSucceeded
+	}
+	j.finishedAt = m.now()
+	j.mu.Unlock()
+
+	if notify != nil {
+		m.notify(j, notify)
+	}
+}
+
+func (m *Manager) notify(j *job, notify func(Status)) {
+	j.mu.Lock()
+	j.lastNotify = m.now()
+	j.mu.Unlock()
+	notify(m.status(j))
+}
+
+// status takes a snapshot of j.
+func (m *Manager) status(j *job) Status {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	s := Status{
+		ID:          j.id,
+		State:       j.state,
+		Query:       j.query,
+		Columns:     j.columns,
+		RowCount:    j.results.rows(),
+		Bytes:       j.results.bytes,
+		Spilled:     j.results.spilled(),
+		Truncated:   j.truncated,
+		Error:       j.err,
+		SubmittedAt: j.submittedAt,
+	}
+	end := m.now()
+	if j.state != StateRunning {
+		finished, expires := j.finishedAt, j.finishedAt.Add(m.opts.TTL)
+		s.FinishedAt, s.ExpiresAt = &finished, &expires
+		end = j.finishedAt
+	}
+	s.ElapsedMS = end.Sub(j.submittedAt).Milliseconds()
+	return s
+}
+
+// find returns the job with id if it belongs to databaseURI.
+func (m *Manager) find(id, databaseURI string) (*job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.evictExpiredLocked(m.now())
+	j, ok := m.jobs[id]
+	if !ok || j.databaseURI != databaseURI {
+		return nil, fmt.Errorf("job %s not found or expired", id)
+	}
+	return j, nil
+}
+
+// Status returns the status of the job with id.
+func (m *Manager) Status(id, databaseURI string) (Status, error) {
+	j, err := m.find(id, databaseURI)
+	if err != nil {
+		return Status{}, err
+	}
+	return m.status(j), nil
+}
+
+// Fetch returns up to maxRows rows of the job result starting at offset, within maxBytes of
+// serialized rows. The rows stored so far can be fetched while the job is still running.
+func (m *Manager) Fetch(id, databaseURI string, offset, maxRows, maxBytes int) (*Page, error) {
+	j, err := m.find(id, databaseURI)
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("offset must not be negative")
+	}
+
+	// 先取状态再读行，保证 More 不会漏掉读取期间新到的行
+	status := m.status(j)
+	j.mu.Lock()
+	encoded, err := j.results.read(offset, maxRows, maxBytes)
+	stored := j.results.rows()
+	j.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{Status: status, Offset: offset, Rows: make([][]interface{}, 0, len(encoded))}
+	for _, row := range encoded {
+		values, err := decodeRow(row)
+		if err != nil {
+			return nil, err
+		}
+		page.Rows = append(page.Rows, values)
+		page.Bytes += len(row)
+	}
+	page.More = offset+len(page.Rows) < stored || !status.Done()
+	return page, nil
+}
+
+// decodeRow decodes a stored row. Strings and NULL get their Go form back; other values stay
+// JSON, so numbers and JSON documents keep their exact text.
+func decodeRow(row []byte) ([]interface{}, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(row, &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode stored row: %v", err)
+	}
+	values := make([]interface{}, len(raw))
+	for i, value := range raw {
+		switch value[0] {
+		case 'n':
+			values[i] = nil
+		case '"':
+			var s string
+			if err := json.Unmarshal(value, &s); err != nil {
+				return nil, fmt.Errorf("failed to decode stored row: %v", err)
+			}
+			values[i] = s
+		default:
+			values[i] = value
+		}
+	}
+	return values, nil
+}
+
+// Cancel stops the job with id. The rows read before it stopped can still be fetched.
+// Cancelling a finished job has no effect.
+func (m *Manager) Cancel(id, databaseURI string) (Status, error) {
+	j, err := m.find(id, databaseURI)
+	if err != nil {
+		return Status{}, err
+	}
+	j.mu.Lock()
+	if j.state == StateRunning {
+		j.cancelled = true
+		j.cancel()
+	}
+	j.mu.Unlock()
+	return m.status(j), nil
+}
+
+// Close cancels every job and removes all results.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		m.removeLocked(j)
+	}
+}
+
+// removeLocked cancels j and drops its result.
+func (m *Manager) removeLocked(j *job) {
+	j.mu.Lock()
+	if j.state == StateRunning {
+		j.cancelled = true
+	}
+	j.cancel()
+	m.bytes.Add(-j.results.bytes)
+	j.results.close()
+	j.mu.Unlock()
+	delete(m.jobs, j.id)
+}
+
+func (m *Manager) evictExpiredLocked(now time.Time) {
+	for _, j := range m.jobs {
+		j.mu.Lock()
+		expired := j.state != StateRunning && !now.Before(j.finishedAt.Add(m.opts.TTL))
+		j.mu.Unlock()
+		if expired {
+			m.removeLocked(j)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+)
+
+var testColumns = []db.Column{{Name: "n", Type: "INT8"}, {Name: "s", Type: "STRING"}}
+
+// emitRows returns a RunFunc that produces n rows and then returns err.
+func emitRows(n int, err error) RunFunc {
+	return func(ctx context.Context, start func([]db.Column) error, row func([]interface{}) error) error {
+		if e := start(testColumns); e != nil {
+			return e
+		}
+		for i := 0; i < n; i++ {
+			if e := row([]interface{}{int64(i), fmt.Sprintf("row %d", i)}); e != nil {
+				return e
+			}
+		}
+		return err
+	}
+}
+
+// waitDone polls the job until it finishes.
+func waitDone(t *testing.T, m *Manager, id, databaseURI string) Status {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status, err := m.Status(id, databaseURI)
+		if err != nil {
+			t.Fatalf("Status returned error: %v", err)
+		}
+		if status.Done() {
+			return status
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return Status{}
+}
+
+func TestResultStoreSpillsToDisk(t *testing.T) {
+	store := newResultStore(t.TempDir(), 100, 0)
+	defer store.close()
+
+	const total = 3000
+	for i := 0; i < total; i++ {
+		row, _ := json.Marshal([]interface{}{i})
+		if err := store.append(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !store.spilled() || store.rows() != total || len(store.memory) == 0 {
+		t.Fatalf("expected rows in memory and on disk, got %d in memory of %d", len(store.memory), store.rows())
+	}
+
+	// Read across the memory boundary and from the middle of an index stride.
+	for _, offset := range []int{len(store.memory) - 2, 1500, total - 3} {
+		rows, err := store.read(offset, 5, 1<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := total - offset
+		if want > 5 {
+			want = 5
+		}
+		if len(rows) != want {
+			t.Fatalf("read(%d) returned %d rows, want %d", offset, len(rows), want)
+		}
+		for i, row := range rows {
+			if string(row) != fmt.Sprintf("[%d]", offset+i) {
+				t.Fatalf("read(%d) row %d = %s", offset, i, row)
+			}
+		}
+	}
+
+	// The byte budget stops the page, but at least one row is returned.
+	if rows, _ := store.read(0, 10, 1); len(rows) != 1 {
+		t.Fatalf("expected one row within a tiny byte budget, got %d", len(rows))
+	}
+
+	path := store.file.Name()
+	store.close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("close should remove the spill file, stat returned %v", err)
+	}
+	if err := store.append([]byte("[1]")); err != errResultClosed {
+		t.Fatalf("append after close returned %v", err)
+	}
+}
+
+func TestManagerRunsAndFetches(t *testing.T) {
+	m := NewManager(Options{Dir: t.TempDir(), MemoryBytes: 64})
+	defer m.Close()
+
+	var notified []Status
+	done := make(chan struct{})
+	status, err := m.Submit(context.Background(), "postgres://a/db1", "SELECT n, s FROM t", emitRows(50, nil), func(s Status) {
+		notified = append(notified, s)
+		if s.Done() {
+			close(done)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.ID == "" || status.Query != "SELECT n, s FROM t" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	final := waitDone(t, m, status.ID, "postgres://a/db1")
+	<-done
+	if final.State != StateSucceeded || final.RowCount != 50 || !final.Spilled || final.FinishedAt == nil || final.ExpiresAt == nil {
+		t.Fatalf("unexpected final status %+v", final)
+	}
+	if last := notified[len(notified)-1]; last.State != StateSucceeded {
+		t.Fatalf("the last notification should report the final state, got %+v", last)
+	}
+
+	page, err := m.Fetch(status.ID, "postgres://a/db1", 45, 10, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Rows) != 5 || page.More {
+		t.Fatalf("unexpected last page %+v", page)
+	}
+	if n, ok := page.Rows[0][0].(json.RawMessage); !ok || string(n) != "45" || page.Rows[0][1] != "row 45" {
+		t.Fatalf("unexpected row %v", page.Rows[0])
+	}
+	if page, _ := m.Fetch(status.ID, "postgres://a/db1", 0, 10, 1<<20); !page.More {
+		t.Fatal("the first page should report more rows")
+	}
+
+	// Jobs are only visible to calls for the same database.
+	if _, err := m.Status(status.ID, "postgres://a/db2"); err == nil {
+		t.Fatal("a job should not be visible from another database")
+	}
+	if _, err := m.Fetch(status.ID, "postgres://a/db1", -1, 10, 100); err == nil {
+		t.Fatal("a negative offset should fail")
+	}
+}
+
+func TestManagerFailuresAndLimits(t *testing.T) {
+	m := NewManager(Options{Dir: t.TempDir(), MaxBytes: 100, MaxRunning: 1})
+	defer m.Close()
+
+	failed, _ := m.Submit(context.Background(), "", "SELECT 1", emitRows(1, errors.New("boom")), nil)
+	if status := waitDone(t, m, failed.ID, ""); status.State != StateFailed || status.Error != "boom" {
+		t.Fatalf("unexpected failed status %+v", status)
+	}
+
+	full, _ := m.Submit(context.Background(), "", "SELECT 1", emitRows(100, nil), nil)
+	if status := waitDone(t, m, full.ID, ""); status.State != StateSucceeded || !status.Truncated || status.Bytes > 100 {
+		t.Fatalf("unexpected truncated status %+v", status)
+	}
+
+	blocked := make(chan struct{})
+	running, err := m.Submit(context.Background(), "", "SELECT 1", func(ctx context.Context, start func([]db.Column) error, row func([]interface{}) error) error {
+		start(testColumns)
+		row([]interface{}{int64(1), "a"})
+		close(blocked)
+		<-ctx.Done()
+		return ctx.Err()
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-blocked
+	if _, err := m.Submit(context.Background(), "", "SELECT 2", emitRows(1, nil), nil); err == nil {
+		t.Fatal("a second running job should exceed MaxRunning")
+	}
+
+	if _, err := m.Cancel(running.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	status := waitDone(t, m, running.ID, "")
+	if status.State != StateCancelled || status.RowCount != 1 {
+		t.Fatalf("unexpected cancelled status %+v", status)
+	}
+	if page, _ := m.Fetch(running.ID, "", 0, 10, 1<<20); len(page.Rows) != 1 {
+		t.Fatal("the rows of a cancelled job should still be fetchable")
+	}
+}
+
+func TestManagerEvictsFinishedJobs(t *testing.T) {
+	m := NewManager(Options{Dir: t.TempDir(), MaxJobs: 2, TTL: time.Minute})
+	defer m.Close()
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		status, err := m.Submit(context.Background(), "", "SELECT 1", emitRows(1, nil), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitDone(t, m, status.ID, "")
+		now = now.Add(time.Second)
+		ids = append(ids, status.ID)
+	}
+	if _, err := m.Status(ids[0], ""); err == nil {
+		t.Fatal("the oldest finished job should be dropped beyond MaxJobs")
+	}
+	if _, err := m.Status(ids[2], ""); err != nil {
+		t.Fatalf("the newest job should be kept: %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := m.Status(ids[2], ""); err == nil {
+		t.Fatal("a job should expire after TTL")
+	}
+}
+
+func TestManagerServerLimits(t *testing.T) {
+	m := NewManager(Options{Dir: t.TempDir(), MemoryBytes: 50, MaxTotalRunning: 1, MaxTotalBytes: 100})
+	defer m.Close()
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	// Each row is ~14 bytes: a job on db1 fills the server budget and is truncated.
+	first, err := m.Submit(context.Background(), "db1", "SELECT 1", emitRows(100, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := waitDone(t, m, first.ID, "db1"); !status.Truncated || status.Bytes > 100 {
+		t.Fatalf("the job should be truncated by the server budget: %+v", status)
+	}
+
+	// A job for another database drops the finished job to make room.
+	now = now.Add(time.Second)
+	blocked := make(chan struct{})
+	running, err := m.Submit(context.Background(), "db2", "SELECT 1", func(ctx context.Context, start func([]db.Column) error, row func([]interface{}) error) error {
+		start(testColumns)
+		for i := 0; i < 100; i++ {
+			if err := row([]interface{}{int64(i), "x"}); err != nil {
+				break
+			}
+		}
+		close(blocked)
+		<-ctx.Done()
+		return ctx.Err()
+	}, nil)
+	if err != nil {
+		t.Fatalf("the oldest finished job should be dropped for a new one: %v", err)
+	}
+	if _, err := m.Status(first.ID, "db1"); err == nil {
+		t.Fatal("the finished job should be dropped when the server budget is used")
+	}
+	<-blocked
+
+	// Running jobs are capped across databases, and so is their storage.
+	if _, err := m.Submit(context.Background(), "db3", "SELECT 1", emitRows(1, nil), nil); err == nil {
+		t.Fatal("a second running job should exceed MaxTotalRunning")
+	}
+	m.opts.MaxTotalRunning = 2
+	if _, err := m.Submit(context.Background(), "db3", "SELECT 1", emitRows(1, nil), nil); err == nil {
+		t.Fatal("a job should be refused while running jobs use the whole budget")
+	}
+
+	m.Cancel(running.ID, "db2")
+	waitDone(t, m, running.ID, "db2")
+	m.Close()
+	if got := m.bytes.Load(); got != 0 {
+		t.Fatalf("removed jobs should release their bytes, %d left", got)
+	}
+}
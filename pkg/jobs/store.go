@@ -0,0 +1,147 @@
+package jobs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// indexStride is the number of spilled rows between two entries of the file index.
+const indexStride = 1024
+
+var (
+	// errResultFull stops a job whose result reached Options.MaxBytes.
+	errResultFull = errors.New("job result is full")
+	// errResultClosed stops a job whose result was dropped while it was running.
+	errResultClosed = errors.New("job result was dropped")
+)
+
+// resultStore holds the rows of a job as JSON arrays, one per row. The first rows stay in memory;
+// once they take memoryBytes the rest are appended to a spill file, one row per line.
+type resultStore struct {
+	dir         string
+	memoryBytes int
+	maxBytes    int64
+
+	memory     [][]byte
+	memoryUsed int
+	file       *os.File
+	w          *bufio.Writer
+	fileBytes  int64
+	fileRows   int
+	// index holds the file offset of every indexStride-th spilled row.
+	index  []int64
+	bytes  int64
+	closed bool
+}
+
+func newResultStore(dir string, memoryBytes int, maxBytes int64) *resultStore {
+	return &resultStore{dir: dir, memoryBytes: memoryBytes, maxBytes: maxBytes}
+}
+
+// rows returns the number of stored rows.
+func (r *resultStore) rows() int {
+	return len(r.memory) + r.fileRows
+}
+
+// spilled reports whether rows were written to disk.
+func (r *resultStore) spilled() bool {
+	return r.file != nil
+}
+
+// append stores one encoded row, or returns errResultFull when it would exceed maxBytes.
+func (r *resultStore) append(row []byte) error {
+	if r.closed {
+		return errResultClosed
+	}
+	if r.maxBytes > 0 && r.bytes+int64(len(row)) > r.maxBytes {
+		return errResultFull
+	}
+	if r.file == nil && r.memoryUsed+len(row) <= r.memoryBytes {
+		r.memory = append(r.memory, row)
+		r.memoryUsed += len(row)
+		r.bytes += int64(len(row))
+		return nil
+	}
+
+	if r.file == nil {
+		file, err := os.CreateTemp(r.dir, "kwdb-job-*.ndjson")
+		if err != nil {
+			return fmt.Errorf("failed to create spill file: %v", err)
+		}
+		r.file = file
+		r.w = bufio.NewWriterSize(file, 64*1024)
+	}
+	if r.fileRows%indexStride == 0 {
+		r.index = append(r.index, r.fileBytes)
+	}
+	if _, err := r.w.Write(row); err != nil {
+		return fmt.Errorf("failed to write spill file: %v", err)
+	}
+	if err := r.w.WriteByte('\n'); err != nil {
+		return fmt.Errorf("failed to write spill file: %v", err)
+	}
+	r.fileBytes += int64(len(row)) + 1
+	r.fileRows++
+	r.bytes += int64(len(row))
+	return nil
+}
+
+// read returns up to maxRows rows starting at offset whose total size stays within maxBytes.
+// At least one row is returned when any is left, so a single large row cannot block the reader.
+func (r *resultStore) read(offset, maxRows, maxBytes int) ([][]byte, error) {
+	var out [][]byte
+	size := 0
+	take := func(row []byte) bool {
+		if len(out) >= maxRows || (len(out) > 0 && size+len(row) > maxBytes) {
+			return false
+		}
+		out = append(out, row)
+		size += len(row)
+		return true
+	}
+
+	for offset < len(r.memory) {
+		if !take(r.memory[offset]) {
+			return out, nil
+		}
+		offset++
+	}
+	if r.file == nil || offset-len(r.memory) >= r.fileRows {
+		return out, nil
+	}
+
+	if err := r.w.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write spill file: %v", err)
+	}
+	fileRow := offset - len(r.memory)
+	start := r.index[fileRow/indexStride]
+	reader := bufio.NewReader(io.NewSectionReader(r.file, start, r.fileBytes-start))
+	for i := fileRow / indexStride * indexStride; i < r.fileRows; i++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spill file: %v", err)
+		}
+		if i < fileRow {
+			continue
+		}
+		if !take(bytes.TrimSuffix(line, []byte{'\n'})) {
+			break
+		}
+	}
+	return out, nil
+}
+
+// close releases the rows and removes the spill file.
+func (r *resultStore) close() {
+	r.closed = true
+	r.memory = nil
+	if r.file != nil {
+		r.file.Close()
+		os.Remove(r.file.Name())
+		r.file = nil
+	}
+}
@@ -0,0 +1,415 @@
+// Package policy decides whether write statements may run against a database.
+//
+// A Policy is loaded from a JSON file and holds default Rules for the server plus optional
+// per-tenant Rules. Static rules are checked against the statement text before it runs;
+// max_affected_rows is checked by the tools with a rolled-back pre-check.
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+)
+
+// Rule names reported by a Violation; they match the JSON field names of Rules.
+const (
+	RuleBlockStatements = "block_statements"
+	RuleRequireWhere    = "require_where"
+	RuleMaxAffectedRows = "max_affected_rows"
+	RuleDenyDatabases   = "deny_databases"
+	RuleDenyTables      = "deny_tables"
+
+	RuleConfirmStatements      = "confirm_statements"
+	RuleConfirmUnboundedWrites = "confirm_unbounded_writes"
+	RuleConfirmAlterRows       = "confirm_alter_rows"
+)
+
+// RuleUnparsable is reported for SQL the lexer cannot split, since no rule can be checked on it.
+const RuleUnparsable = "unparsable_sql"
+
+// Values of Rules.ConfirmFallback.
+const (
+	FallbackRefuse = "refuse"
+	FallbackAllow  = "allow"
+)
+
+// Rules is the write policy of one database.
+type Rules struct {
+	// BlockStatements lists refused statement kinds. An entry is a statement prefix such as
+	// "DROP DATABASE", "TRUNCATE" or "GRANT", or a category such as "ddl" or "dcl".
+	BlockStatements []string `json:"block_statements,omitempty"`
+	// RequireWhere refuses UPDATE and DELETE statements without a WHERE clause.
+	RequireWhere bool `json:"require_where,omitempty"`
+	// MaxAffectedRows caps the rows one DML statement may change (0 disables the cap).
+	MaxAffectedRows int64 `json:"max_affected_rows,omitempty"`
+	// DenyDatabases refuses writes to objects in these databases.
+	DenyDatabases []string `json:"deny_databases,omitempty"`
+	// DenyTables refuses writes to these tables, named "table" or "database.table".
+	DenyTables []string `json:"deny_tables,omitempty"`
+
+	// ConfirmStatements lists statement kinds that run only after the user confirms them,
+	// matched like BlockStatements.
+	ConfirmStatements []string `json:"confirm_statements,omitempty"`
+	// ConfirmUnboundedWrites asks for confirmation of UPDATE and DELETE without a WHERE clause.
+	ConfirmUnboundedWrites bool `json:"confirm_unbounded_writes,omitempty"`
+	// ConfirmAlterRows asks for confirmation of ALTER TABLE on tables with at least this many
+	// estimated rows (0 disables it).
+	ConfirmAlterRows int64 `json:"confirm_alter_rows,omitempty"`
+	// ConfirmFallback is what happens when a statement needs confirmation but the client cannot
+	// be asked: "refuse" (the default) or "allow".
+	ConfirmFallback string `json:"confirm_fallback,omitempty"`
+}
+
+// Policy holds the server default rules and per-tenant overrides.
+type Policy struct {
+	Default Rules `json:"default"`
+	// Tenants replaces Default for a database. Keys are matched against the connection string
+	// as "host:port/database" first, then as "database".
+	Tenants map[string]Rules `json:"tenants,omitempty"`
+}
+
+// Violation reports the rule that refused a statement.
+type Violation struct {
+	Rule string `json:"rule"`
+	// Statement is the operation keyword of the refused statement, e.g. DELETE.
+	Statement string `json:"statement"`
+	Message   string `json:"message"`
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("rejected by write policy rule %s: %s", v.Rule, v.Message)
+}
+
+// Load reads a policy from a JSON file.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read write policy: %v", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes and validates a JSON policy. Unknown fields are rejected so that a misspelt
+// rule does not silently leave writes unguarded.
+func Parse(data []byte) (*Policy, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var p Policy
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("invalid write policy: %v", err)
+	}
+	if err := p.Default.validate(); err != nil {
+		return nil, fmt.Errorf("invalid write policy default: %v", err)
+	}
+	for key, rules := range p.Tenants {
+		if err := rules.validate(); err != nil {
+			return nil, fmt.Errorf("invalid write policy for tenant %q: %v", key, err)
+		}
+	}
+	return &p, nil
+}
+
+func (r Rules) validate() error {
+	if r.MaxAffectedRows < 0 {
+		return fmt.Errorf("max_affected_rows must not be negative")
+	}
+	if r.ConfirmAlterRows < 0 {
+		return fmt.Errorf("confirm_alter_rows must not be negative")
+	}
+	switch r.ConfirmFallback {
+	case "", FallbackRefuse, FallbackAllow:
+	default:
+		return fmt.Errorf("confirm_fallback must be %q or %q, got %q", FallbackRefuse, FallbackAllow, r.ConfirmFallback)
+	}
+	for name, list := range map[string][]string{
+		RuleBlockStatements:   r.BlockStatements,
+		RuleDenyDatabases:     r.DenyDatabases,
+		RuleDenyTables:        r.DenyTables,
+		RuleConfirmStatements: r.ConfirmStatements,
+	} {
+		for _, entry := range list {
+			if strings.TrimSpace(entry) == "" {
+				return fmt.Errorf("%s contains an empty entry", name)
+			}
+		}
+	}
+	return nil
+}
+
+// RulesFor returns the rules of the first tenant key found in the policy, or the default
+// rules. keys are ordered most specific first, as returned by db.TenantKeys. A nil policy
+// has no rules.
+func (p *Policy) RulesFor(keys []string) Rules {
+	if p == nil {
+		return Rules{}
+	}
+	for _, key := range keys {
+		if rules, ok := p.Tenants[key]; ok {
+			return rules
+		}
+	}
+	return p.Default
+}
+
+// DependsOnDatabase reports whether the rules applied to a connection depend on its database:
+// the policy has per-tenant rules, or deny_databases or deny_tables entries. A nil policy has
+// no rules.
+func (p *Policy) DependsOnDatabase() bool {
+	if p == nil {
+		return false
+	}
+	if len(p.Tenants) > 0 {
+		return true
+	}
+	return len(p.Default.DenyDatabases) > 0 || len(p.Default.DenyTables) > 0
+}
+
+// Check applies the static rules to every statement of sql and returns the first violation.
+// database is the current database of the connection, used for unqualified names.
+// Text that cannot be parsed is refused, so that it cannot bypass the rules.
+func (r Rules) Check(sql, database string) *Violation {
+	statements, err := db.SplitStatements(sql)
+	if err != nil {
+		return unparsable(err)
+	}
+	for _, stmt := range statements {
+		if v := r.CheckStatement(stmt, database); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// unparsable reports SQL the lexer failed on.
+func unparsable(err error) *Violation {
+	return &Violation{
+		Rule:    RuleUnparsable,
+		Message: fmt.Sprintf("the SQL cannot be parsed, so the write policy cannot be checked: %v", err),
+	}
+}
+
+// CheckStatement applies the static rules to one statement.
+func (r Rules) CheckStatement(stmt db.Statement, database string) *Violation {
+	if !stmt.Category.IsWrite() {
+		return nil
+	}
+	toks := db.SignificantTokens(stmt.Tokens)
+
+	for _, entry := range r.BlockStatements {
+		if matchesStatement(entry, stmt, toks) {
+			return &Violation{
+				Rule:      RuleBlockStatements,
+				Statement: stmt.Keyword,
+				Message:   fmt.Sprintf("%s statements are blocked (%q)", statementName(toks), entry),
+			}
+		}
+	}
+
+	if r.RequireWhere {
+		if verb, ok := missingWhere(toks); ok {
+			return &Violation{
+				Rule:      RuleRequireWhere,
+				Statement: stmt.Keyword,
+				Message:   fmt.Sprintf("%s without a WHERE clause is not allowed", verb),
+			}
+		}
+	}
+
+	if len(r.DenyDatabases) == 0 && len(r.DenyTables) == 0 {
+		return nil
+	}
+	for _, target := range writeTargets(toks, database) {
+		for _, denied := range r.DenyDatabases {
+			if target.database != "" && strings.EqualFold(target.database, strings.TrimSpace(denied)) {
+				return &Violation{
+					Rule:      RuleDenyDatabases,
+					Statement: stmt.Keyword,
+					Message:   fmt.Sprintf("writes to database %s are not allowed", target.database),
+				}
+			}
+		}
+		if target.table == "" {
+			continue
+		}
+		for _, denied := range r.DenyTables {
+			if target.matchesTable(strings.TrimSpace(denied)) {
+				return &Violation{
+					Rule:      RuleDenyTables,
+					Statement: stmt.Keyword,
+					Message:   fmt.Sprintf("writes to table %s are not allowed", target),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// CheckAffectedRows applies max_affected_rows to the row count of a pre-checked statement.
+func (r Rules) CheckAffectedRows(keyword string, affected int64) *Violation {
+	if r.MaxAffectedRows <= 0 || affected <= r.MaxAffectedRows {
+		return nil
+	}
+	return &Violation{
+		Rule:      RuleMaxAffectedRows,
+		Statement: keyword,
+		Message:   fmt.Sprintf("%s would change %d rows, more than the limit of %d", keyword, affected, r.MaxAffectedRows),
+	}
+}
+
+// Confirmation explains why a statement needs the user's confirmation before it runs.
+type Confirmation struct {
+	Rule      string `json:"rule"`
+	Statement string `json:"statement"`
+	Reason    string `json:"reason"`
+	// Tables are the tables the statement changes, as "database.table".
+	Tables []string `json:"tables,omitempty"`
+}
+
+// RowEstimator returns the estimated row count of a table, and false when it is unknown.
+type RowEstimator func(database, table string) (int64, bool)
+
+// ConfirmationFor returns why stmt needs confirmation, or nil when it may run as is.
+// estimate is only called for ALTER TABLE when confirm_alter_rows is set.
+func (r Rules) ConfirmationFor(stmt db.Statement, database string, estimate RowEstimator) *Confirmation {
+	if !stmt.Category.IsWrite() {
+		return nil
+	}
+	toks := db.SignificantTokens(stmt.Tokens)
+	var tables []string
+	for _, target := range writeTargets(toks, database) {
+		if target.table != "" {
+			tables = append(tables, target.String())
+		}
+	}
+	confirmation := func(rule, reason string) *Confirmation {
+		return &Confirmation{Rule: rule, Statement: stmt.Keyword, Reason: reason, Tables: tables}
+	}
+
+	for _, entry := range r.ConfirmStatements {
+		if matchesStatement(entry, stmt, toks) {
+			return confirmation(RuleConfirmStatements, fmt.Sprintf("%s statements need confirmation", statementName(toks)))
+		}
+	}
+	if r.ConfirmUnboundedWrites {
+		if verb, ok := missingWhere(toks); ok {
+			return confirmation(RuleConfirmUnboundedWrites, fmt.Sprintf("%s without a WHERE clause changes every row", verb))
+		}
+	}
+	if r.ConfirmAlterRows > 0 && estimate != nil && statementName(toks) == "ALTER TABLE" {
+		for _, target := range writeTargets(toks, database) {
+			if target.table == "" {
+				continue
+			}
+			if rows, ok := estimate(target.database, target.table); ok && rows >= r.ConfirmAlterRows {
+				return confirmation(RuleConfirmAlterRows, fmt.Sprintf("ALTER TABLE on %s, which has about %d rows", target, rows))
+			}
+		}
+	}
+	return nil
+}
+
+// AllowsUnconfirmed reports whether statements needing confirmation run anyway when the client
+// cannot be asked.
+func (r Rules) AllowsUnconfirmed() bool {
+	return r.ConfirmFallback == FallbackAllow
+}
+
+// statementModifiers are words between the verb and the object type that blocking ignores,
+// so "DROP VIEW" also blocks DROP MATERIALIZED VIEW and "CREATE DATABASE" blocks CREATE TS DATABASE.
+var statementModifiers = map[string]bool{
+	"OR": true, "REPLACE": true, "TEMP": true, "TEMPORARY": true, "UNIQUE": true,
+	"INVERTED": true, "MATERIALIZED": true, "TS": true, "UNLOGGED": true,
+}
+
+// statementWords returns the leading keywords of a statement with modifiers removed.
+func statementWords(toks []db.Token) []string {
+	var words []string
+	for _, tok := range toks {
+		kw := tok.Keyword()
+		if kw == "" {
+			break
+		}
+		if len(words) == 1 && statementModifiers[kw] {
+			continue
+		}
+		words = append(words, kw)
+	}
+	return words
+}
+
+// statementName names a statement by its verb and, for CREATE, ALTER and DROP, its object type.
+func statementName(toks []db.Token) string {
+	words := statementWords(toks)
+	switch {
+	case len(words) == 0:
+		return ""
+	case len(words) > 1 && (words[0] == "CREATE" || words[0] == "ALTER" || words[0] == "DROP"):
+		return words[0] + " " + words[1]
+	default:
+		return words[0]
+	}
+}
+
+// matchesStatement reports whether a block_statements entry matches stmt.
+func matchesStatement(entry string, stmt db.Statement, toks []db.Token) bool {
+	entry = strings.TrimSpace(entry)
+	if strings.EqualFold(entry, string(stmt.Category)) || strings.EqualFold(entry, stmt.Keyword) {
+		return true
+	}
+	want := strings.Fields(strings.ToUpper(entry))
+	words := statementWords(toks)
+	if len(want) > len(words) {
+		return false
+	}
+	for i := range want {
+		if want[i] != words[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// missingWhere returns the verb of the first UPDATE or DELETE, including one inside a WITH
+// statement, that has no WHERE clause of its own.
+func missingWhere(toks []db.Token) (string, bool) {
+	for i, tok := range toks {
+		if !tok.Is("UPDATE", "DELETE") || !isStatementStart(toks, i) {
+			continue
+		}
+		depth := 0
+		found := false
+	scan:
+		for _, next := range toks[i+1:] {
+			switch {
+			case next.IsOperator("(") || next.IsOperator("["):
+				depth++
+			case next.IsOperator(")") || next.IsOperator("]"):
+				depth--
+				if depth < 0 {
+					break scan
+				}
+			case depth == 0 && next.Is("WHERE"):
+				found = true
+				break scan
+			}
+		}
+		if !found {
+			return tok.Keyword(), true
+		}
+	}
+	return "", false
+}
+
+// isStatementStart reports whether toks[i] begins a statement: the first word, or the word
+// after an opening bracket or a closing parenthesis, as in WITH x AS (...) DELETE ...
+func isStatementStart(toks []db.Token, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := toks[i-1]
+	return prev.IsOperator("(") || prev.IsOperator("[") || prev.IsOperator(")")
+}
@@ -0,0 +1,184 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+)
+
+func TestParse(t *testing.T) {
+	p, err := Parse([]byte(`{
+		"default": {"block_statements": ["DROP DATABASE", "dcl"], "require_where": true},
+		"tenants": {"analytics": {"max_affected_rows": 100}}
+	}`))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if !p.Default.RequireWhere || len(p.Default.BlockStatements) != 2 {
+		t.Fatalf("unexpected default rules: %+v", p.Default)
+	}
+	if got := p.RulesFor([]string{"db.local:26257/analytics", "analytics"}); got.MaxAffectedRows != 100 || got.RequireWhere {
+		t.Fatalf("tenant rules should replace the default, got %+v", got)
+	}
+	if got := p.RulesFor([]string{"db.local:26257/other", "other"}); !got.RequireWhere {
+		t.Fatalf("expected default rules, got %+v", got)
+	}
+
+	for _, bad := range []string{
+		`{"default": {"require_were": true}}`,
+		`{"default": {"max_affected_rows": -1}}`,
+		`{"tenants": {"iot": {"deny_tables": [" "]}}}`,
+		`not json`,
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Fatalf("Parse(%s) should fail", bad)
+		}
+	}
+
+	var nilPolicy *Policy
+	if got := nilPolicy.RulesFor([]string{"iot"}); got.RequireWhere || got.MaxAffectedRows != 0 {
+		t.Fatalf("a nil policy should have no rules, got %+v", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(`{"default": {"deny_databases": ["system"]}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(p.Default.DenyDatabases) != 1 {
+		t.Fatalf("unexpected rules: %+v", p.Default)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("Load should fail for a missing file")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	rules := Rules{
+		BlockStatements: []string{"DROP DATABASE", "TRUNCATE", "dcl"},
+		RequireWhere:    true,
+		DenyDatabases:   []string{"system"},
+		DenyTables:      []string{"audit_log", "shop.orders"},
+	}
+
+	tests := []struct {
+		sql  string
+		rule string
+	}{
+		{"SELECT * FROM audit_log", ""},
+		{"DROP DATABASE shop", RuleBlockStatements},
+		{"drop database if exists shop cascade", RuleBlockStatements},
+		{"DROP TABLE t", ""},
+		{"TRUNCATE t", RuleBlockStatements},
+		{"GRANT SELECT ON TABLE t TO bob", RuleBlockStatements},
+		{"CREATE USER bob", RuleBlockStatements},
+		{"DELETE FROM t", RuleRequireWhere},
+		{"DELETE FROM t WHERE id = 1", ""},
+		{"UPDATE t SET a = 1", RuleRequireWhere},
+		{"UPDATE t SET a = (SELECT b FROM u WHERE u.id = 1)", RuleRequireWhere},
+		{"UPDATE t SET a = 1 WHERE id IN (SELECT id FROM u)", ""},
+		{"WITH d AS (DELETE FROM t RETURNING id) SELECT * FROM d", RuleRequireWhere},
+		{"INSERT INTO t VALUES (1) ON CONFLICT (id) DO UPDATE SET a = 2", ""},
+		{"INSERT INTO audit_log VALUES (1)", RuleDenyTables},
+		{`INSERT INTO "AUDIT_LOG" VALUES (1)`, RuleDenyTables},
+		{"INSERT INTO public.audit_log VALUES (1)", RuleDenyTables},
+		{"INSERT INTO other.audit_log VALUES (1)", RuleDenyTables},
+		{"UPDATE orders SET a = 1 WHERE id = 1", RuleDenyTables},
+		{"UPDATE other.orders SET a = 1 WHERE id = 1", ""},
+		{"INSERT INTO system.users VALUES (1)", RuleDenyDatabases},
+		{"ALTER TABLE system.public.jobs ADD COLUMN x INT", RuleDenyDatabases},
+		{"DROP INDEX audit_log@idx", RuleDenyTables},
+		{"CREATE INDEX idx ON audit_log (a)", RuleDenyTables},
+		{"CREATE TS DATABASE system", RuleDenyDatabases},
+		{"DELETE FROM t WHERE a = 'unterminated", RuleUnparsable},
+	}
+	for _, tt := range tests {
+		v := rules.Check(tt.sql, "shop")
+		switch {
+		case tt.rule == "" && v != nil:
+			t.Errorf("Check(%q) = %v, want no violation", tt.sql, v)
+		case tt.rule != "" && v == nil:
+			t.Errorf("Check(%q) = nil, want rule %s", tt.sql, tt.rule)
+		case v != nil && v.Rule != tt.rule:
+			t.Errorf("Check(%q) fired %s, want %s", tt.sql, v.Rule, tt.rule)
+		}
+	}
+
+	// Writes with no named target fall back to the current database.
+	denied := Rules{DenyDatabases: []string{"shop"}}
+	if v := denied.Check("CANCEL JOB 1", "shop"); v == nil || v.Rule != RuleDenyDatabases {
+		t.Fatalf("expected deny_databases for the current database, got %v", v)
+	}
+	if v := denied.Check("INSERT INTO other.t VALUES (1)", "shop"); v != nil {
+		t.Fatalf("a write to another database should pass, got %v", v)
+	}
+}
+
+func TestCheckAffectedRows(t *testing.T) {
+	rules := Rules{MaxAffectedRows: 10}
+	if v := rules.CheckAffectedRows("DELETE", 10); v != nil {
+		t.Fatalf("10 rows should be within the cap, got %v", v)
+	}
+	v := rules.CheckAffectedRows("DELETE", 11)
+	if v == nil || v.Rule != RuleMaxAffectedRows {
+		t.Fatalf("expected max_affected_rows, got %v", v)
+	}
+	if v.Error() != "rejected by write policy rule max_affected_rows: DELETE would change 11 rows, more than the limit of 10" {
+		t.Fatalf("unexpected message: %s", v.Error())
+	}
+	if v := (Rules{}).CheckAffectedRows("DELETE", 1000); v != nil {
+		t.Fatalf("no cap should allow any count, got %v", v)
+	}
+}
+
+func TestConfirmationFor(t *testing.T) {
+	rules := Rules{
+		ConfirmStatements:      []string{"DROP", "TRUNCATE"},
+		ConfirmUnboundedWrites: true,
+		ConfirmAlterRows:       1000,
+	}
+	estimate := func(database, table string) (int64, bool) {
+		if database == "shop" && table == "orders" {
+			return 5000, true
+		}
+		return 10, true
+	}
+
+	tests := []struct {
+		sql  string
+		rule string
+	}{
+		{"DROP TABLE orders", RuleConfirmStatements},
+		{"TRUNCATE orders", RuleConfirmStatements},
+		{"DELETE FROM orders", RuleConfirmUnboundedWrites},
+		{"DELETE FROM orders WHERE id = 1", ""},
+		{"ALTER TABLE orders ADD COLUMN note STRING", RuleConfirmAlterRows},
+		{"ALTER TABLE small ADD COLUMN note STRING", ""},
+		{"SELECT * FROM orders", ""},
+	}
+	for _, tt := range tests {
+		statements, _ := db.SplitStatements(tt.sql)
+		c := rules.ConfirmationFor(statements[0], "shop", estimate)
+		switch {
+		case tt.rule == "" && c != nil:
+			t.Errorf("ConfirmationFor(%q) = %+v, want none", tt.sql, c)
+		case tt.rule != "" && (c == nil || c.Rule != tt.rule):
+			t.Errorf("ConfirmationFor(%q) = %+v, want rule %s", tt.sql, c, tt.rule)
+		}
+	}
+
+	statements, _ := db.SplitStatements("DROP TABLE orders")
+	if c := rules.ConfirmationFor(statements[0], "shop", estimate); len(c.Tables) != 1 || c.Tables[0] != "shop.orders" {
+		t.Fatalf("confirmation should name the changed table, got %+v", c)
+	}
+	if _, err := Parse([]byte(`{"default": {"confirm_fallback": "maybe"}}`)); err == nil {
+		t.Fatal("an unknown confirm_fallback should be rejected")
+	}
+}
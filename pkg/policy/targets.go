@@ -0,0 +1,192 @@
+package policy
+
+import (
+	"strings"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+)
+
+// target is an object a write statement changes. table is empty for a whole database.
+type target struct {
+	database string
+	table    string
+}
+
+func (t target) String() string {
+	if t.database == "" {
+		return t.table
+	}
+	return t.database + "." + t.table
+}
+
+// matchesTable reports whether a deny_tables entry, "table" or "database.table", names t.
+func (t target) matchesTable(entry string) bool {
+	if db, table, ok := strings.Cut(entry, "."); ok {
+		return strings.EqualFold(db, t.database) && strings.EqualFold(table, t.table)
+	}
+	return strings.EqualFold(entry, t.table)
+}
+
+// schemaNames are schemas a two-part name may start with; other prefixes name a database.
+var schemaNames = map[string]bool{
+	"public": true, "pg_catalog": true, "information_schema": true, "crdb_internal": true, "pg_extension": true,
+}
+
+// writeTargets returns the databases and tables a write statement changes. Unqualified names
+// resolve to database. When no target can be found, as for IMPORT or CANCEL statements, the
+// current database is the target.
+func writeTargets(toks []db.Token, database string) []target {
+	var targets []target
+	for i, tok := range toks {
+		if i > 0 && !isStatementStart(toks, i) {
+			continue
+		}
+		switch tok.Keyword() {
+		case "INSERT", "UPSERT":
+			if j := indexOfWord(toks, i+1, "INTO"); j >= 0 {
+				targets = append(targets, tableNames(toks, j+1, database, false)...)
+			}
+		case "UPDATE":
+			j := i + 1
+			if j < len(toks) && toks[j].Is("ONLY") {
+				j++
+			}
+			targets = append(targets, tableNames(toks, j, database, false)...)
+		case "DELETE":
+			if j := indexOfWord(toks, i+1, "FROM"); j >= 0 {
+				targets = append(targets, tableNames(toks, j+1, database, false)...)
+			}
+		case "TRUNCATE":
+			j := i + 1
+			if j < len(toks) && toks[j].Is("TABLE") {
+				j++
+			}
+			targets = append(targets, tableNames(toks, j, database, true)...)
+		case "CREATE", "ALTER", "DROP", "COMMENT", "BACKUP", "RESTORE", "IMPORT":
+			targets = append(targets, objectTargets(toks[i:], database)...)
+		case "GRANT", "REVOKE":
+			if j := indexOfWord(toks, i+1, "ON"); j >= 0 {
+				targets = append(targets, objectTargets(toks[j:], database)...)
+			}
+		}
+	}
+	if len(targets) == 0 && database != "" {
+		targets = append(targets, target{database: database})
+	}
+	return targets
+}
+
+// objectTargets finds the object type word in a DDL, DCL or admin statement and returns the
+// names that follow it, e.g. DROP TABLE IF EXISTS a, b or GRANT ... ON DATABASE d.
+func objectTargets(toks []db.Token, database string) []target {
+	for j := 1; j < len(toks) && toks[j].Kind == db.TokenWord; j++ {
+		switch toks[j].Keyword() {
+		case "DATABASE":
+			var targets []target
+			for _, name := range names(toks, skipIfExists(toks, j+1), true) {
+				targets = append(targets, target{database: name[len(name)-1]})
+			}
+			return targets
+		case "TABLE", "VIEW", "SEQUENCE", "INTO":
+			return tableNames(toks, skipIfExists(toks, j+1), database, true)
+		case "INDEX":
+			// CREATE INDEX name ON table; DROP and ALTER INDEX name the table as table@index.
+			if k := indexOfWord(toks, j+1, "ON"); k >= 0 && toks[0].Is("CREATE") {
+				return tableNames(toks, k+1, database, false)
+			}
+			return tableNames(toks, skipIfExists(toks, j+1), database, true)
+		case "SCHEMA", "COLUMN", "CONSTRAINT":
+			return []target{{database: database}}
+		}
+	}
+	return nil
+}
+
+// tableNames reads one table name at toks[i], or a comma-separated list when list is set.
+func tableNames(toks []db.Token, i int, database string, list bool) []target {
+	var targets []target
+	for _, name := range names(toks, i, list) {
+		switch len(name) {
+		case 1:
+			targets = append(targets, target{database: database, table: name[0]})
+		case 2:
+			if schemaNames[strings.ToLower(name[0])] {
+				targets = append(targets, target{database: database, table: name[1]})
+			} else {
+				targets = append(targets, target{database: name[0], table: name[1]})
+			}
+		default:
+			targets = append(targets, target{database: name[0], table: name[len(name)-1]})
+		}
+	}
+	return targets
+}
+
+// names reads dotted names starting at toks[i]. A name ends at @, as in table@index.
+func names(toks []db.Token, i int, list bool) [][]string {
+	var result [][]string
+	for i < len(toks) {
+		var name []string
+		for i < len(toks) {
+			part, ok := identifier(toks[i])
+			if !ok {
+				break
+			}
+			name = append(name, part)
+			i++
+			if i < len(toks) && toks[i].IsOperator(".") {
+				i++
+				continue
+			}
+			break
+		}
+		if len(name) == 0 {
+			break
+		}
+		result = append(result, name)
+		if i < len(toks) && toks[i].IsOperator("@") {
+			i += 2
+		}
+		if !list || i >= len(toks) || !toks[i].IsOperator(",") {
+			break
+		}
+		i++
+	}
+	return result
+}
+
+// identifier returns the name of a word or quoted identifier token. Unquoted words are folded
+// to lower case like KWDB does.
+func identifier(tok db.Token) (string, bool) {
+	switch tok.Kind {
+	case db.TokenWord:
+		return strings.ToLower(tok.Text), true
+	case db.TokenQuotedIdent:
+		return strings.ReplaceAll(strings.Trim(tok.Text, `"`), `""`, `"`), true
+	}
+	return "", false
+}
+
+// skipIfExists skips IF EXISTS or IF NOT EXISTS at toks[i].
+func skipIfExists(toks []db.Token, i int) int {
+	if i < len(toks) && toks[i].Is("IF") {
+		i++
+		if i < len(toks) && toks[i].Is("NOT") {
+			i++
+		}
+		if i < len(toks) && toks[i].Is("EXISTS") {
+			i++
+		}
+	}
+	return i
+}
+
+// indexOfWord returns the position of the first keyword token at or after i, or -1.
+func indexOfWord(toks []db.Token, i int, keyword string) int {
+	for ; i < len(toks); i++ {
+		if toks[i].Is(keyword) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,70 @@
+package resources
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/ctxutil"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/export"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// maxExportResourceBytes caps the size of an export read through its resource.
+const maxExportResourceBytes = 100 << 20
+
+// RegisterExportResourceTemplate registers the resource template of files written by export-query.
+func RegisterExportResourceTemplate(s *server.MCPServer, store *export.Store) {
+	// 模板 URI
+	templateURI := export.URIPrefix + "{id}"
+
+	exportTemplate := mcp.NewResourceTemplate(
+		templateURI,
+		"Query Export",
+		mcp.WithTemplateDescription("A file written by export-query, as CSV, NDJSON or Parquet (base64), readable for the same database until it expires"),
+	)
+
+	s.AddResourceTemplate(exportTemplate, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		uri := request.Params.URI
+		id, err := extractParamFromURI(uri, templateURI, "id")
+		if err != nil {
+			return nil, fmt.Errorf("invalid URI format for export resource: %v", err)
+		}
+
+		// 导出属于写入它的数据库，只能通过相同的 X-Database-URI 读取
+		path, format, err := store.Open(id, ctxutil.GetDatabaseURI(ctx))
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read export %s: %v", id, err)
+		}
+		if info.Size() > maxExportResourceBytes {
+			return nil, fmt.Errorf("export %s is larger than %d MiB; read it from %s instead", id, maxExportResourceBytes>>20, path)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read export %s: %v", id, err)
+		}
+
+		if export.IsText(format) {
+			return []mcp.ResourceContents{
+				mcp.TextResourceContents{
+					URI:      uri,
+					MIMEType: export.MIMEType(format),
+					Text:     string(content),
+				},
+			}, nil
+		}
+		return []mcp.ResourceContents{
+			mcp.BlobResourceContents{
+				URI:      uri,
+				MIMEType: export.MIMEType(format),
+				Blob:     base64.StdEncoding.EncodeToString(content),
+			},
+		}, nil
+	})
+}
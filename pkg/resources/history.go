@@ -0,0 +1,56 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/audit"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/ctxutil"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// HistoryURI is the resource listing the recent SQL calls of the reading session.
+	HistoryURI = "kwdb://history"
+	// historyResourceEntries is the number of calls the history resource lists.
+	historyResourceEntries = 50
+)
+
+// RegisterHistoryResource registers the resource that lists the recent SQL calls of the session
+// reading it on the current database.
+func RegisterHistoryResource(s *server.MCPServer, history *audit.History) {
+	historyResource := mcp.NewResource(
+		HistoryURI,
+		"Query History",
+		mcp.WithResourceDescription("The recent SQL tool calls of this session on the current database, newest first, with their status, duration and row count"),
+		mcp.WithMIMEType("application/json"),
+	)
+
+	s.AddResource(historyResource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		var sessionID string
+		if session := server.ClientSessionFromContext(ctx); session != nil {
+			sessionID = session.SessionID()
+		}
+		entries := history.Query(ctxutil.GetDatabaseURI(ctx), audit.HistoryFilter{
+			SessionID: sessionID,
+			Limit:     historyResourceEntries,
+		})
+
+		historyJSON, err := json.MarshalIndent(map[string]interface{}{
+			"session_id": sessionID,
+			"entries":    entries,
+		}, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal query history: %v", err)
+		}
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      HistoryURI,
+				MIMEType: "application/json",
+				Text:     string(historyJSON),
+			},
+		}, nil
+	})
+}
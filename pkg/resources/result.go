@@ -0,0 +1,189 @@
+package resources
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// ResultURIPrefix starts the resource URI of a stored query result; the result ID follows it.
+	ResultURIPrefix = "kwdb://result/"
+	// resultTTL is how long a stored result stays readable.
+	resultTTL = 10 * time.Minute
+	// maxResultsPerSession bounds the results stored for one session; the oldest is dropped first.
+	maxResultsPerSession = 8
+	// maxResultBytes bounds the text kept by all sessions together; the oldest results are dropped first.
+	maxResultBytes = 256 << 20
+)
+
+// StoredResult is a query result kept for a session until it expires.
+type StoredResult struct {
+	ID        string
+	URI       string
+	MIMEType  string
+	Text      string
+	ExpiresAt time.Time
+
+	sessionID string
+	createdAt time.Time
+}
+
+// ResultStore keeps rendered query results in memory so clients can read them as resources.
+// A result can only be read from the session that stored it.
+type ResultStore struct {
+	mu       sync.Mutex
+	results  map[string]*StoredResult
+	bytes    int
+	maxBytes int
+	now      func() time.Time
+}
+
+// NewResultStore returns an empty store.
+func NewResultStore() *ResultStore {
+	return &ResultStore{
+		results:  make(map[string]*StoredResult),
+		maxBytes: maxResultBytes,
+		now:      time.Now,
+	}
+}
+
+// Put stores text for sessionID and returns the stored result.
+// Results need a session, since only the session that stored a result can read it.
+func (s *ResultStore) Put(sessionID, mimeType, text string) (*StoredResult, error) {
+	if sessionID == "" {
+		return nil, fmt.Errorf("result resources need a client session")
+	}
+	if len(text) > s.maxBytes {
+		return nil, fmt.Errorf("result of %d bytes exceeds the %d-byte result store limit", len(text), s.maxBytes)
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate result ID: %v", err)
+	}
+	id := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.evictExpiredLocked(now)
+
+	// 同一会话的结果数达到上限时，淘汰最早存储的结果
+	var oldest *StoredResult
+	owned := 0
+	for _, r := range s.results {
+		if r.sessionID != sessionID {
+			continue
+		}
+		owned++
+		if oldest == nil || r.createdAt.Before(oldest.createdAt) {
+			oldest = r
+		}
+	}
+	if owned >= maxResultsPerSession {
+		s.deleteLocked(oldest)
+	}
+	// 所有会话的结果总大小超出预算时，按存储时间从早到晚淘汰
+	for s.bytes+len(text) > s.maxBytes {
+		oldest = nil
+		for _, r := range s.results {
+			if oldest == nil || r.createdAt.Before(oldest.createdAt) {
+				oldest = r
+			}
+		}
+		s.deleteLocked(oldest)
+	}
+
+	r := &StoredResult{
+		ID:        id,
+		URI:       ResultURIPrefix + id,
+		MIMEType:  mimeType,
+		Text:      text,
+		sessionID: sessionID,
+		createdAt: now,
+		ExpiresAt: now.Add(resultTTL),
+	}
+	s.results[id] = r
+	s.bytes += len(text)
+	return r, nil
+}
+
+// Get returns the result with id if it belongs to sessionID and has not expired.
+func (s *ResultStore) Get(id, sessionID string) (*StoredResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictExpiredLocked(s.now())
+	r, ok := s.results[id]
+	if !ok || r.sessionID != sessionID {
+		return nil, fmt.Errorf("result %s not found or expired", id)
+	}
+	return r, nil
+}
+
+// CloseSession drops the results of a closed session.
+func (s *ResultStore) CloseSession(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.results {
+		if r.sessionID == sessionID {
+			s.deleteLocked(r)
+		}
+	}
+}
+
+func (s *ResultStore) evictExpiredLocked(now time.Time) {
+	for _, r := range s.results {
+		if !now.Before(r.ExpiresAt) {
+			s.deleteLocked(r)
+		}
+	}
+}
+
+func (s *ResultStore) deleteLocked(r *StoredResult) {
+	delete(s.results, r.ID)
+	s.bytes -= len(r.Text)
+}
+
+// RegisterResultResourceTemplate registers the resource template of results stored by read-query.
+func RegisterResultResourceTemplate(s *server.MCPServer, store *ResultStore) {
+	// 模板 URI
+	templateURI := ResultURIPrefix + "{id}"
+
+	resultTemplate := mcp.NewResourceTemplate(
+		templateURI,
+		"Query Result",
+		mcp.WithTemplateDescription("The full result of a read-query call made with as_resource, readable by the same session until it expires"),
+	)
+
+	s.AddResourceTemplate(resultTemplate, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		uri := request.Params.URI
+		id, err := extractParamFromURI(uri, templateURI, "id")
+		if err != nil {
+			return nil, fmt.Errorf("invalid URI format for result resource: %v", err)
+		}
+
+		var sessionID string
+		if session := server.ClientSessionFromContext(ctx); session != nil {
+			sessionID = session.SessionID()
+		}
+		r, err := store.Get(id, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      uri,
+				MIMEType: r.MIMEType,
+				Text:     r.Text,
+			},
+		}, nil
+	})
+}
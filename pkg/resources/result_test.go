@@ -0,0 +1,134 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestResultStore(t *testing.T) {
+	store := NewResultStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	r, err := store.Put("s1", "text/csv", "a\n1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(r.URI, ResultURIPrefix) || r.ExpiresAt != now.Add(resultTTL) {
+		t.Fatalf("unexpected stored result %+v", r)
+	}
+	if got, err := store.Get(r.ID, "s1"); err != nil || got.Text != "a\n1\n" {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+	if _, err := store.Get(r.ID, "s2"); err == nil {
+		t.Fatal("another session should not read the result")
+	}
+
+	// The oldest result of a session is dropped beyond the limit.
+	for i := 0; i < maxResultsPerSession; i++ {
+		now = now.Add(time.Millisecond)
+		if _, err := store.Put("s1", "text/csv", "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Get(r.ID, "s1"); err == nil {
+		t.Fatal("the oldest result should be dropped")
+	}
+
+	kept, _ := store.Put("s2", "text/csv", "y")
+	store.CloseSession("s1")
+	if len(store.results) != 1 {
+		t.Fatalf("closing a session should drop only its results, %d left", len(store.results))
+	}
+	now = now.Add(resultTTL)
+	if _, err := store.Get(kept.ID, "s2"); err == nil {
+		t.Fatal("a result should expire after its TTL")
+	}
+
+	if _, err := store.Put("", "text/csv", "z"); err == nil {
+		t.Fatal("a result without a session should be refused")
+	}
+}
+
+func TestResultStoreBudget(t *testing.T) {
+	store := NewResultStore()
+	store.maxBytes = 10
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	put := func(sessionID, text string) *StoredResult {
+		t.Helper()
+		now = now.Add(time.Millisecond)
+		r, err := store.Put(sessionID, "text/csv", text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	first := put("s1", "aaaa")
+	second := put("s2", "bbbb")
+	// The oldest result is dropped to make room, whichever session stored it.
+	third := put("s2", "cccc")
+	if _, err := store.Get(first.ID, "s1"); err == nil {
+		t.Fatal("the oldest result should be dropped beyond the budget")
+	}
+	if _, err := store.Get(second.ID, "s2"); err != nil {
+		t.Fatalf("a result within the budget was dropped: %v", err)
+	}
+	if store.bytes != 8 {
+		t.Fatalf("bytes = %d, want 8", store.bytes)
+	}
+
+	if _, err := store.Put("s1", "text/csv", "0123456789a"); err == nil {
+		t.Fatal("a result larger than the budget should be refused")
+	}
+	store.CloseSession("s2")
+	if store.bytes != 0 || len(store.results) != 0 {
+		t.Fatalf("closing the session left %d results, %d bytes", len(store.results), store.bytes)
+	}
+	if _, err := store.Get(third.ID, "s2"); err == nil {
+		t.Fatal("the results of a closed session should be dropped")
+	}
+}
+
+func TestResultResourceTemplate(t *testing.T) {
+	s := server.NewMCPServer("test", "1.0", server.WithResourceCapabilities(true, true))
+	store := NewResultStore()
+	RegisterResultResourceTemplate(s, store)
+
+	r, _ := store.Put("s1", "application/json", `{"columns":["a"],"rows":[[1]]}`)
+	ctx := s.WithContext(context.Background(), server.NewInProcessSession("s1", nil))
+	read := func(uri string) mcp.JSONRPCMessage {
+		message, _ := json.Marshal(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"method":  "resources/read",
+			"params":  map[string]any{"uri": uri},
+		})
+		return s.HandleMessage(ctx, message)
+	}
+
+	response, ok := read(r.URI).(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatalf("expected a response, got %+v", read(r.URI))
+	}
+	contents := response.Result.(mcp.ReadResourceResult).Contents
+	text := contents[0].(mcp.TextResourceContents)
+	if text.Text != r.Text || text.MIMEType != "application/json" {
+		t.Fatalf("unexpected contents %+v", text)
+	}
+
+	if _, ok := read(ResultURIPrefix + "0123456789abcdef0123456789abcdef").(mcp.JSONRPCError); !ok {
+		t.Fatal("an unknown result should fail")
+	}
+	ctx = context.Background()
+	if _, ok := read(r.URI).(mcp.JSONRPCError); !ok {
+		t.Fatal("a call without a session should not read the result")
+	}
+}
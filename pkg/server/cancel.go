@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// requestIDMetaKey carries the JSON-RPC id of a tool call from the before-call hook to the
+// cancellation middleware, which cannot see it otherwise.
+const requestIDMetaKey = "kwdb-mcp-server/request-id"
+
+// callRegistry tracks running tool calls per session so they can be cancelled by
+// notifications/cancelled or when the session goes away.
+type callRegistry struct {
+	mu    sync.Mutex
+	calls map[string]map[string]context.CancelCauseFunc
+}
+
+func newCallRegistry() *callRegistry {
+	return &callRegistry{calls: make(map[string]map[string]context.CancelCauseFunc)}
+}
+
+func (r *callRegistry) add(sessionID, requestID string, cancel context.CancelCauseFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.calls[sessionID] == nil {
+		r.calls[sessionID] = make(map[string]context.CancelCauseFunc)
+	}
+	r.calls[sessionID][requestID] = cancel
+}
+
+func (r *callRegistry) remove(sessionID, requestID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.calls[sessionID], requestID)
+	if len(r.calls[sessionID]) == 0 {
+		delete(r.calls, sessionID)
+	}
+}
+
+// cancel cancels one running call and reports whether it was found.
+func (r *callRegistry) cancel(sessionID, requestID, reason string) bool {
+	r.mu.Lock()
+	cancel, ok := r.calls[sessionID][requestID]
+	r.mu.Unlock()
+	if ok {
+		cancel(errors.New(reason))
+	}
+	return ok
+}
+
+// cancelSession cancels every running call of a session.
+func (r *callRegistry) cancelSession(sessionID, reason string) {
+	r.mu.Lock()
+	calls := r.calls[sessionID]
+	delete(r.calls, sessionID)
+	r.mu.Unlock()
+	for _, cancel := range calls {
+		cancel(errors.New(reason))
+	}
+}
+
+// tagRequestID is a before-call-tool hook that records the JSON-RPC id in the request meta.
+func tagRequestID(ctx context.Context, id any, request *mcp.CallToolRequest) {
+	if request.Params.Meta == nil {
+		request.Params.Meta = &mcp.Meta{}
+	}
+	if request.Params.Meta.AdditionalFields == nil {
+		request.Params.Meta.AdditionalFields = make(map[string]any)
+	}
+	request.Params.Meta.AdditionalFields[requestIDMetaKey] = requestIDString(id)
+}
+
+// middleware runs each tool call with its own cancellable context. When the call is
+// cancelled, whatever the tool returned is replaced by a response with status "cancelled";
+// the database driver has already cancelled the running statement through the context.
+func (r *callRegistry) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ctx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+
+		sessionID := sessionIDFromContext(ctx)
+		var requestID string
+		if request.Params.Meta != nil {
+			requestID, _ = request.Params.Meta.AdditionalFields[requestIDMetaKey].(string)
+			delete(request.Params.Meta.AdditionalFields, requestIDMetaKey)
+		}
+		if requestID != "" {
+			r.add(sessionID, requestID, cancel)
+			defer r.remove(sessionID, requestID)
+		}
+
+		result, err := next(ctx, request)
+		if ctx.Err() == nil {
+			return result, err
+		}
+
+		reason := "client disconnected"
+		if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, context.Canceled) {
+			reason = cause.Error()
+		}
+		log.Printf("Tool call %s (%s) of session %s cancelled: %s", request.Params.Name, requestID, sessionID, reason)
+		return cancelledResult(request.Params.Name, reason)
+	}
+}
+
+// handleCancelled is the notifications/cancelled handler.
+func (r *callRegistry) handleCancelled(ctx context.Context, notification mcp.JSONRPCNotification) {
+	id, ok := notification.Params.AdditionalFields["requestId"]
+	if !ok {
+		return
+	}
+	reason, _ := notification.Params.AdditionalFields["reason"].(string)
+	if reason == "" {
+		reason = "cancelled by client"
+	}
+	r.cancel(sessionIDFromContext(ctx), requestIDString(id), reason)
+}
+
+// cancelledResult is the standardized response of a cancelled tool call.
+func cancelledResult(tool, reason string) (*mcp.CallToolResult, error) {
+	response := map[string]interface{}{
+		"status": "cancelled",
+		"type":   "cancelled",
+		"data": map[string]interface{}{
+			"tool":   tool,
+			"reason": reason,
+		},
+		"error": fmt.Sprintf("request cancelled: %s", reason),
+	}
+
+	jsonResult, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize result: %v", err)
+	}
+	result := mcp.NewToolResultStructured(response, string(jsonResult))
+	result.IsError = true
+	return result, nil
+}
+
+// requestIDString normalizes a JSON-RPC id so that 7 and 7.0 match.
+func requestIDString(id any) string {
+	switch v := id.(type) {
+	case mcp.RequestId:
+		return v.String()
+	case *mcp.RequestId:
+		return v.String()
+	default:
+		return mcp.NewRequestId(v).String()
+	}
+}
+
+// sessionIDFromContext returns the MCP session ID of the current request, or "".
+func sessionIDFromContext(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// newCancelTestServer returns a server with one tool that blocks until its context is cancelled.
+func newCancelTestServer(calls *callRegistry, started chan struct{}) *server.MCPServer {
+	hooks := &server.Hooks{}
+	hooks.AddBeforeCallTool(tagRequestID)
+	s := server.NewMCPServer("test", "1.0.0",
+		server.WithToolCapabilities(true),
+		server.WithHooks(hooks),
+		server.WithToolHandlerMiddleware(calls.middleware),
+	)
+	s.AddNotificationHandler("notifications/cancelled", calls.handleCancelled)
+	s.AddTool(mcp.NewTool("block"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		close(started)
+		<-ctx.Done()
+		return mcp.NewToolResultError("Query execution failed: context canceled"), nil
+	})
+	return s
+}
+
+func callBlockingTool(s *server.MCPServer) chan mcp.JSONRPCMessage {
+	done := make(chan mcp.JSONRPCMessage, 1)
+	go func() {
+		done <- s.HandleMessage(context.Background(), json.RawMessage(
+			`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"block"}}`))
+	}()
+	return done
+}
+
+func waitCancelled(t *testing.T, done chan mcp.JSONRPCMessage, reason string) {
+	t.Helper()
+	select {
+	case msg := <-done:
+		encoded, _ := json.Marshal(msg)
+		if !strings.Contains(string(encoded), `\"status\": \"cancelled\"`) || !strings.Contains(string(encoded), reason) {
+			t.Fatalf("expected a cancelled response with reason %q, got %s", reason, encoded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("tool call was not cancelled")
+	}
+}
+
+func TestCancelledNotificationCancelsToolCall(t *testing.T) {
+	calls := newCallRegistry()
+	started := make(chan struct{})
+	s := newCancelTestServer(calls, started)
+
+	done := callBlockingTool(s)
+	<-started
+
+	// A notification for another request leaves the call running.
+	s.HandleMessage(context.Background(), json.RawMessage(
+		`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":8}}`))
+	select {
+	case <-done:
+		t.Fatal("call cancelled by a notification for another request")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	s.HandleMessage(context.Background(), json.RawMessage(
+		`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7,"reason":"user pressed stop"}}`))
+	waitCancelled(t, done, "user pressed stop")
+
+	if len(calls.calls) != 0 {
+		t.Fatalf("finished call still registered: %v", calls.calls)
+	}
+}
+
+func TestCancelSessionCancelsToolCalls(t *testing.T) {
+	calls := newCallRegistry()
+	started := make(chan struct{})
+	s := newCancelTestServer(calls, started)
+
+	done := callBlockingTool(s)
+	<-started
+
+	calls.cancelSession("", "session closed")
+	waitCancelled(t, done, "session closed")
+}
+
+func TestRequestIDString(t *testing.T) {
+	if requestIDString(float64(7)) != requestIDString(mcp.NewRequestId(int64(7))) {
+		t.Fatal("numeric request ids should match regardless of representation")
+	}
+	if requestIDString("7") == requestIDString(float64(7)) {
+		t.Fatal("string and numeric request ids must not collide")
+	}
+}
@@ -3,14 +3,19 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/audit"
 	"gitee.com/kwdb/kwdb-mcp-server/pkg/ctxutil"
 	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/export"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/jobs"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/policy"
 	"gitee.com/kwdb/kwdb-mcp-server/pkg/prompts"
 	"gitee.com/kwdb/kwdb-mcp-server/pkg/resources"
 	"gitee.com/kwdb/kwdb-mcp-server/pkg/tools"
@@ -23,10 +28,62 @@ const (
 	httpHeartbeatIntervalEnvKey  = "KWDB_HTTP_HEARTBEAT_INTERVAL"
 )
 
+// runningCalls tracks the tool calls in flight so clients can cancel them.
+var runningCalls = newCallRegistry()
+
+// queryJobs runs the submit-query jobs of the server created last; Cleanup stops them.
+var queryJobs *jobs.Manager
+
+// queryResults keeps the results read-query stores as resources, dropped when their session closes.
+var queryResults = resources.NewResultStore()
+
+// auditLog records the tool calls of the server created last in its query history and audit
+// sinks; Cleanup closes it.
+var auditLog *audit.Logger
+
 // ServerConfig controls database and admin endpoint defaults used by the server.
 type ServerConfig struct {
-	ConnectionString    string
-	DefaultAdminBaseURL string
+	ConnectionString string
+	// ReadConnectionString optionally points read-query at a separate read-only role or endpoint
+	// of the default database. It requires ConnectionString.
+	ReadConnectionString string
+	DefaultAdminBaseURL  string
+	// ResultTimeZone is the IANA time zone used to render TIMESTAMPTZ results (default UTC).
+	ResultTimeZone string
+	// MaxBinaryBytes caps binary result values before base64 encoding (0 uses the default).
+	MaxBinaryBytes int
+	// DefaultRows, MaxRows and MaxResultBytes bound the rows returned by one read-query call (0 uses the defaults).
+	DefaultRows    int
+	MaxRows        int
+	MaxResultBytes int
+	// TxIdleTimeout rolls back session transactions left unused for this long (0 uses the default).
+	TxIdleTimeout time.Duration
+	// StatementTimeout bounds every statement run by a tool call (0 disables it).
+	StatementTimeout time.Duration
+	// TenantStatementTimeouts overrides StatementTimeout per database, as "database=duration" pairs
+	// separated by commas; a database is named "host:port/database" or just "database".
+	TenantStatementTimeouts string
+	// PolicyFile is a JSON write policy applied to the write tools (empty allows every write).
+	PolicyFile string
+	// ImportDir is the directory import-file may read from (empty disables the tool).
+	ImportDir string
+	// ExportDir is the directory export-query writes files to (empty disables the tool).
+	ExportDir string
+	// JobDir holds the spill files of submit-query jobs (empty uses the system temporary directory).
+	JobDir string
+	// JobTimeout bounds a submit-query job unless the call gives timeout_ms (0 disables it).
+	JobTimeout time.Duration
+	// Transport names the transport the server is served over (stdio, sse or http) in audit records.
+	Transport string
+	// AuditFile is the JSONL file every tool call is recorded in (empty disables the file).
+	AuditFile string
+	// AuditMaxBytes and AuditMaxBackups control the rotation of AuditFile (0 uses the defaults).
+	AuditMaxBytes   int64
+	AuditMaxBackups int
+	// AuditTable is the KWDB table every tool call is recorded in (empty disables the table).
+	AuditTable string
+	// AuditConnectionString is the database of AuditTable (empty uses ConnectionString).
+	AuditConnectionString string
 }
 
 // CreateServer creates MCP server.
@@ -40,11 +97,66 @@ func CreateServer(connectionString string) (*server.MCPServer, error) {
 
 // CreateServerWithConfig creates MCP server with optional default database and admin endpoints.
 func CreateServerWithConfig(config ServerConfig) (*server.MCPServer, error) {
+	if err := configureValueCodec(config); err != nil {
+		return nil, err
+	}
+
 	if config.ConnectionString != "" {
 		if err := db.InitDB(config.ConnectionString); err != nil {
 			return nil, err
 		}
 	}
+	if config.ReadConnectionString != "" {
+		if config.ConnectionString == "" {
+			return nil, fmt.Errorf("a read connection string requires a default connection string")
+		}
+		if err := db.InitReadDB(config.ReadConnectionString); err != nil {
+			return nil, err
+		}
+	}
+	db.GetTxManager().SetIdleTimeout(config.TxIdleTimeout)
+
+	tenantTimeouts, err := db.ParseTenantTimeouts(config.TenantStatementTimeouts)
+	if err != nil {
+		return nil, err
+	}
+	db.SetStatementTimeouts(db.StatementTimeouts{
+		Default: config.StatementTimeout,
+		Tenants: tenantTimeouts,
+	})
+
+	var writePolicy *policy.Policy
+	if config.PolicyFile != "" {
+		if writePolicy, err = policy.Load(config.PolicyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.ImportDir != "" {
+		if info, err := os.Stat(config.ImportDir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("import directory %s is not a directory", config.ImportDir)
+		}
+	}
+
+	var exports *export.Store
+	if config.ExportDir != "" {
+		if exports, err = export.NewStore(config.ExportDir, export.Options{}); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.JobDir != "" {
+		if info, err := os.Stat(config.JobDir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("job directory %s is not a directory", config.JobDir)
+		}
+	}
+	auditSinks, err := openAuditSinks(config)
+	if err != nil {
+		return nil, err
+	}
+	history := audit.NewHistory()
+	auditLog = audit.NewLogger(config.Transport, append([]audit.Sink{history}, auditSinks...)...)
+	queryJobs = jobs.NewManager(jobs.Options{Dir: config.JobDir})
 
 	// Create MCP server with capabilities
 	s := server.NewMCPServer(
@@ -53,7 +165,12 @@ func CreateServerWithConfig(config ServerConfig) (*server.MCPServer, error) {
 		server.WithResourceCapabilities(true, true),
 		server.WithPromptCapabilities(true),
 		server.WithToolCapabilities(true),
+		server.WithElicitation(),
 		server.WithLogging(),
+		server.WithHooks(newSessionHooks()),
+		// 审计中间件在最外层，记录的耗时和结果包含取消与超时；查询历史也由它记录
+		server.WithToolHandlerMiddleware(auditLog.Middleware),
+		server.WithToolHandlerMiddleware(runningCalls.middleware),
 		server.WithInstructions("This server allows you to interact with KWDB (KaiwuDB) databases using SQL."),
 	)
 
@@ -61,6 +178,14 @@ func CreateServerWithConfig(config ServerConfig) (*server.MCPServer, error) {
 	if err := registerLazyResources(s); err != nil {
 		return nil, err
 	}
+	if exports != nil {
+		resources.RegisterExportResourceTemplate(s, exports)
+	}
+	resources.RegisterResultResourceTemplate(s, queryResults)
+	resources.RegisterHistoryResource(s, history)
+
+	// Cancel running statements when the client cancels the tool call
+	s.AddNotificationHandler("notifications/cancelled", runningCalls.handleCancelled)
 
 	// Register prompts - basic prompts without table schemas
 	prompts.RegisterPrompts(s)
@@ -68,12 +193,108 @@ func CreateServerWithConfig(config ServerConfig) (*server.MCPServer, error) {
 	// Register tools
 	tools.RegisterToolsWithConfig(s, tools.Config{
 		DefaultAdminBaseURL: config.DefaultAdminBaseURL,
+		DefaultRows:         config.DefaultRows,
+		MaxRows:             config.MaxRows,
+		MaxResultBytes:      config.MaxResultBytes,
+		Policy:              writePolicy,
+		ImportDir:           config.ImportDir,
+		Exports:             exports,
+		Jobs:                queryJobs,
+		JobTimeout:          config.JobTimeout,
+		Results:             queryResults,
+		History:             history,
 	})
 
 	log.Println("KWDB (KaiwuDB) MCP Server initialized successfully (database connection will be established on demand)")
 	return s, nil
 }
 
+// openAuditSinks opens the audit file and table configured in config.
+func openAuditSinks(config ServerConfig) ([]audit.Sink, error) {
+	var sinks []audit.Sink
+	if config.AuditFile != "" {
+		file, err := audit.NewFileSink(config.AuditFile, config.AuditMaxBytes, config.AuditMaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, file)
+	}
+	if config.AuditTable != "" {
+		connectionString := config.AuditConnectionString
+		if connectionString == "" {
+			connectionString = config.ConnectionString
+		}
+		if connectionString == "" {
+			closeAuditSinks(sinks)
+			return nil, fmt.Errorf("an audit table requires an audit connection string or a default connection string")
+		}
+		table, err := audit.NewTableSink(connectionString, config.AuditTable)
+		if err != nil {
+			closeAuditSinks(sinks)
+			return nil, err
+		}
+		sinks = append(sinks, table)
+	}
+	return sinks, nil
+}
+
+func closeAuditSinks(sinks []audit.Sink) {
+	for _, sink := range sinks {
+		sink.Close()
+	}
+}
+
+// configureValueCodec applies result encoding options shared by every query.
+func configureValueCodec(config ServerConfig) error {
+	codec := db.DefaultValueCodec
+	if config.ResultTimeZone != "" {
+		loc, err := time.LoadLocation(config.ResultTimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid result time zone %q: %v", config.ResultTimeZone, err)
+		}
+		codec.Location = loc
+	}
+	if config.MaxBinaryBytes > 0 {
+		codec.MaxBytes = config.MaxBinaryBytes
+	}
+	db.SetValueCodec(codec)
+	return nil
+}
+
+// newSessionHooks returns hooks that release per-session state when an MCP session closes.
+// stdio, SSE and streamable HTTP all unregister their sessions when the client goes away.
+// The hooks also tag each tool call with its request id so it can be cancelled.
+func newSessionHooks() *server.Hooks {
+	hooks := &server.Hooks{}
+	hooks.AddBeforeCallTool(tagRequestID)
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		closeSession(session.SessionID())
+	})
+	return hooks
+}
+
+// closeSession cancels the running tool calls of a closed session, rolls back its transaction
+// and drops its stored results.
+// SSE runs tool calls detached from the HTTP request, so this is what stops them when the client goes away.
+func closeSession(sessionID string) {
+	runningCalls.cancelSession(sessionID, "session closed")
+	db.GetTxManager().CloseSession(sessionID)
+	queryResults.CloseSession(sessionID)
+}
+
+// closeSessionOnDelete closes a streamable HTTP session terminated by DELETE;
+// mcp-go does not run the unregister hooks for it.
+func closeSessionOnDelete(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if r.Method == http.MethodDelete {
+			if sessionID := r.Header.Get(server.HeaderKeySessionID); sessionID != "" {
+				closeSession(sessionID)
+			}
+		}
+	})
+}
+
 // registerLazyResources registers resources with lazy loading
 func registerLazyResources(s *server.MCPServer) error {
 	// Register basic resources
@@ -92,7 +313,13 @@ func ServeStdio(s *server.MCPServer) error {
 
 // ServeSSE starts the server using SSE over HTTP
 func ServeSSE(s *server.MCPServer, addr string, baseURL string) error {
-	sseServer := server.NewSSEServer(s, server.WithBaseURL(baseURL))
+	// 与 HTTP 模式相同，把 X-Database-URI 写入 context，资源据此区分租户
+	sseServer := server.NewSSEServer(s,
+		server.WithBaseURL(baseURL),
+		server.WithSSEContextFunc(func(ctx context.Context, r *http.Request) context.Context {
+			return ctxutil.WithDatabaseURI(ctx, r.Header.Get("X-Database-URI"))
+		}),
+	)
 	log.Printf("SSE server listening on %s", addr)
 	return sseServer.Start(addr)
 }
@@ -169,7 +396,7 @@ func ServeHTTP(s *server.MCPServer, addr string, tlsConfig *HTTPTLSConfig) error
 	// mcp-go 在使用 WithStreamableHTTPServer 时不会帮我们注册路由，这里显式挂载 /mcp。
 	streamable := server.NewStreamableHTTPServer(s, httpOpts...)
 	mux := http.NewServeMux()
-	mux.Handle("/mcp", streamable)
+	mux.Handle("/mcp", closeSessionOnDelete(streamable))
 	baseHTTPServer.Handler = mux
 
 	return streamable.Start(addr)
@@ -207,6 +434,14 @@ func resolveHTTPHeartbeatInterval() time.Duration {
 
 // Cleanup performs cleanup operations
 func Cleanup() {
+	// 先停止后台作业，再关闭它们使用的连接池
+	if queryJobs != nil {
+		queryJobs.Close()
+	}
+	if auditLog != nil {
+		auditLog.Close()
+		auditLog = nil
+	}
 	db.Close()
 }
 
@@ -0,0 +1,229 @@
+package tools
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	"github.com/lib/pq"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// registerBulkInsertTool registers the bulk ingestion tool.
+func registerBulkInsertTool(s *server.MCPServer, config Config) {
+	bulkInsertTool := mcp.NewTool("bulk-insert",
+		mcp.WithDescription("Load many rows into a relational or time-series table of KWDB (KaiwuDB) in one call. "+
+			"Rows are streamed with COPY in batches, falling back to batched multi-row INSERT where COPY is not supported. "+
+			"Each batch commits on its own; the result reports the rows loaded and the batches that failed. "+
+			"Inside a session transaction rows are inserted in the transaction and the load stops at the first failed batch."),
+		mcp.WithString("table",
+			mcp.Required(),
+			mcp.Description("Table to load, optionally qualified, e.g. sensors, public.sensors or iot.public.sensors."),
+		),
+		mcp.WithArray("columns",
+			mcp.Description("Exact column names, in the order of the values in each row. With csv, the first record is the header when columns is omitted."),
+			mcp.WithStringItems(),
+		),
+		mcp.WithArray("rows",
+			mcp.Description("Rows to load, each an array of values in column order. "+
+				"Values are plain JSON or typed hints such as {\"type\": \"timestamptz\", \"value\": \"2025-01-01T00:00:00Z\"}, as in params."),
+		),
+		mcp.WithString("csv",
+			mcp.Description("Rows as CSV text, instead of rows. Empty fields load as NULL."),
+		),
+		mcp.WithString("method",
+			mcp.Description("auto (default) uses COPY and falls back to INSERT, copy or insert forces one method."),
+			mcp.Enum(db.BulkMethodAuto, db.BulkMethodCopy, db.BulkMethodInsert),
+		),
+		mcp.WithNumber("batch_size",
+			mcp.Description(fmt.Sprintf("Rows per batch (default %d, maximum %d).", db.DefaultBulkBatchSize, db.MaxBulkBatchSize)),
+		),
+		withTimeoutArgument(),
+		mcp.WithRawOutputSchema(json.RawMessage(validOutputSchema)),
+	)
+
+	s.AddTool(bulkInsertTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		table := request.GetString("table", "")
+
+		// 从请求头中获取数据库 URI，用于多租户多数据库访问。
+		headerURI := request.Header.Get("X-Database-URI")
+		useURI, _, missingHeader := resolveDBTarget(headerURI, db.IsDefaultPoolInitialized())
+		if missingHeader {
+			return mcp.NewToolResultError("missing X-Database-URI header"), nil
+		}
+
+		ctx, err := withSessionTx(ctx, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Transaction conflict", err), nil
+		}
+
+		columns, rows, err := parseBulkRows(request)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid bulk-insert request", err), nil
+		}
+		batchSize := request.GetInt("batch_size", 0)
+		if batchSize < 0 {
+			return mcp.NewToolResultError("batch_size must be positive"), nil
+		}
+		stmt, err := bulkInsertStatement(table, columns)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid bulk-insert request", err), nil
+		}
+
+		// 写策略按等价的 INSERT 语句检查；行数已知，无需预检查
+		rules, database, err := policyRules(config.Policy, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Write policy check failed", err), nil
+		}
+		if violation := rules.Check(stmt, database); violation != nil {
+			return policyResult(violation)
+		}
+		if violation := rules.CheckAffectedRows("INSERT", int64(len(rows))); violation != nil {
+			return policyResult(violation)
+		}
+		timeout, err := callTimeout(request, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid bulk-insert request", err), nil
+		}
+		affected := int64(len(rows))
+		if result, err := confirmWrite(ctx, s, rules, database, useURI, stmt, timeout, &affected); result != nil || err != nil {
+			return result, err
+		}
+
+		ctx, cancel := db.WithStatementTimeout(ctx, timeout)
+		defer cancel()
+
+		started := time.Now()
+		result, err := db.BulkInsert(ctx, useURI, table, columns, rows, db.BulkOptions{
+			Method:    request.GetString("method", db.BulkMethodAuto),
+			BatchSize: batchSize,
+		})
+		if err != nil {
+			if db.IsTimeout(ctx, err) {
+				return timeoutResult(timeout, time.Since(started))
+			}
+			return mcp.NewToolResultErrorFromErr("Bulk insert failed", err), nil
+		}
+
+		response := map[string]interface{}{
+			"status": "success",
+			"type":   "bulk_insert_result",
+			"data":   result,
+			"error":  nil,
+		}
+		if len(result.Errors) > 0 {
+			response["status"] = "error"
+			response["error"] = fmt.Sprintf("%d of %d batches failed", len(result.Errors), result.Batches)
+		}
+
+		jsonResult, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize result: %v", err)
+		}
+
+		toolResult := mcp.NewToolResultStructured(response, string(jsonResult))
+		// Batches that loaded stay committed, so only a load where nothing landed is an error.
+		toolResult.IsError = len(result.Errors) > 0 && result.RowsLoaded == 0
+		return toolResult, nil
+	})
+}
+
+// parseBulkRows reads the columns and either the rows or the csv argument of bulk-insert,
+// converting the values to driver arguments.
+func parseBulkRows(request mcp.CallToolRequest) ([]string, [][]interface{}, error) {
+	args := request.GetArguments()
+	columns := request.GetStringSlice("columns", nil)
+	rawRows, hasRows := args["rows"]
+	text := request.GetString("csv", "")
+	hasRows = hasRows && rawRows != nil
+
+	switch {
+	case hasRows && text != "":
+		return nil, nil, fmt.Errorf("give either rows or csv, not both")
+	case text != "":
+		return parseBulkCSV(text, columns)
+	case !hasRows:
+		return nil, nil, fmt.Errorf("rows or csv is required")
+	}
+
+	if len(columns) == 0 {
+		return nil, nil, fmt.Errorf("columns is required with rows")
+	}
+	list, ok := rawRows.([]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("rows must be an array of arrays")
+	}
+	rows := make([][]interface{}, len(list))
+	for i, raw := range list {
+		values, ok := raw.([]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("row %d must be an array", i)
+		}
+		row, err := db.BuildQueryArgs(values)
+		if err != nil {
+			return nil, nil, fmt.Errorf("row %d: %v", i, err)
+		}
+		rows[i] = row
+	}
+	return columns, rows, nil
+}
+
+// parseBulkCSV parses CSV rows. Without columns the first record is the header. Empty fields
+// become NULL.
+func parseBulkCSV(text string, columns []string) ([]string, [][]interface{}, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.FieldsPerRecord = -1
+	var rows [][]interface{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid csv: %v", err)
+		}
+		if columns == nil {
+			columns = record
+			for i := range columns {
+				columns[i] = strings.TrimSpace(columns[i])
+			}
+			continue
+		}
+		if len(record) != len(columns) {
+			return nil, nil, fmt.Errorf("csv record %d has %d fields, expected %d", line, len(record), len(columns))
+		}
+		row := make([]interface{}, len(record))
+		for i, field := range record {
+			if field != "" {
+				row[i] = field
+			}
+		}
+		rows = append(rows, row)
+	}
+	if len(columns) == 0 {
+		return nil, nil, fmt.Errorf("csv has no header and columns is not given")
+	}
+	return columns, rows, nil
+}
+
+// bulkInsertStatement renders a single-row INSERT equivalent to the load, used to apply the
+// write policy and shown when the user is asked to confirm it.
+func bulkInsertStatement(table string, columns []string) (string, error) {
+	name, err := db.QuoteTableName(table)
+	if err != nil {
+		return "", err
+	}
+	quoted := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = pq.QuoteIdentifier(column)
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", name, strings.Join(quoted, ", "), strings.Join(placeholders, ", ")), nil
+}
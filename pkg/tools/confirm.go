@@ -0,0 +1,199 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/policy"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// confirmationTimeout bounds how long a tool call waits for the user to answer a confirmation.
+const confirmationTimeout = 5 * time.Minute
+
+// writeImpact is the estimated effect of a statement, shown to the user before it runs.
+type writeImpact struct {
+	// AffectedRows is counted by running a single DML statement in a rolled-back pre-check.
+	AffectedRows *int64 `json:"affected_rows,omitempty"`
+	// TableRows holds the estimated rows of the changed tables, from their statistics.
+	TableRows map[string]int64 `json:"table_rows,omitempty"`
+}
+
+// confirmWrite asks the user, through MCP elicitation, to confirm the statements of query that
+// the write policy marks dangerous. It returns a result when the call must stop: the user
+// declined, or the client cannot be asked and the policy refuses unconfirmed statements.
+// The impact estimates are bounded by timeout, the statement timeout of the call. affected is
+// the row count of an earlier pre-check, if any; otherwise a single DML statement is counted here.
+func confirmWrite(ctx context.Context, s *server.MCPServer, rules policy.Rules, database, connectionString, query string, timeout time.Duration, affected *int64, args ...interface{}) (*mcp.CallToolResult, error) {
+	statements, err := db.SplitStatements(query)
+	if err != nil {
+		// 无法解析的语句无法判断是否需要确认，拒绝执行
+		return mcp.NewToolResultErrorFromErr("Write confirmation failed", err), nil
+	}
+
+	// 估算影响使用本次调用的语句超时，等待用户确认则不受其限制
+	estimateCtx, cancel := db.WithStatementTimeout(ctx, timeout)
+	defer cancel()
+	type estimated struct {
+		rows int64
+		ok   bool
+	}
+	estimates := map[string]estimated{}
+	estimate := func(database, table string) (int64, bool) {
+		key := database + "." + table
+		if e, ok := estimates[key]; ok {
+			return e.rows, e.ok
+		}
+		rows, ok, err := db.EstimateTableRows(estimateCtx, connectionString, database, table)
+		if err != nil {
+			log.Printf("Failed to estimate rows of %s: %v", key, err)
+		}
+		estimates[key] = estimated{rows, ok}
+		return rows, ok
+	}
+
+	var confirmations []*policy.Confirmation
+	for _, stmt := range statements {
+		if c := rules.ConfirmationFor(stmt, database, estimate); c != nil {
+			confirmations = append(confirmations, c)
+		}
+	}
+	if len(confirmations) == 0 {
+		return nil, nil
+	}
+
+	impact := writeImpact{TableRows: map[string]int64{}}
+	for _, c := range confirmations {
+		for _, table := range c.Tables {
+			tableDB, tableName, qualified := strings.Cut(table, ".")
+			if !qualified {
+				tableDB, tableName = "", table
+			}
+			if rows, ok := estimate(tableDB, tableName); ok {
+				impact.TableRows[table] = rows
+			}
+		}
+	}
+	impact.AffectedRows = affected
+	if affected == nil && len(statements) == 1 && statements[0].Category == db.CategoryDML && db.CountPlaceholders(query) == len(args) {
+		if counted, err := db.PrecheckAffectedRows(estimateCtx, connectionString, query, args...); err == nil {
+			impact.AffectedRows = &counted
+		} else {
+			log.Printf("Failed to pre-check affected rows: %v", err)
+		}
+	}
+
+	if !supportsElicitation(ctx) {
+		if rules.AllowsUnconfirmed() {
+			log.Printf("Running %s without confirmation: the client does not support elicitation", confirmations[0].Statement)
+			return nil, nil
+		}
+		return confirmationResult("confirmation_required", "the statement needs confirmation, but the client does not support elicitation", confirmations, impact, "")
+	}
+
+	askCtx, cancelAsk := context.WithTimeout(ctx, confirmationTimeout)
+	defer cancelAsk()
+	answer, err := s.RequestElicitation(askCtx, mcp.ElicitationRequest{
+		Params: mcp.ElicitationParams{
+			Message:         confirmationMessage(query, database, confirmations, impact),
+			RequestedSchema: confirmationSchema,
+		},
+	})
+	if err != nil {
+		return confirmationResult("confirmation_failed", fmt.Sprintf("failed to ask for confirmation: %v", err), confirmations, impact, "")
+	}
+	if answer.Action == mcp.ElicitationResponseActionAccept && confirmed(answer.Content) {
+		return nil, nil
+	}
+	return confirmationResult("confirmation_declined", "the user did not confirm the statement", confirmations, impact, string(answer.Action))
+}
+
+// confirmationSchema asks for a single yes or no answer.
+var confirmationSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"confirm": map[string]interface{}{
+			"type":        "boolean",
+			"title":       "Run this statement",
+			"description": "Check to run the statement on the database.",
+		},
+	},
+	"required": []string{"confirm"},
+}
+
+// supportsElicitation reports whether the client of the current session declared elicitation
+// support and its transport can deliver the request.
+func supportsElicitation(ctx context.Context) bool {
+	session := server.ClientSessionFromContext(ctx)
+	if _, ok := session.(server.SessionWithElicitation); !ok {
+		return false
+	}
+	withInfo, ok := session.(server.SessionWithClientInfo)
+	return ok && withInfo.GetClientCapabilities().Elicitation != nil
+}
+
+// confirmed reads the confirm field of an accepted elicitation.
+func confirmed(content any) bool {
+	fields, ok := content.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	confirm, _ := fields["confirm"].(bool)
+	return confirm
+}
+
+// confirmationMessage shows the statement, why it needs confirmation and its estimated impact.
+func confirmationMessage(query, database string, confirmations []*policy.Confirmation, impact writeImpact) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Confirm this write on database %s:\n\n%s\n\n", database, strings.TrimSpace(query))
+	for _, c := range confirmations {
+		fmt.Fprintf(&b, "- %s\n", c.Reason)
+	}
+	if impact.AffectedRows != nil {
+		fmt.Fprintf(&b, "\nEstimated impact: %d rows would change.", *impact.AffectedRows)
+	}
+	tables := make([]string, 0, len(impact.TableRows))
+	for table := range impact.TableRows {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		fmt.Fprintf(&b, "\nTable %s has about %d rows.", table, impact.TableRows[table])
+	}
+	return b.String()
+}
+
+// confirmationResult reports a statement that did not run because it was not confirmed.
+func confirmationResult(kind, message string, confirmations []*policy.Confirmation, impact writeImpact, action string) (*mcp.CallToolResult, error) {
+	data := map[string]interface{}{
+		"rule":          confirmations[0].Rule,
+		"statement":     confirmations[0].Statement,
+		"confirmations": confirmations,
+		"impact":        impact,
+	}
+	if action != "" {
+		data["action"] = action
+	}
+	response := map[string]interface{}{
+		"status": "rejected",
+		"type":   kind,
+		"data":   data,
+		"error":  message,
+	}
+
+	jsonResult, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize result: %v", err)
+	}
+
+	result := mcp.NewToolResultStructured(response, string(jsonResult))
+	result.IsError = true
+	return result, nil
+}
@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// cursorTTL is how long an unused cursor stays valid.
+	cursorTTL = 10 * time.Minute
+	// maxCursorsPerSession bounds the open cursors held for one session; the oldest is evicted first.
+	maxCursorsPerSession = 32
+)
+
+// queryCursor remembers where the next page of a read-query statement starts.
+// A cursor is bound to the session and database that created it and is consumed on use.
+type queryCursor struct {
+	sessionID   string
+	databaseURI string
+	sql         string
+	args        []interface{}
+	paramCount  int
+	offset      int
+	pageSize    int
+	maxBytes    int
+	createdAt   time.Time
+	expiresAt   time.Time
+}
+
+// cursorStore keeps open read-query cursors in memory.
+type cursorStore struct {
+	mu      sync.Mutex
+	cursors map[string]*queryCursor
+	now     func() time.Time
+}
+
+func newCursorStore() *cursorStore {
+	return &cursorStore{
+		cursors: make(map[string]*queryCursor),
+		now:     time.Now,
+	}
+}
+
+// readQueryCursors holds the cursors issued by the read-query tool.
+var readQueryCursors = newCursorStore()
+
+// put stores c and returns its token.
+func (s *cursorStore) put(c queryCursor) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate cursor: %v", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.evictExpiredLocked(now)
+
+	// 同一会话的游标数达到上限时，淘汰最早创建的游标
+	var owned []string
+	for t, existing := range s.cursors {
+		if existing.sessionID == c.sessionID {
+			owned = append(owned, t)
+		}
+	}
+	for len(owned) >= maxCursorsPerSession {
+		oldest := 0
+		for i := range owned {
+			if s.cursors[owned[i]].createdAt.Before(s.cursors[owned[oldest]].createdAt) {
+				oldest = i
+			}
+		}
+		delete(s.cursors, owned[oldest])
+		owned = append(owned[:oldest], owned[oldest+1:]...)
+	}
+
+	c.createdAt = now
+	c.expiresAt = now.Add(cursorTTL)
+	s.cursors[token] = &c
+	return token, nil
+}
+
+// get returns the cursor for token if it belongs to sessionID and databaseURI. The cursor stays
+// in the store, so a page that fails can be retried; remove it once its page has been produced.
+func (s *cursorStore) get(token, sessionID, databaseURI string) (*queryCursor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictExpiredLocked(s.now())
+
+	c, ok := s.cursors[token]
+	if !ok {
+		return nil, fmt.Errorf("cursor not found or expired")
+	}
+	if c.sessionID != sessionID || c.databaseURI != databaseURI {
+		return nil, fmt.Errorf("cursor not found or expired")
+	}
+	copied := *c
+	return &copied, nil
+}
+
+// remove drops the cursor for token.
+func (s *cursorStore) remove(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cursors, token)
+}
+
+func (s *cursorStore) evictExpiredLocked(now time.Time) {
+	for token, c := range s.cursors {
+		if !now.Before(c.expiresAt) {
+			delete(s.cursors, token)
+		}
+	}
+}
+
+// sessionIDFromContext returns the MCP session ID of the caller, or "" when there is none.
+func sessionIDFromContext(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCursorStoreGet(t *testing.T) {
+	store := newCursorStore()
+	token, err := store.put(queryCursor{sessionID: "s1", databaseURI: "uri", sql: "SELECT 1", offset: 20, pageSize: 20})
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	if _, err := store.get(token, "s2", "uri"); err == nil {
+		t.Fatal("cursor should not be usable from another session")
+	}
+	if _, err := store.get(token, "s1", "other"); err == nil {
+		t.Fatal("cursor should not be usable against another database")
+	}
+
+	c, err := store.get(token, "s1", "uri")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if c.sql != "SELECT 1" || c.offset != 20 {
+		t.Fatalf("unexpected cursor %+v", c)
+	}
+
+	if _, err := store.get(token, "s1", "uri"); err != nil {
+		t.Fatalf("cursor should stay usable until removed: %v", err)
+	}
+	store.remove(token)
+	if _, err := store.get(token, "s1", "uri"); err == nil {
+		t.Fatal("cursor should be gone after remove")
+	}
+}
+
+func TestCursorStoreExpiry(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := newCursorStore()
+	store.now = func() time.Time { return now }
+
+	token, err := store.put(queryCursor{sessionID: "s1"})
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	now = now.Add(cursorTTL)
+	if _, err := store.get(token, "s1", ""); err == nil {
+		t.Fatal("expired cursor should be rejected")
+	}
+}
+
+func TestCursorStoreSessionLimit(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := newCursorStore()
+	store.now = func() time.Time { return now }
+
+	var tokens []string
+	for i := 0; i < maxCursorsPerSession+1; i++ {
+		now = now.Add(time.Second)
+		token, err := store.put(queryCursor{sessionID: "s1", offset: i})
+		if err != nil {
+			t.Fatalf("put: %v", err)
+		}
+		tokens = append(tokens, token)
+	}
+	if _, err := store.put(queryCursor{sessionID: "s2"}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	if _, err := store.get(tokens[0], "s1", ""); err == nil {
+		t.Fatal("oldest cursor should be evicted once the session limit is reached")
+	}
+	if _, err := store.get(tokens[len(tokens)-1], "s1", ""); err != nil {
+		t.Fatalf("newest cursor should still be available: %v", err)
+	}
+	// s1 keeps maxCursorsPerSession cursors, plus the one of s2.
+	if len(store.cursors) != maxCursorsPerSession+1 {
+		t.Fatalf("expected %d cursors left, got %d", maxCursorsPerSession+1, len(store.cursors))
+	}
+}
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// registerExplainQueryTool registers the structured EXPLAIN tool.
+func registerExplainQueryTool(s *server.MCPServer) {
+	explainQueryTool := mcp.NewTool("explain-query",
+		mcp.WithDescription("Show the query plan of a statement on KWDB (KaiwuDB) as a JSON operator tree. "+
+			"Each operator reports estimated and actual rows, table, index, spans, time-series markers and the nodes it ran on; "+
+			"full scans and full scans that are filtered afterwards (a likely missing index) are listed as issues."),
+		mcp.WithString("sql",
+			mcp.Required(),
+			mcp.Description("Read or DML statement to explain, without the EXPLAIN keyword."),
+		),
+		withQueryParams(),
+		mcp.WithBoolean("analyze",
+			mcp.Description("Run EXPLAIN ANALYZE to execute the statement and collect actual row counts (default false). "+
+				"A write statement is executed in a transaction that is always rolled back."),
+		),
+		mcp.WithRawOutputSchema(json.RawMessage(validOutputSchema)),
+	)
+
+	s.AddTool(explainQueryTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sql := request.GetString("sql", "")
+		analyze := request.GetBool("analyze", false)
+
+		// 从请求头中获取数据库 URI，用于多租户多数据库访问。
+		headerURI := request.Header.Get("X-Database-URI")
+		useURI, _, missingHeader := resolveDBTarget(headerURI, db.IsDefaultPoolInitialized())
+		if missingHeader {
+			return mcp.NewToolResultError("missing X-Database-URI header"), nil
+		}
+
+		ctx, err := withSessionTx(ctx, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Transaction conflict", err), nil
+		}
+
+		args, err := parseQueryParams(request, sql)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid query parameters", err), nil
+		}
+
+		ctx, cancel, timeout, err := withCallTimeout(ctx, request, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid explain-query request", err), nil
+		}
+		defer cancel()
+
+		started := time.Now()
+		result, err := db.ExplainQuery(ctx, useURI, sql, analyze, args...)
+		if err != nil {
+			if db.IsTimeout(ctx, err) {
+				return timeoutResult(timeout, time.Since(started))
+			}
+			return mcp.NewToolResultErrorFromErr("Explain failed", err), nil
+		}
+
+		plan := result.Plan
+		response := map[string]interface{}{
+			"status": "success",
+			"type":   "explain_result",
+			"data": map[string]interface{}{
+				"result_type":   "plan",
+				"analyze":       result.Analyze,
+				"rolled_back":   result.RolledBack,
+				"distribution":  plan.Distribution,
+				"properties":    plan.Properties,
+				"root":          plan.Root,
+				"issues":        plan.Issues,
+				"full_scan":     plan.HasIssue(db.IssueFullScan),
+				"missing_index": plan.HasIssue(db.IssueMissingIndex),
+				"text":          plan.Text,
+				"metadata": map[string]interface{}{
+					"query":          result.Query,
+					"original_query": sql,
+					"param_count":    len(args),
+				},
+			},
+			"error": nil,
+		}
+
+		jsonResult, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize result: %v", err)
+		}
+		return mcp.NewToolResultStructured(response, string(jsonResult)), nil
+	})
+}
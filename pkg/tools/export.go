@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/export"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// registerExportQueryTool registers the export tool. It writes files only into config.Exports
+// and is not registered when no export directory is configured.
+func registerExportQueryTool(s *server.MCPServer, config Config) {
+	exportQueryTool := mcp.NewTool("export-query",
+		mcp.WithDescription("Run a read-only query on KWDB (KaiwuDB) and stream the full result set to a file in the server's export directory, as CSV, NDJSON or Parquet. "+
+			"Returns the file's path, row count, size and SHA-256 checksum, and a kwdb://export/{id} resource URI the client can read the file from, "+
+			"so the rows do not pass through the conversation."),
+		mcp.WithString("sql",
+			mcp.Required(),
+			mcp.Description("SQL query to export. Only read operations like SELECT, SHOW, EXPLAIN are allowed. No LIMIT is added."),
+		),
+		withQueryParams(),
+		mcp.WithString("format",
+			mcp.Description("csv (default), ndjson or parquet."),
+			mcp.Enum(export.Formats...),
+		),
+		withTimeoutArgument(),
+		mcp.WithRawOutputSchema(json.RawMessage(validOutputSchema)),
+	)
+
+	s.AddTool(exportQueryTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sql := request.GetString("sql", "")
+		format := request.GetString("format", export.FormatCSV)
+
+		// 从请求头中获取数据库 URI，用于多租户多数据库访问。
+		headerURI := request.Header.Get("X-Database-URI")
+		useURI, _, missingHeader := resolveDBTarget(headerURI, db.IsDefaultPoolInitialized())
+		if missingHeader {
+			return mcp.NewToolResultError("missing X-Database-URI header"), nil
+		}
+
+		ctx, err := withSessionTx(ctx, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Transaction conflict", err), nil
+		}
+		ctx = db.WithReadURI(ctx, request.Header.Get("X-Database-Read-URI"))
+
+		args, err := parseQueryParams(request, sql)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid query parameters", err), nil
+		}
+		if err := db.ValidateReadQuery(sql); err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid export-query request", err), nil
+		}
+
+		ctx, cancel, timeout, err := withCallTimeout(ctx, request, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid export-query request", err), nil
+		}
+		defer cancel()
+
+		exp, err := config.Exports.Create(format, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid export-query request", err), nil
+		}
+
+		started := time.Now()
+		err = db.StreamQuery(ctx, useURI, sql, exp.Begin, exp.WriteRow, args...)
+		if err != nil {
+			exp.Abort()
+			if db.IsTimeout(ctx, err) {
+				return timeoutResult(timeout, time.Since(started))
+			}
+			return mcp.NewToolResultErrorFromErr("Export failed", err), nil
+		}
+		file, err := exp.Commit()
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Export failed", err), nil
+		}
+
+		response := map[string]interface{}{
+			"status": "success",
+			"type":   "export_result",
+			"data": map[string]interface{}{
+				"export": file,
+				"metadata": map[string]interface{}{
+					"query":       sql,
+					"param_count": len(args),
+					"duration_ms": time.Since(started).Milliseconds(),
+				},
+			},
+			"error": nil,
+		}
+
+		jsonResult, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize result: %v", err)
+		}
+
+		result := mcp.NewToolResultStructured(response, string(jsonResult))
+		// 同时附带资源链接，客户端可直接读取文件而不经过模型上下文
+		result.Content = append(result.Content, mcp.NewResourceLink(file.URI, "export "+file.ID, fmt.Sprintf("%d rows exported as %s", file.Rows, file.Format), file.MIMEType))
+		return result, nil
+	})
+}
@@ -0,0 +1,187 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/export"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Output formats for the text content of tabular results.
+const (
+	outputJSONRows     = "json_rows"
+	outputJSONColumnar = "json_columnar"
+	outputCSV          = "csv"
+	outputMarkdown     = "markdown_table"
+)
+
+var outputFormats = []string{outputJSONRows, outputJSONColumnar, outputCSV, outputMarkdown}
+
+// outputFormatDescription documents the output_format argument of the tabular tools.
+const outputFormatDescription = "Format of the text content: json_rows (one object per row), json_columnar (column names once, rows as arrays), " +
+	"csv or markdown_table. The rows come first and a compact JSON summary without them follows; structuredContent is unchanged. " +
+	"Without it the text content is the full JSON response."
+
+// withOutputFormat declares the output_format argument.
+func withOutputFormat() mcp.ToolOption {
+	return mcp.WithString("output_format",
+		mcp.Description(outputFormatDescription),
+		mcp.Enum(outputFormats...),
+	)
+}
+
+// validateOutputFormat accepts the empty string, which keeps the full JSON response as text.
+func validateOutputFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	for _, f := range outputFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("output_format must be one of %s", strings.Join(outputFormats, ", "))
+}
+
+// outputInfo reports the size of the rendered rows, so callers can choose a cheaper format.
+type outputInfo struct {
+	Format string `json:"format"`
+	Bytes  int    `json:"bytes"`
+	// ApproxTokens estimates the tokens of the text at about four bytes per token.
+	ApproxTokens int `json:"approx_tokens"`
+}
+
+func newOutputInfo(format, text string) *outputInfo {
+	return &outputInfo{Format: format, Bytes: len(text), ApproxTokens: (len(text) + 3) / 4}
+}
+
+// renderTable renders rows of values encoded by db.ValueCodec in format.
+func renderTable(format string, columns []string, rows [][]interface{}) (string, error) {
+	switch format {
+	case outputJSONRows:
+		return renderJSONRows(columns, rows)
+	case outputJSONColumnar:
+		encoded, err := json.Marshal(map[string]interface{}{"columns": columns, "rows": rows})
+		return string(encoded), err
+	case outputCSV:
+		return renderCSV(columns, rows)
+	case outputMarkdown:
+		return renderMarkdown(columns, rows), nil
+	}
+	return "", validateOutputFormat(format)
+}
+
+// renderJSONRows writes a JSON array with one object per row, keys in column order.
+// A repeated column name gets a _2, _3, ... suffix so no value is lost.
+func renderJSONRows(columns []string, rows [][]interface{}) (string, error) {
+	seen := map[string]int{}
+	keys := make([][]byte, len(columns))
+	for i, name := range columns {
+		seen[name]++
+		if n := seen[name]; n > 1 {
+			name = fmt.Sprintf("%s_%d", name, n)
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return "", err
+		}
+		keys[i] = key
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for r, row := range rows {
+		if r > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('{')
+		for i, value := range row {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return "", err
+			}
+			buf.Write(keys[i])
+			buf.WriteByte(':')
+			buf.Write(encoded)
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(']')
+	return buf.String(), nil
+}
+
+// renderCSV writes a header and one record per row like export-query: NULL is an empty field.
+func renderCSV(columns []string, rows [][]interface{}) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(columns); err != nil {
+		return "", err
+	}
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i, value := range row {
+			record[i] = export.TextValue(value)
+		}
+		if err := w.Write(record); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	return buf.String(), w.Error()
+}
+
+// renderMarkdown writes a GitHub-flavored Markdown table. NULL is shown as NULL.
+func renderMarkdown(columns []string, rows [][]interface{}) string {
+	var b strings.Builder
+	writeMarkdownRow(&b, columns)
+	b.WriteString("|")
+	for range columns {
+		b.WriteString(" --- |")
+	}
+	b.WriteString("\n")
+
+	cells := make([]string, len(columns))
+	for _, row := range rows {
+		for i, value := range row {
+			if value == nil {
+				cells[i] = "NULL"
+			} else {
+				cells[i] = export.TextValue(value)
+			}
+		}
+		writeMarkdownRow(&b, cells)
+	}
+	return b.String()
+}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+func writeMarkdownRow(b *strings.Builder, cells []string) {
+	b.WriteString("|")
+	for _, cell := range cells {
+		b.WriteString(" ")
+		b.WriteString(markdownEscaper.Replace(cell))
+		b.WriteString(" |")
+	}
+	b.WriteString("\n")
+}
+
+// tableResult returns a result whose text content is the rendered table followed by summary as
+// compact JSON. structuredContent keeps the full response.
+func tableResult(response, summary interface{}, table string) (*mcp.CallToolResult, error) {
+	encoded, err := json.Marshal(summary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize result: %v", err)
+	}
+	return &mcp.CallToolResult{
+		Content:           []mcp.Content{mcp.NewTextContent(table), mcp.NewTextContent(string(encoded))},
+		StructuredContent: response,
+	}, nil
+}
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestRenderTable(t *testing.T) {
+	columns := []string{"id", "note", "id"}
+	rows := [][]interface{}{
+		{int64(1), "a|b\nc", int64(10)},
+		{int64(2), nil, json.RawMessage(`{"k":[1,2]}`)},
+	}
+
+	tests := map[string]string{
+		outputJSONRows:     `[{"id":1,"note":"a|b\nc","id_2":10},{"id":2,"note":null,"id_2":{"k":[1,2]}}]`,
+		outputJSONColumnar: `{"columns":["id","note","id"],"rows":[[1,"a|b\nc",10],[2,null,{"k":[1,2]}]]}`,
+		outputCSV:          "id,note,id\n1,\"a|b\nc\",10\n2,,\"{\"\"k\"\":[1,2]}\"\n",
+		outputMarkdown:     "| id | note | id |\n| --- | --- | --- |\n| 1 | a\\|b<br>c | 10 |\n| 2 | NULL | {\"k\":[1,2]} |\n",
+	}
+	for format, want := range tests {
+		got, err := renderTable(format, columns, rows)
+		if err != nil {
+			t.Fatalf("renderTable(%s) returned error: %v", format, err)
+		}
+		if got != want {
+			t.Errorf("renderTable(%s) =\n%s\nwant:\n%s", format, got, want)
+		}
+	}
+
+	if _, err := renderTable("xml", columns, rows); err == nil {
+		t.Fatal("an unknown format should fail")
+	}
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	for _, format := range append([]string{""}, outputFormats...) {
+		if err := validateOutputFormat(format); err != nil {
+			t.Errorf("validateOutputFormat(%q) returned error: %v", format, err)
+		}
+	}
+	if err := validateOutputFormat("JSON"); err == nil {
+		t.Fatal("an unknown format should fail")
+	}
+}
+
+func TestTableResult(t *testing.T) {
+	response := map[string]interface{}{"status": "success", "data": map[string]interface{}{"rows": [][]interface{}{{1}}}}
+	result, err := tableResult(response, map[string]interface{}{"status": "success"}, "a\n1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Content) != 2 {
+		t.Fatalf("expected table and summary content, got %d items", len(result.Content))
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; text != "a\n1\n" {
+		t.Errorf("unexpected table content %q", text)
+	}
+	if text := result.Content[1].(mcp.TextContent).Text; text != `{"status":"success"}` {
+		t.Errorf("unexpected summary content %q", text)
+	}
+	if result.StructuredContent == nil {
+		t.Fatal("structuredContent should keep the full response")
+	}
+}
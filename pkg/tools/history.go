@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/audit"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	defaultHistoryEntries = 20
+	maxHistoryEntries     = 200
+)
+
+// registerQueryHistoryTool registers the tool that looks up the SQL calls made earlier.
+func registerQueryHistoryTool(s *server.MCPServer, config Config) {
+	historyTool := mcp.NewTool("query-history",
+		mcp.WithDescription("List the recent SQL tool calls made on the current database, newest first, with their status, duration, "+
+			"row count and error. Use it to reuse a query that worked or to look at earlier failures instead of deriving them again."),
+		mcp.WithString("scope",
+			mcp.Description("session (default) lists the calls of this MCP session; database lists the calls of every session using the same database URI."),
+			mcp.Enum("session", "database"),
+		),
+		mcp.WithString("status",
+			mcp.Description("Only list calls with this status."),
+			mcp.Enum("success", "error", "timeout", "rejected", "cancelled"),
+		),
+		mcp.WithString("table",
+			mcp.Description("Only list calls whose SQL or table argument names this table. A qualified name matches on its last part."),
+		),
+		mcp.WithString("since",
+			mcp.Description("Only list calls started at or after this time: an RFC 3339 timestamp or a duration before now, such as 30m or 24h."),
+		),
+		mcp.WithString("until",
+			mcp.Description("Only list calls started at or before this time, in the same formats as since."),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of calls to list (default %d, max %d).", defaultHistoryEntries, maxHistoryEntries)),
+		),
+		mcp.WithRawOutputSchema(json.RawMessage(validOutputSchema)),
+	)
+
+	s.AddTool(historyTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// 历史记录按请求头中的数据库 URI 区分，与审计中间件记录时一致
+		headerURI := request.Header.Get("X-Database-URI")
+		if _, _, missingHeader := resolveDBTarget(headerURI, db.IsDefaultPoolInitialized()); missingHeader {
+			return mcp.NewToolResultError("missing X-Database-URI header"), nil
+		}
+
+		filter, scope, err := parseHistoryFilter(request, time.Now())
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid query-history request", err), nil
+		}
+		if scope == "session" {
+			filter.SessionID = sessionIDFromContext(ctx)
+		}
+		entries := config.History.Query(headerURI, filter)
+
+		response := map[string]interface{}{
+			"status": "success",
+			"type":   "query_history",
+			"data": map[string]interface{}{
+				"entries": entries,
+				"metadata": map[string]interface{}{
+					"count": len(entries),
+					"scope": scope,
+					"limit": filter.Limit,
+				},
+			},
+			"error": nil,
+		}
+
+		jsonResult, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize result: %v", err)
+		}
+		return mcp.NewToolResultStructured(response, string(jsonResult)), nil
+	})
+}
+
+// parseHistoryFilter reads the filter and scope of a query-history call.
+func parseHistoryFilter(request mcp.CallToolRequest, now time.Time) (audit.HistoryFilter, string, error) {
+	scope := request.GetString("scope", "session")
+	if scope != "session" && scope != "database" {
+		return audit.HistoryFilter{}, "", fmt.Errorf("scope must be session or database, got %q", scope)
+	}
+	limit, err := budgetArgument(request, "limit", defaultHistoryEntries, maxHistoryEntries)
+	if err != nil {
+		return audit.HistoryFilter{}, "", err
+	}
+	filter := audit.HistoryFilter{
+		Status: request.GetString("status", ""),
+		Table:  strings.TrimSpace(request.GetString("table", "")),
+		Limit:  limit,
+	}
+	if filter.Since, err = parseHistoryTime(request.GetString("since", ""), now); err != nil {
+		return audit.HistoryFilter{}, "", fmt.Errorf("invalid since: %v", err)
+	}
+	if filter.Until, err = parseHistoryTime(request.GetString("until", ""), now); err != nil {
+		return audit.HistoryFilter{}, "", fmt.Errorf("invalid until: %v", err)
+	}
+	return filter, scope, nil
+}
+
+// parseHistoryTime parses an RFC 3339 timestamp or a duration before now; "" is the zero time.
+func parseHistoryTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 timestamp nor a positive duration", value)
+	}
+	return now.Add(-d), nil
+}
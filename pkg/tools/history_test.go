@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/audit"
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+)
+
+func TestQueryHistoryTool(t *testing.T) {
+	const uri = "postgres://root@localhost:26257/db1"
+	history := audit.NewHistory()
+	logger := audit.NewLogger("stdio", history)
+
+	s := mcpserver.NewMCPServer("test", "1.0", mcpserver.WithToolCapabilities(true))
+	RegisterToolsWithConfig(s, Config{History: history})
+	if s.GetTool("query-history") == nil {
+		t.Fatal("query-history was not registered")
+	}
+
+	request := func(name string, args map[string]any) mcp.CallToolRequest {
+		r := mcp.CallToolRequest{Header: http.Header{}, Params: mcp.CallToolParams{Name: name, Arguments: args}}
+		r.Header.Set("X-Database-URI", uri)
+		return r
+	}
+	// Record calls through the audit middleware, as the server does.
+	record := func(sql, status string) {
+		handler := logger.Middleware(func(ctx context.Context, r mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultStructured(map[string]interface{}{"status": status}, status), nil
+		})
+		handler(context.Background(), request("read-query", map[string]any{"sql": sql}))
+	}
+	record("SELECT * FROM sensors", "success")
+	record("SELECT * FROM missing", "error")
+	record("SELECT count(*) FROM sensors", "success")
+
+	call := func(args map[string]any) map[string]interface{} {
+		t.Helper()
+		result, err := s.GetTool("query-history").Handler(context.Background(), request("query-history", args))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.IsError {
+			t.Fatalf("unexpected error result %+v", result)
+		}
+		return result.StructuredContent.(map[string]interface{})["data"].(map[string]interface{})
+	}
+
+	data := call(map[string]any{"table": "sensors", "status": "success", "limit": 1})
+	entries := data["entries"].([]audit.Record)
+	if len(entries) != 1 || entries[0].SQL != "SELECT count(*) FROM sensors" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	if entries[0].Tool != "read-query" || entries[0].Status != "success" {
+		t.Errorf("unexpected entry %+v", entries[0])
+	}
+
+	data = call(map[string]any{"scope": "database", "status": "error"})
+	if entries := data["entries"].([]audit.Record); len(entries) != 1 || entries[0].SQL != "SELECT * FROM missing" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	result, err := s.GetTool("query-history").Handler(context.Background(), request("query-history", map[string]any{"since": "yesterday"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsError {
+		t.Error("expected an error for an invalid since")
+	}
+}
+
+func TestParseHistoryTime(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"", time.Time{}, false},
+		{"30m", now.Add(-30 * time.Minute), false},
+		{"2026-02-28T08:00:00+08:00", time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC), false},
+		{"-1h", time.Time{}, true},
+		{"yesterday", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseHistoryTime(tt.value, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseHistoryTime(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseHistoryTime(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,548 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	"github.com/lib/pq"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// maxImportFileBytes caps the size of a file read by import-file.
+	maxImportFileBytes = 100 << 20
+	// maxImportIssues caps the validation problems reported for one file.
+	maxImportIssues = 20
+)
+
+// Import file formats.
+const (
+	importFormatCSV    = "csv"
+	importFormatNDJSON = "ndjson"
+)
+
+// importIssue is a value or column of an imported file that does not fit the target table.
+type importIssue struct {
+	// Row is the zero-based data row, or nil for a problem with a whole column.
+	Row      *int        `json:"row,omitempty"`
+	Column   string      `json:"column"`
+	Value    interface{} `json:"value,omitempty"`
+	Expected string      `json:"expected,omitempty"`
+	Message  string      `json:"message"`
+}
+
+// registerImportFileTool registers the file import tool. It reads files only under
+// config.ImportDir and is not registered when no import directory is configured.
+func registerImportFileTool(s *server.MCPServer, config Config) {
+	importFileTool := mcp.NewTool("import-file",
+		mcp.WithDescription("Load a CSV or NDJSON file from the server's import directory into a KWDB (KaiwuDB) table. "+
+			"Values are validated against the table's column types before loading, and the rows are loaded in batches like bulk-insert. "+
+			"Progress is reported with MCP progress notifications when the call has a progress token."),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("File to import, relative to the import directory. Absolute paths and paths leaving the directory are refused."),
+		),
+		mcp.WithString("table",
+			mcp.Required(),
+			mcp.Description("Target table, optionally qualified, e.g. sensors or iot.public.sensors."),
+		),
+		mcp.WithString("format",
+			mcp.Description("csv or ndjson. Defaults to the file extension (.csv, .ndjson or .jsonl)."),
+			mcp.Enum(importFormatCSV, importFormatNDJSON),
+		),
+		mcp.WithArray("columns",
+			mcp.Description("For csv, the column names of a file without a header row. For ndjson, the fields to load, in order (default: every field)."),
+			mcp.WithStringItems(),
+		),
+		mcp.WithBoolean("create_table",
+			mcp.Description("Create the table when it does not exist, with column types inferred from the file (default false)."),
+		),
+		mcp.WithString("method",
+			mcp.Description("auto (default) uses COPY and falls back to INSERT, copy or insert forces one method."),
+			mcp.Enum(db.BulkMethodAuto, db.BulkMethodCopy, db.BulkMethodInsert),
+		),
+		mcp.WithNumber("batch_size",
+			mcp.Description(fmt.Sprintf("Rows per batch (default %d, maximum %d).", db.DefaultBulkBatchSize, db.MaxBulkBatchSize)),
+		),
+		withTimeoutArgument(),
+		mcp.WithRawOutputSchema(json.RawMessage(validOutputSchema)),
+	)
+
+	s.AddTool(importFileTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		table := request.GetString("table", "")
+		file := request.GetString("path", "")
+		createTable := request.GetBool("create_table", false)
+
+		// 从请求头中获取数据库 URI，用于多租户多数据库访问。
+		headerURI := request.Header.Get("X-Database-URI")
+		useURI, _, missingHeader := resolveDBTarget(headerURI, db.IsDefaultPoolInitialized())
+		if missingHeader {
+			return mcp.NewToolResultError("missing X-Database-URI header"), nil
+		}
+
+		ctx, err := withSessionTx(ctx, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Transaction conflict", err), nil
+		}
+
+		path, err := resolveImportPath(config.ImportDir, file)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid import-file request", err), nil
+		}
+		format, err := importFormat(path, request.GetString("format", ""))
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid import-file request", err), nil
+		}
+		batchSize := request.GetInt("batch_size", 0)
+		if batchSize < 0 {
+			return mcp.NewToolResultError("batch_size must be positive"), nil
+		}
+		name, err := db.QuoteTableName(table)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid import-file request", err), nil
+		}
+		columns, rows, err := readImportFile(path, format, request.GetStringSlice("columns", nil))
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Failed to read import file", err), nil
+		}
+
+		// 读取表结构使用语句超时；表不存在时按需建表
+		lookupCtx, cancelLookup := db.WithStatementTimeout(ctx, db.StatementTimeoutFor(useURI))
+		tableColumns, err := db.GetTableColumnsWithURI(lookupCtx, useURI, name)
+		cancelLookup()
+		var createSQL string
+		var columnTypes map[string]string
+		switch {
+		case err != nil && db.IsUndefinedTable(err) && createTable:
+			createSQL, columnTypes = createTableStatement(name, columns, rows)
+		case err != nil && db.IsUndefinedTable(err):
+			return mcp.NewToolResultError(fmt.Sprintf("table %s does not exist; set create_table to create it from the file", table)), nil
+		case err != nil:
+			return mcp.NewToolResultErrorFromErr("Failed to read table columns", err), nil
+		default:
+			var issues []importIssue
+			if columns, issues = validateImportRows(columns, rows, tableColumns); len(issues) > 0 {
+				return importValidationResult(file, table, issues)
+			}
+		}
+
+		insertSQL, err := bulkInsertStatement(table, columns)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid import-file request", err), nil
+		}
+
+		// 写策略：建表语句和等价的 INSERT 语句都要检查
+		rules, database, err := policyRules(config.Policy, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Write policy check failed", err), nil
+		}
+		statements := []string{insertSQL}
+		if createSQL != "" {
+			statements = []string{createSQL, insertSQL}
+		}
+		for _, stmt := range statements {
+			if violation := rules.Check(stmt, database); violation != nil {
+				return policyResult(violation)
+			}
+		}
+		if violation := rules.CheckAffectedRows("INSERT", int64(len(rows))); violation != nil {
+			return policyResult(violation)
+		}
+		timeout, err := callTimeout(request, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid import-file request", err), nil
+		}
+		affected := int64(len(rows))
+		if result, err := confirmWrite(ctx, s, rules, database, useURI, strings.Join(statements, ";\n"), timeout, &affected); result != nil || err != nil {
+			return result, err
+		}
+
+		ctx, cancel := db.WithStatementTimeout(ctx, timeout)
+		defer cancel()
+
+		started := time.Now()
+		if createSQL != "" {
+			if _, err := db.ExecuteWriteQueryWithOptions(ctx, useURI, createSQL, db.WriteOptions{}); err != nil {
+				if db.IsTimeout(ctx, err) {
+					return timeoutResult(timeout, time.Since(started))
+				}
+				return mcp.NewToolResultErrorFromErr("Failed to create table", err), nil
+			}
+		}
+
+		notify := progressNotifier(ctx, s, request)
+		total := len(rows)
+		result, err := db.BulkInsert(ctx, useURI, table, columns, importArgs(rows), db.BulkOptions{
+			Method:    request.GetString("method", db.BulkMethodAuto),
+			BatchSize: batchSize,
+			OnBatch: func(progress *db.BulkResult) {
+				done := progress.RowsLoaded + progress.RowsFailed
+				notify(done, int64(total), fmt.Sprintf("loaded %d of %d rows", progress.RowsLoaded, total))
+			},
+		})
+		if err != nil {
+			if db.IsTimeout(ctx, err) {
+				return timeoutResult(timeout, time.Since(started))
+			}
+			return mcp.NewToolResultErrorFromErr("Import failed", err), nil
+		}
+
+		data := map[string]interface{}{
+			"file":          file,
+			"format":        format,
+			"table_created": createSQL != "",
+			"load":          result,
+		}
+		if createSQL != "" {
+			data["create_sql"] = createSQL
+			data["column_types"] = columnTypes
+		}
+		response := map[string]interface{}{
+			"status": "success",
+			"type":   "import_result",
+			"data":   data,
+			"error":  nil,
+		}
+		if len(result.Errors) > 0 {
+			response["status"] = "error"
+			response["error"] = fmt.Sprintf("%d of %d batches failed", len(result.Errors), result.Batches)
+		}
+
+		jsonResult, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize result: %v", err)
+		}
+
+		toolResult := mcp.NewToolResultStructured(response, string(jsonResult))
+		toolResult.IsError = len(result.Errors) > 0 && result.RowsLoaded == 0
+		return toolResult, nil
+	})
+}
+
+// resolveImportPath resolves name inside dir, following symbolic links, and refuses files
+// outside dir. Absolute names and names leaving dir are refused before the file system is
+// touched, and a missing file gets the same error as a file outside dir, so calls cannot probe
+// which files exist on the host.
+func resolveImportPath(dir, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	notFound := fmt.Errorf("%s is not a file in the import directory", name)
+
+	cleaned := filepath.Clean(name)
+	if filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", notFound
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid import directory: %v", err)
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return "", fmt.Errorf("invalid import directory: %v", err)
+	}
+
+	resolved, err := filepath.EvalSymlinks(filepath.Join(root, cleaned))
+	if err != nil {
+		return "", notFound
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", notFound
+	}
+	return resolved, nil
+}
+
+// importFormat returns the requested format, or the one implied by the file extension.
+func importFormat(path, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case importFormatCSV, importFormatNDJSON:
+		return strings.ToLower(format), nil
+	case "":
+	default:
+		return "", fmt.Errorf("unknown format %q", format)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return importFormatCSV, nil
+	case ".ndjson", ".jsonl":
+		return importFormatNDJSON, nil
+	}
+	return "", fmt.Errorf("cannot tell the format of %s; set format to csv or ndjson", filepath.Base(path))
+}
+
+// readImportFile reads and parses an import file into column names and rows of raw values:
+// strings for CSV, JSON values for NDJSON, and nil for NULL.
+func readImportFile(path, format string, columns []string) ([]string, [][]interface{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil, fmt.Errorf("%s is not a regular file", filepath.Base(path))
+	}
+	if info.Size() > maxImportFileBytes {
+		return nil, nil, fmt.Errorf("%s is larger than %d MiB", filepath.Base(path), maxImportFileBytes>>20)
+	}
+	content, err := io.ReadAll(io.LimitReader(f, maxImportFileBytes+1))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if format == importFormatNDJSON {
+		return parseNDJSON(content, columns)
+	}
+	return parseBulkCSV(string(content), columns)
+}
+
+// parseNDJSON parses one JSON object per line. Without columns, the fields are loaded in the
+// order they first appear; a field missing from a line is NULL.
+func parseNDJSON(content []byte, columns []string) ([]string, [][]interface{}, error) {
+	var records []map[string]interface{}
+	var seen []string
+	known := map[string]bool{}
+	for i, line := range bytes.Split(content, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		keys, values, err := decodeObject(line)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		for _, key := range keys {
+			if !known[key] {
+				known[key] = true
+				seen = append(seen, key)
+			}
+		}
+		records = append(records, values)
+	}
+	if len(columns) == 0 {
+		columns = seen
+	}
+	if len(columns) == 0 {
+		return nil, nil, fmt.Errorf("the file has no fields to load")
+	}
+
+	rows := make([][]interface{}, len(records))
+	for i, record := range records {
+		row := make([]interface{}, len(columns))
+		for j, column := range columns {
+			row[j] = record[column]
+		}
+		rows[i] = row
+	}
+	return columns, rows, nil
+}
+
+// decodeObject decodes a JSON object, keeping the order of its keys and numbers as json.Number.
+func decodeObject(line []byte) ([]string, map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil, fmt.Errorf("not a JSON object")
+	}
+	var keys []string
+	values := map[string]interface{}{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key := tok.(string)
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		if _, dup := values[key]; !dup {
+			keys = append(keys, key)
+		}
+		values[key] = value
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, nil, fmt.Errorf("unexpected data after the JSON object")
+	}
+	return keys, values, nil
+}
+
+// validateImportRows matches the file columns to the table columns and checks every value
+// against the column type. It returns the exact table column names and the problems found.
+func validateImportRows(columns []string, rows [][]interface{}, tableColumns []map[string]interface{}) ([]string, []importIssue) {
+	type columnInfo struct {
+		name       string
+		dataType   string
+		nullable   bool
+		hasDefault bool
+		hidden     bool
+	}
+	var infos []columnInfo
+	for _, col := range tableColumns {
+		infos = append(infos, columnInfo{
+			name:       fmt.Sprint(col["column_name"]),
+			dataType:   fmt.Sprint(col["data_type"]),
+			nullable:   fmt.Sprint(col["is_nullable"]) != "false",
+			hasDefault: col["column_default"] != nil,
+			hidden:     fmt.Sprint(col["is_hidden"]) == "true",
+		})
+	}
+
+	var issues []importIssue
+	matched := make([]*columnInfo, len(columns))
+	mapped := make([]string, len(columns))
+	used := map[string]bool{}
+	for i, column := range columns {
+		// 优先精确匹配，否则接受大小写不同的唯一匹配
+		for j := range infos {
+			if infos[j].name == column {
+				matched[i] = &infos[j]
+				break
+			}
+		}
+		if matched[i] == nil {
+			var candidates []*columnInfo
+			for j := range infos {
+				if strings.EqualFold(infos[j].name, column) {
+					candidates = append(candidates, &infos[j])
+				}
+			}
+			switch len(candidates) {
+			case 0:
+				issues = append(issues, importIssue{Column: column, Message: "no such column in the table"})
+				continue
+			case 1:
+				matched[i] = candidates[0]
+			default:
+				issues = append(issues, importIssue{Column: column, Message: "the name matches several columns that differ only in case"})
+				continue
+			}
+		}
+		mapped[i] = matched[i].name
+		used[matched[i].name] = true
+	}
+	for _, info := range infos {
+		if !used[info.name] && !info.nullable && !info.hasDefault && !info.hidden {
+			issues = append(issues, importIssue{Column: info.name, Expected: info.dataType, Message: "the column is NOT NULL and missing from the file"})
+		}
+	}
+
+	for r, row := range rows {
+		for i, value := range row {
+			if len(issues) >= maxImportIssues {
+				return mapped, issues
+			}
+			info := matched[i]
+			switch {
+			case info == nil:
+			case value == nil && !info.nullable:
+				row := r
+				issues = append(issues, importIssue{Row: &row, Column: columns[i], Expected: info.dataType, Message: "NULL in a NOT NULL column"})
+			case !db.ValueFitsColumn(value, info.dataType):
+				row := r
+				issues = append(issues, importIssue{Row: &row, Column: columns[i], Value: value, Expected: info.dataType, Message: "the value does not match the column type"})
+			}
+		}
+	}
+	return mapped, issues
+}
+
+// createTableStatement builds a relational CREATE TABLE for the file, with the narrowest type
+// that holds every value of each column. Columns with only NULL values become STRING.
+func createTableStatement(name string, columns []string, rows [][]interface{}) (string, map[string]string) {
+	types := make([]string, len(columns))
+	for _, row := range rows {
+		for i, value := range row {
+			types[i] = db.WidenType(types[i], db.InferValueType(value))
+		}
+	}
+
+	columnTypes := make(map[string]string, len(columns))
+	defs := make([]string, len(columns))
+	for i, column := range columns {
+		if types[i] == "" {
+			types[i] = db.ImportTypeString
+		}
+		columnTypes[column] = types[i]
+		defs[i] = pq.QuoteIdentifier(column) + " " + types[i]
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s)", name, strings.Join(defs, ", ")), columnTypes
+}
+
+// importArgs converts raw file values to driver arguments: JSON objects and arrays become
+// JSON text and numbers keep their exact text.
+func importArgs(rows [][]interface{}) [][]interface{} {
+	for _, row := range rows {
+		for i, value := range row {
+			switch v := value.(type) {
+			case json.Number:
+				row[i] = v.String()
+			case map[string]interface{}, []interface{}:
+				encoded, err := json.Marshal(v)
+				if err == nil {
+					row[i] = string(encoded)
+				}
+			}
+		}
+	}
+	return rows
+}
+
+// importValidationResult reports a file whose values do not fit the target table.
+func importValidationResult(file, table string, issues []importIssue) (*mcp.CallToolResult, error) {
+	response := map[string]interface{}{
+		"status": "error",
+		"type":   "import_validation_failed",
+		"data": map[string]interface{}{
+			"file":   file,
+			"table":  table,
+			"issues": issues,
+		},
+		"error": fmt.Sprintf("the file does not match table %s; nothing was loaded", table),
+	}
+
+	jsonResult, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize result: %v", err)
+	}
+
+	result := mcp.NewToolResultStructured(response, string(jsonResult))
+	result.IsError = true
+	return result, nil
+}
+
+// progressNotifier returns a function that reports progress of the call with
+// notifications/progress. It does nothing when the caller did not send a progress token.
+func progressNotifier(ctx context.Context, s *server.MCPServer, request mcp.CallToolRequest) func(progress, total int64, message string) {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return func(int64, int64, string) {}
+	}
+	token := request.Params.Meta.ProgressToken
+	return func(progress, total int64, message string) {
+		err := s.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+			"progressToken": token,
+			"progress":      progress,
+			"total":         total,
+			"message":       message,
+		})
+		if err != nil {
+			log.Printf("Failed to send progress notification: %v", err)
+		}
+	}
+}
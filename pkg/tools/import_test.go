@@ -0,0 +1,117 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveImportPath(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "data.csv"), []byte("a\n1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret.csv"), []byte("a\n1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.csv"), filepath.Join(root, "link.csv")); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := resolveImportPath(root, "data.csv")
+	if err != nil || filepath.Base(path) != "data.csv" {
+		t.Fatalf("resolveImportPath(data.csv) = %q, %v", path, err)
+	}
+	for _, name := range []string{"", "../" + filepath.Base(outside) + "/secret.csv", filepath.Join(outside, "secret.csv"), "link.csv", "missing.csv"} {
+		if _, err := resolveImportPath(root, name); err == nil {
+			t.Errorf("resolveImportPath(%q) should fail", name)
+		}
+	}
+
+	// Files outside the directory cannot be told apart from missing ones.
+	_, errOutside := resolveImportPath(root, "link.csv")
+	_, errMissing := resolveImportPath(root, "missing.csv")
+	if strings.Replace(errOutside.Error(), "link.csv", "missing.csv", 1) != errMissing.Error() {
+		t.Errorf("errors differ: %q and %q", errOutside, errMissing)
+	}
+	if _, err := resolveImportPath(root, "sub/../data.csv"); err != nil {
+		t.Errorf("a path that stays inside the directory should resolve: %v", err)
+	}
+}
+
+func TestImportFormat(t *testing.T) {
+	if f, err := importFormat("/data/readings.JSONL", ""); err != nil || f != importFormatNDJSON {
+		t.Fatalf("expected ndjson from the extension, got %q, %v", f, err)
+	}
+	if f, err := importFormat("/data/readings.txt", "CSV"); err != nil || f != importFormatCSV {
+		t.Fatalf("the format argument should win, got %q, %v", f, err)
+	}
+	if _, err := importFormat("/data/readings.txt", ""); err == nil {
+		t.Fatal("an unknown extension without format should fail")
+	}
+}
+
+func TestParseNDJSON(t *testing.T) {
+	content := []byte("{\"ts\": \"2025-01-01 00:00:00\", \"value\": 1.5}\n\n{\"value\": 2, \"tags\": {\"site\": \"a\"}}\n")
+	columns, rows, err := parseNDJSON(content, nil)
+	if err != nil {
+		t.Fatalf("parseNDJSON returned error: %v", err)
+	}
+	if strings.Join(columns, ",") != "ts,value,tags" || len(rows) != 2 || rows[1][0] != nil {
+		t.Fatalf("unexpected result: %v %#v", columns, rows)
+	}
+	args := importArgs(rows)
+	if args[0][1] != "1.5" || args[1][2] != `{"site":"a"}` {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+
+	if _, _, err := parseNDJSON([]byte("{\"a\": 1}\n[1, 2]\n"), nil); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("a line that is not an object should be reported, got %v", err)
+	}
+	if _, _, err := parseNDJSON([]byte("{\"a\": 1} {\"b\": 2}\n"), nil); err == nil {
+		t.Fatal("two objects on one line should fail")
+	}
+}
+
+func TestValidateImportRows(t *testing.T) {
+	tableColumns := []map[string]interface{}{
+		{"column_name": "ts", "data_type": "TIMESTAMPTZ", "is_nullable": false, "column_default": nil, "is_hidden": false},
+		{"column_name": "value", "data_type": "FLOAT8", "is_nullable": true, "column_default": nil, "is_hidden": false},
+		{"column_name": "site", "data_type": "VARCHAR(20)", "is_nullable": false, "column_default": nil, "is_hidden": false},
+		{"column_name": "rowid", "data_type": "INT8", "is_nullable": false, "column_default": "unique_rowid()", "is_hidden": true},
+	}
+
+	columns, issues := validateImportRows([]string{"TS", "value", "site"}, [][]interface{}{
+		{"2025-01-01 00:00:00", "1.5", "a"},
+	}, tableColumns)
+	if len(issues) != 0 || strings.Join(columns, ",") != "ts,value,site" {
+		t.Fatalf("a matching file should pass, got %v %+v", columns, issues)
+	}
+
+	_, issues = validateImportRows([]string{"ts", "value", "extra"}, [][]interface{}{
+		{nil, "high", "x"},
+	}, tableColumns)
+	var messages []string
+	for _, issue := range issues {
+		messages = append(messages, issue.Column+": "+issue.Message)
+	}
+	want := "extra: no such column in the table|site: the column is NOT NULL and missing from the file|ts: NULL in a NOT NULL column|value: the value does not match the column type"
+	if strings.Join(messages, "|") != want {
+		t.Fatalf("unexpected issues:\n%s", strings.Join(messages, "\n"))
+	}
+}
+
+func TestCreateTableStatement(t *testing.T) {
+	stmt, types := createTableStatement(`"iot"."readings"`, []string{"ts", "value", "Note"}, [][]interface{}{
+		{"2025-01-01", "1", nil},
+		{"2025-01-01 00:00:01", "1.5", nil},
+	})
+	if stmt != `CREATE TABLE "iot"."readings" ("ts" TIMESTAMP, "value" FLOAT8, "Note" STRING)` {
+		t.Fatalf("unexpected statement %s", stmt)
+	}
+	if types["value"] != "FLOAT8" {
+		t.Fatalf("unexpected types %v", types)
+	}
+}
@@ -0,0 +1,277 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/jobs"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// registerQueryJobTools registers the tools that run read-only queries as background jobs.
+func registerQueryJobTools(s *server.MCPServer, config Config) {
+	submitTool := mcp.NewTool("submit-query",
+		mcp.WithDescription("Start a long read-only query on KWDB (KaiwuDB) as a background job and return its job_id at once. "+
+			"Poll query-status, read rows with fetch-query-result while or after it runs, and stop it with cancel-query-job. "+
+			"The submitting session is told about the job's progress with notifications/message logging notifications from logger query-job."),
+		mcp.WithString("sql",
+			mcp.Required(),
+			mcp.Description("SQL query to run. Only read operations like SELECT, SHOW, EXPLAIN are allowed. No LIMIT is added."),
+		),
+		withQueryParams(),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description("Timeout of the job in milliseconds. Can only lower the server's job timeout, which caps it."),
+		),
+		mcp.WithRawOutputSchema(json.RawMessage(validOutputSchema)),
+	)
+
+	s.AddTool(submitTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sql := request.GetString("sql", "")
+
+		// 从请求头中获取数据库 URI，作业只对同一数据库可见。
+		headerURI := request.Header.Get("X-Database-URI")
+		useURI, _, missingHeader := resolveDBTarget(headerURI, db.IsDefaultPoolInitialized())
+		if missingHeader {
+			return mcp.NewToolResultError("missing X-Database-URI header"), nil
+		}
+
+		if err := db.ValidateReadQuery(sql); err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid submit-query request", err), nil
+		}
+		args, err := parseQueryParams(request, sql)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid query parameters", err), nil
+		}
+
+		// 作业在后台运行，不受本次调用的 context 和会话事务影响
+		jobCtx := db.WithReadURI(context.Background(), request.Header.Get("X-Database-Read-URI"))
+		jobCtx, cancelTimeout, timeout, err := withTimeout(jobCtx, request, config.JobTimeout)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid submit-query request", err), nil
+		}
+
+		run := func(ctx context.Context, start func([]db.Column) error, row func([]interface{}) error) error {
+			defer cancelTimeout()
+			err := db.StreamQuery(ctx, useURI, sql, start, row, args...)
+			if err != nil && db.IsTimeout(ctx, err) {
+				return fmt.Errorf("statement timed out (timeout %s)", timeout)
+			}
+			return err
+		}
+		status, err := config.Jobs.Submit(jobCtx, useURI, sql, run, jobNotifier(s, sessionIDFromContext(ctx)))
+		if err != nil {
+			cancelTimeout()
+			return mcp.NewToolResultErrorFromErr("Failed to submit query", err), nil
+		}
+		return jobStatusResult(status)
+	})
+
+	statusTool := mcp.NewTool("query-status",
+		mcp.WithDescription("Report the state of a submit-query job: running, succeeded, failed or cancelled, with the rows read so far."),
+		withJobID(),
+		mcp.WithRawOutputSchema(json.RawMessage(validOutputSchema)),
+	)
+
+	s.AddTool(statusTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		useURI, errResult := jobDatabase(request)
+		if errResult != nil {
+			return errResult, nil
+		}
+		status, err := config.Jobs.Status(request.GetString("job_id", ""), useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid query-status request", err), nil
+		}
+		return jobStatusResult(status)
+	})
+
+	fetchTool := mcp.NewTool("fetch-query-result",
+		mcp.WithDescription("Fetch a page of the rows of a submit-query job, from offset. Rows can be fetched while the job runs; "+
+			"when metadata.more is true, call again with offset set to next_offset."),
+		withJobID(),
+		mcp.WithNumber("offset",
+			mcp.Description("Index of the first row to return (default 0)."),
+		),
+		mcp.WithNumber("page_size",
+			mcp.Description(fmt.Sprintf("Rows per page (default %d, max %d).", config.DefaultRows, config.MaxRows)),
+		),
+		mcp.WithNumber("max_bytes",
+			mcp.Description(fmt.Sprintf("Maximum serialized JSON bytes of the returned rows (default and max %d).", config.MaxResultBytes)),
+		),
+		withOutputFormat(),
+		mcp.WithRawOutputSchema(json.RawMessage(validOutputSchema)),
+	)
+
+	s.AddTool(fetchTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		useURI, errResult := jobDatabase(request)
+		if errResult != nil {
+			return errResult, nil
+		}
+		pageSize, err := budgetArgument(request, "page_size", config.DefaultRows, config.MaxRows)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid fetch-query-result request", err), nil
+		}
+		maxBytes, err := budgetArgument(request, "max_bytes", config.MaxResultBytes, config.MaxResultBytes)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid fetch-query-result request", err), nil
+		}
+		outputFormat := request.GetString("output_format", "")
+		if err := validateOutputFormat(outputFormat); err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid fetch-query-result request", err), nil
+		}
+
+		page, err := config.Jobs.Fetch(request.GetString("job_id", ""), useURI, request.GetInt("offset", 0), pageSize, maxBytes)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid fetch-query-result request", err), nil
+		}
+
+		var nextOffset interface{}
+		if page.More {
+			nextOffset = page.Offset + len(page.Rows)
+		}
+		metadata := map[string]interface{}{
+			"offset":    page.Offset,
+			"row_count": len(page.Rows),
+			"page_size": pageSize,
+			"max_bytes": maxBytes,
+			"bytes":     page.Bytes,
+			"more":      page.More,
+		}
+		data := map[string]interface{}{
+			"result_type": "table",
+			"job":         page.Status,
+			"columns":     page.Status.Columns,
+			"rows":        page.Rows,
+			"next_offset": nextOffset,
+			"metadata":    metadata,
+		}
+		response := map[string]interface{}{
+			"status": "success",
+			"type":   "query_job_result",
+			"data":   data,
+			"error":  nil,
+		}
+
+		if outputFormat != "" {
+			columns := make([]string, len(page.Status.Columns))
+			for i, col := range page.Status.Columns {
+				columns[i] = col.Name
+			}
+			table, err := renderTable(outputFormat, columns, page.Rows)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render result: %v", err)
+			}
+			metadata["output"] = newOutputInfo(outputFormat, table)
+			summary := map[string]interface{}{
+				"status": "success",
+				"type":   "query_job_result",
+				"data": map[string]interface{}{
+					"result_type": "table",
+					"job":         page.Status,
+					"next_offset": nextOffset,
+					"metadata":    metadata,
+				},
+				"error": nil,
+			}
+			return tableResult(response, summary, table)
+		}
+
+		jsonResult, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize result: %v", err)
+		}
+		return mcp.NewToolResultStructured(response, string(jsonResult)), nil
+	})
+
+	cancelTool := mcp.NewTool("cancel-query-job",
+		mcp.WithDescription("Cancel a running submit-query job. The rows read before it stopped can still be fetched."),
+		withJobID(),
+		mcp.WithRawOutputSchema(json.RawMessage(validOutputSchema)),
+	)
+
+	s.AddTool(cancelTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		useURI, errResult := jobDatabase(request)
+		if errResult != nil {
+			return errResult, nil
+		}
+		status, err := config.Jobs.Cancel(request.GetString("job_id", ""), useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid cancel-query-job request", err), nil
+		}
+		return jobStatusResult(status)
+	})
+}
+
+// withJobID declares the job_id argument.
+func withJobID() mcp.ToolOption {
+	return mcp.WithString("job_id",
+		mcp.Required(),
+		mcp.Description("job_id returned by submit-query."),
+	)
+}
+
+// jobDatabase returns the database URI the job of a call belongs to.
+func jobDatabase(request mcp.CallToolRequest) (string, *mcp.CallToolResult) {
+	headerURI := request.Header.Get("X-Database-URI")
+	useURI, _, missingHeader := resolveDBTarget(headerURI, db.IsDefaultPoolInitialized())
+	if missingHeader {
+		return "", mcp.NewToolResultError("missing X-Database-URI header")
+	}
+	if strings.TrimSpace(request.GetString("job_id", "")) == "" {
+		return "", mcp.NewToolResultError("job_id is required")
+	}
+	return useURI, nil
+}
+
+// jobNotifier reports the progress of a job to the session that submitted it as logging
+// notifications. The submit-query call has already returned, so its progress token cannot be
+// used. Updates of a running job are sent at info level and the final state at notice level, or
+// warning when the job failed or was cancelled. It returns nil when the call has no session, and
+// stops once a notification cannot be sent, for example after the session closed.
+func jobNotifier(s *server.MCPServer, sessionID string) func(jobs.Status) {
+	if sessionID == "" {
+		return nil
+	}
+	var stopped atomic.Bool
+	return func(status jobs.Status) {
+		if stopped.Load() {
+			return
+		}
+		level := mcp.LoggingLevelInfo
+		switch status.State {
+		case jobs.StateSucceeded:
+			level = mcp.LoggingLevelNotice
+		case jobs.StateFailed, jobs.StateCancelled:
+			level = mcp.LoggingLevelWarning
+		}
+		err := s.SendLogMessageToSpecificClient(sessionID, mcp.NewLoggingMessageNotification(level, "query-job", map[string]any{
+			"message": fmt.Sprintf("job %s %s: %d rows", status.ID, status.State, status.RowCount),
+			"job":     status,
+		}))
+		if err != nil {
+			stopped.Store(true)
+			log.Printf("Failed to send job notification, stopping notifications of job %s: %v", status.ID, err)
+		}
+	}
+}
+
+// jobStatusResult builds the standardized response of the job tools.
+func jobStatusResult(status jobs.Status) (*mcp.CallToolResult, error) {
+	response := map[string]interface{}{
+		"status": "success",
+		"type":   "query_job",
+		"data":   status,
+		"error":  nil,
+	}
+
+	jsonResult, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize result: %v", err)
+	}
+	return mcp.NewToolResultStructured(response, string(jsonResult)), nil
+}
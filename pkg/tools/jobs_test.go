@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/jobs"
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+)
+
+func TestFetchQueryResultTool(t *testing.T) {
+	const uri = "postgres://root@localhost:26257/db1"
+	manager := jobs.NewManager(jobs.Options{Dir: t.TempDir()})
+	defer manager.Close()
+
+	s := mcpserver.NewMCPServer("test", "1.0", mcpserver.WithToolCapabilities(true))
+	RegisterToolsWithConfig(s, Config{Jobs: manager})
+	for _, name := range []string{"submit-query", "query-status", "fetch-query-result", "cancel-query-job"} {
+		if s.GetTool(name) == nil {
+			t.Fatalf("%s was not registered", name)
+		}
+	}
+
+	status, err := manager.Submit(context.Background(), uri, "SELECT id, name FROM t", func(ctx context.Context, start func([]db.Column) error, row func([]interface{}) error) error {
+		start([]db.Column{{Name: "id", Type: "INT8"}, {Name: "name", Type: "STRING"}})
+		row([]interface{}{int64(1), "a"})
+		return row([]interface{}{int64(2), nil})
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(uri string, args map[string]any) *mcp.CallToolResult {
+		t.Helper()
+		request := mcp.CallToolRequest{Header: http.Header{}, Params: mcp.CallToolParams{Name: "fetch-query-result", Arguments: args}}
+		request.Header.Set("X-Database-URI", uri)
+		result, err := s.GetTool("fetch-query-result").Handler(context.Background(), request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	// Wait until both rows are stored.
+	for {
+		if st, _ := manager.Status(status.ID, uri); st.Done() {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	result := call(uri, map[string]any{"job_id": status.ID, "output_format": "csv"})
+	if result.IsError || len(result.Content) != 2 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; text != "id,name\n1,a\n2,\n" {
+		t.Fatalf("unexpected CSV %q", text)
+	}
+
+	if result := call("postgres://root@localhost:26257/db2", map[string]any{"job_id": status.ID}); !result.IsError {
+		t.Fatal("a job should not be fetched for another database")
+	}
+}
@@ -25,6 +25,7 @@ var metricsHistoryInputSchema = []byte(`{
     "start_ms": {"type": "integer", "description": "Start time in Unix milliseconds"},
     "end_ms": {"type": "integer", "description": "End time in Unix milliseconds"},
     "sample_ms": {"type": "integer", "description": "Sampling interval in milliseconds"},
+    "output_format": {"type": "string", "enum": ["json_rows", "json_columnar", "csv", "markdown_table"], "description": "Format of the text content, one row per datapoint with columns name, timestamp_ms and value. Without it the text content is the full JSON response."},
     "queries": {
       "type": "array",
       "minItems": 1,
@@ -49,6 +50,8 @@ type metricsHistoryInput struct {
 	EndMS    int64                 `json:"end_ms"`
 	SampleMS int64                 `json:"sample_ms"`
 	Queries  []metricsHistoryQuery `json:"queries"`
+	// OutputFormat selects the text rendering of the datapoints; empty keeps the JSON response.
+	OutputFormat string `json:"output_format"`
 }
 
 type metricsHistoryQuery struct {
@@ -78,6 +81,7 @@ type metricsHistoryData struct {
 	EndMS        int64                  `json:"end_ms"`
 	SampleMS     int64                  `json:"sample_ms"`
 	Results      []metricsHistoryResult `json:"results"`
+	Output       *outputInfo            `json:"output,omitempty"`
 }
 
 type metricsHistoryResult struct {
@@ -151,6 +155,22 @@ func registerQueryMetricsHistoryTool(s *server.MCPServer, config Config) {
 			"error":  nil,
 		}
 
+		if input.OutputFormat != "" {
+			columns, rows := metricsHistoryTable(data.Results)
+			table, err := renderTable(input.OutputFormat, columns, rows)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render metrics result: %v", err)
+			}
+			data.Output = newOutputInfo(input.OutputFormat, table)
+			summary := map[string]any{
+				"status": "success",
+				"type":   "metrics_timeseries",
+				"data":   metricsHistorySummary(data),
+				"error":  nil,
+			}
+			return tableResult(response, summary, table)
+		}
+
 		jsonResult, err := json.MarshalIndent(response, "", "  ")
 		if err != nil {
 			return nil, fmt.Errorf("failed to serialize metrics result: %v", err)
@@ -160,6 +180,51 @@ func registerQueryMetricsHistoryTool(s *server.MCPServer, config Config) {
 	})
 }
 
+// metricsHistoryTable flattens the series into one row per datapoint. A metric queried more than
+// once is named with its aggregations, name:downsampler:source_aggregator:derivative.
+func metricsHistoryTable(results []metricsHistoryResult) ([]string, [][]interface{}) {
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.Name]++
+	}
+
+	rows := [][]interface{}{}
+	for _, result := range results {
+		name := result.Name
+		if counts[name] > 1 {
+			name = strings.Join([]string{name, result.Downsampler, result.SourceAggregator, result.Derivative}, ":")
+		}
+		for _, dp := range result.Datapoints {
+			rows = append(rows, []interface{}{name, dp.TimestampMS, dp.Value})
+		}
+	}
+	return []string{"name", "timestamp_ms", "value"}, rows
+}
+
+// metricsHistorySummary returns data without datapoints, which the rendered table holds.
+func metricsHistorySummary(data *metricsHistoryData) map[string]any {
+	results := make([]map[string]any, len(data.Results))
+	for i, result := range data.Results {
+		results[i] = map[string]any{
+			"name":              result.Name,
+			"downsampler":       result.Downsampler,
+			"source_aggregator": result.SourceAggregator,
+			"derivative":        result.Derivative,
+			"sources":           result.Sources,
+			"datapoint_count":   len(result.Datapoints),
+			"empty_series":      result.EmptySeries,
+		}
+	}
+	return map[string]any{
+		"admin_base_url": data.AdminBaseURL,
+		"start_ms":       data.StartMS,
+		"end_ms":         data.EndMS,
+		"sample_ms":      data.SampleMS,
+		"results":        results,
+		"output":         data.Output,
+	}
+}
+
 func resolveAdminBaseURL(headerValue string, defaultValue string) (string, bool) {
 	if trimmed := strings.TrimSpace(headerValue); trimmed != "" {
 		return trimmed, false
@@ -186,6 +251,9 @@ func validateMetricsHistoryInput(input metricsHistoryInput) error {
 	if input.EndMS-input.StartMS > maxMetricsHistoryWindow {
 		return fmt.Errorf("time window must not exceed %d ms", maxMetricsHistoryWindow)
 	}
+	if err := validateOutputFormat(input.OutputFormat); err != nil {
+		return err
+	}
 	for _, query := range input.Queries {
 		if strings.TrimSpace(query.Name) == "" {
 			return fmt.Errorf("query name must not be empty")
@@ -52,6 +52,12 @@ func TestValidateMetricsHistoryInput(t *testing.T) {
 	if err := validateMetricsHistoryInput(invalid); err == nil {
 		t.Fatal("start_ms=end_ms should fail validation")
 	}
+
+	invalid = valid
+	invalid.OutputFormat = "yaml"
+	if err := validateMetricsHistoryInput(invalid); err == nil {
+		t.Fatal("an unknown output_format should fail validation")
+	}
 }
 
 func TestBuildTSQueryRequest(t *testing.T) {
@@ -303,3 +309,71 @@ func TestQueryMetricsHistoryTool_DefaultAdminBaseURL(t *testing.T) {
 		t.Fatalf("expected success result, got tool error: %+v", result)
 	}
 }
+
+func TestMetricsHistoryTable(t *testing.T) {
+	results := []metricsHistoryResult{
+		{Name: "cpu", Downsampler: "avg", SourceAggregator: "sum", Derivative: "none", Datapoints: []metricsHistoryDatapoint{{TimestampMS: 1000, Value: 0.5}}},
+		{Name: "cpu", Downsampler: "max", SourceAggregator: "sum", Derivative: "none", Datapoints: []metricsHistoryDatapoint{{TimestampMS: 1000, Value: 0.9}}},
+		{Name: "mem", Downsampler: "avg", SourceAggregator: "sum", Derivative: "none", Datapoints: []metricsHistoryDatapoint{{TimestampMS: 2000, Value: 42}}},
+	}
+
+	columns, rows := metricsHistoryTable(results)
+	got, err := renderTable(outputCSV, columns, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "name,timestamp_ms,value\ncpu:avg:sum:none,1000,0.5\ncpu:max:sum:none,1000,0.9\nmem,2000,42\n"
+	if got != want {
+		t.Fatalf("unexpected table:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestQueryMetricsHistoryTool_OutputFormat(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"results": [{"query": {"name": "cr.node.sql.query.count"}, "datapoints": [{"timestampNanos": "1000000000", "value": 2}]}]}`))
+	}))
+	defer srv.Close()
+
+	s := mcpserver.NewMCPServer("test", "1.0", mcpserver.WithToolCapabilities(true))
+	RegisterToolsWithConfig(s, Config{DefaultAdminBaseURL: srv.URL})
+	tool := s.ListTools()["query-metrics-history"]
+
+	result, err := tool.Handler(context.Background(), mcp.CallToolRequest{
+		Params: mcp.CallToolParams{
+			Name: "query-metrics-history",
+			Arguments: map[string]any{
+				"start_ms":      1000,
+				"end_ms":        2000,
+				"sample_ms":     100,
+				"output_format": "markdown_table",
+				"queries": []map[string]any{
+					{"name": "cr.node.sql.query.count", "downsampler": "avg", "source_aggregator": "sum", "derivative": "none"},
+				},
+			},
+		},
+	})
+	if err != nil || result.IsError {
+		t.Fatalf("expected success result, got %+v, %v", result, err)
+	}
+	if len(result.Content) != 2 {
+		t.Fatalf("expected table and summary content, got %+v", result.Content)
+	}
+	table := result.Content[0].(mcp.TextContent).Text
+	if want := "| name | timestamp_ms | value |\n| --- | --- | --- |\n| cr.node.sql.query.count | 1000 | 2 |\n"; table != want {
+		t.Fatalf("unexpected table:\n%s", table)
+	}
+
+	var summary struct {
+		Data struct {
+			Results []map[string]any `json:"results"`
+			Output  outputInfo       `json:"output"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(result.Content[1].(mcp.TextContent).Text), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Data.Output.Bytes != len(table) || summary.Data.Results[0]["datapoints"] != nil {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+}
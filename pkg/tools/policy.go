@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/policy"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// policyRules returns the write policy rules of the database selected by connectionString,
+// together with its name, which unqualified table names resolve to. It fails when the database
+// cannot be determined but the policy has rules that depend on it, so the policy stays closed.
+func policyRules(p *policy.Policy, connectionString string) (policy.Rules, string, error) {
+	keys := db.TenantKeys(connectionString)
+	if len(keys) == 0 {
+		if p.DependsOnDatabase() {
+			return policy.Rules{}, "", fmt.Errorf("cannot determine the database of the connection string, which the write policy needs")
+		}
+		return p.RulesFor(nil), "", nil
+	}
+	return p.RulesFor(keys), keys[len(keys)-1], nil
+}
+
+// checkAffectedRowsPolicy applies max_affected_rows to a single DML statement, counting the
+// rows it would change with a rolled-back pre-check. It returns the count, so the confirmation
+// can show it without running the statement again, or nil when no pre-check ran. The static
+// rules are checked separately with policy.Rules.Check.
+func checkAffectedRowsPolicy(ctx context.Context, rules policy.Rules, connectionString, query string, args ...interface{}) (*int64, *policy.Violation, error) {
+	if rules.MaxAffectedRows <= 0 {
+		return nil, nil, nil
+	}
+	statements, err := db.SplitStatements(query)
+	if err != nil || len(statements) != 1 || statements[0].Category != db.CategoryDML {
+		return nil, nil, nil
+	}
+
+	affected, err := db.PrecheckAffectedRows(ctx, connectionString, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("affected rows pre-check failed: %v", err)
+	}
+	return &affected, rules.CheckAffectedRows(statements[0].Keyword, affected), nil
+}
+
+// policyResult reports a statement refused by the write policy, naming the rule that fired.
+func policyResult(v *policy.Violation) (*mcp.CallToolResult, error) {
+	response := map[string]interface{}{
+		"status": "rejected",
+		"type":   "policy_violation",
+		"data": map[string]interface{}{
+			"rule":      v.Rule,
+			"statement": v.Statement,
+			"message":   v.Message,
+		},
+		"error": v.Error(),
+	}
+
+	jsonResult, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize result: %v", err)
+	}
+
+	result := mcp.NewToolResultStructured(response, string(jsonResult))
+	result.IsError = true
+	return result, nil
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// maxResourceResultBytes caps the serialized rows of a result stored as a resource.
+	maxResourceResultBytes = 8 << 20
+	// resourcePreviewRows is the number of rows a read-query call with as_resource returns inline.
+	resourcePreviewRows = 5
+)
+
+// outputMIMETypes is the MIME type of a stored result in each output format.
+var outputMIMETypes = map[string]string{
+	outputJSONRows:     "application/json",
+	outputJSONColumnar: "application/json",
+	outputCSV:          "text/csv",
+	outputMarkdown:     "text/markdown",
+}
+
+// readQueryResource runs the statement of page without a row limit, stores the rendered result
+// for the session and returns a summary, a preview and a link to the kwdb://result/{id} resource.
+func readQueryResource(ctx context.Context, config Config, databaseURI, sessionID string, page *queryCursor, outputFormat string, timeout time.Duration) (*mcp.CallToolResult, error) {
+	if outputFormat == "" {
+		outputFormat = outputJSONColumnar
+	}
+
+	started := time.Now()
+	result, err := db.ExecuteQueryWithOptions(ctx, databaseURI, page.sql, db.QueryOptions{MaxBytes: maxResourceResultBytes}, page.args...)
+	if err != nil {
+		if db.IsTimeout(ctx, err) {
+			return timeoutResult(timeout, time.Since(started))
+		}
+		return mcp.NewToolResultErrorFromErr("Query execution failed", err), nil
+	}
+
+	text, err := renderTable(outputFormat, result.ColumnNames(), result.Rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render result: %v", err)
+	}
+	stored, err := config.Results.Put(sessionID, outputMIMETypes[outputFormat], text)
+	if err != nil {
+		return mcp.NewToolResultErrorFromErr("Query execution failed", err), nil
+	}
+
+	preview := result.Rows
+	if len(preview) > resourcePreviewRows {
+		preview = preview[:resourcePreviewRows]
+	}
+	output := newOutputInfo(outputFormat, text)
+	response := map[string]interface{}{
+		"status": "success",
+		"type":   "query_result_resource",
+		"data": map[string]interface{}{
+			"result_type": "resource",
+			"resource": map[string]interface{}{
+				"uri":           stored.URI,
+				"mime_type":     stored.MIMEType,
+				"format":        output.Format,
+				"bytes":         output.Bytes,
+				"approx_tokens": output.ApproxTokens,
+				"expires_at":    stored.ExpiresAt,
+			},
+			"columns": result.Columns,
+			"preview": preview,
+			"metadata": map[string]interface{}{
+				"row_count":    len(result.Rows),
+				"query":        page.sql,
+				"param_count":  page.paramCount,
+				"truncated":    result.Truncated,
+				"bytes":        result.Bytes,
+				"oversize_row": result.Oversize,
+				"duration_ms":  time.Since(started).Milliseconds(),
+			},
+		},
+		"error": nil,
+	}
+
+	jsonResult, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize result: %v", err)
+	}
+
+	toolResult := mcp.NewToolResultStructured(response, string(jsonResult))
+	// 完整结果通过资源链接按需读取，不放入工具文本
+	toolResult.Content = append(toolResult.Content, mcp.NewResourceLink(stored.URI, "result "+stored.ID, fmt.Sprintf("%d rows as %s", len(result.Rows), outputFormat), stored.MIMEType))
+	return toolResult, nil
+}
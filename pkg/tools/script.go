@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/policy"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// registerExecuteScriptTool registers the multi-statement script tool.
+func registerExecuteScriptTool(s *server.MCPServer, config Config) {
+	executeScriptTool := mcp.NewTool("execute-script",
+		mcp.WithDescription("Execute a multi-statement SQL script on KWDB (KaiwuDB), such as a migration that creates a table, inserts seed rows and adds an index. "+
+			"Statements are separated by semicolons and run in order on one connection. "+
+			"With atomic set, the whole script runs in one transaction and is rolled back if any statement fails."),
+		mcp.WithString("sql",
+			mcp.Required(),
+			mcp.Description("SQL script to execute. Session statements such as SET, BEGIN and COMMIT are not allowed; use atomic instead of explicit transaction control."),
+		),
+		mcp.WithBoolean("atomic",
+			mcp.Description("Run all statements in a single transaction (default false)."),
+		),
+		mcp.WithRawOutputSchema(json.RawMessage(validOutputSchema)),
+	)
+
+	s.AddTool(executeScriptTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		script := request.GetString("sql", "")
+		atomic := request.GetBool("atomic", false)
+
+		// 从请求头中获取数据库 URI，用于多租户多数据库访问。
+		headerURI := request.Header.Get("X-Database-URI")
+		useURI, _, missingHeader := resolveDBTarget(headerURI, db.IsDefaultPoolInitialized())
+		if missingHeader {
+			return mcp.NewToolResultError("missing X-Database-URI header"), nil
+		}
+
+		ctx, err := withSessionTx(ctx, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Transaction conflict", err), nil
+		}
+
+		// 写策略：静态规则逐条检查；影响行数上限在执行中检查，超限时回滚，因此要求 atomic
+		rules, database, err := policyRules(config.Policy, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Write policy check failed", err), nil
+		}
+		if violation := checkScriptPolicy(ctx, rules, database, script, atomic); violation != nil {
+			return policyResult(violation)
+		}
+		timeout, err := callTimeout(request, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid execute-script request", err), nil
+		}
+		if result, err := confirmWrite(ctx, s, rules, database, useURI, script, timeout, nil); result != nil || err != nil {
+			return result, err
+		}
+		ctx = db.WithMaxAffectedRows(ctx, rules.MaxAffectedRows)
+
+		ctx, cancel := db.WithStatementTimeout(ctx, timeout)
+		defer cancel()
+
+		started := time.Now()
+		result, err := db.ExecuteScript(ctx, useURI, script, atomic)
+		var scriptErr *db.ScriptError
+		if err != nil && !errors.As(err, &scriptErr) {
+			if db.IsTimeout(ctx, err) {
+				return timeoutResult(timeout, time.Since(started))
+			}
+			return mcp.NewToolResultErrorFromErr("Script execution failed", err), nil
+		}
+
+		var totalAffected int64
+		for _, stmt := range result.Statements {
+			totalAffected += stmt.AffectedRows
+		}
+
+		data := map[string]interface{}{
+			"result_type": "script",
+			"atomic":      result.Atomic,
+			"statements":  result.Statements,
+			"metadata": map[string]interface{}{
+				"statement_count":     len(result.Statements),
+				"total_affected_rows": totalAffected,
+				"duration_ms":         result.DurationMS,
+			},
+		}
+		response := map[string]interface{}{
+			"status": "success",
+			"type":   "script_result",
+			"data":   data,
+			"error":  nil,
+		}
+		var limitErr *db.AffectedRowsError
+		if errors.As(err, &limitErr) {
+			// Report the cap as a policy violation, with the statements that ran so far.
+			data["rule"] = policy.RuleMaxAffectedRows
+		}
+		if scriptErr != nil {
+			// Report the statements that ran together with the one that failed.
+			data["failed_index"] = scriptErr.Index
+			data["failed_sql"] = scriptErr.SQL
+			data["rolled_back"] = scriptErr.RolledBack
+			response["status"] = "error"
+			response["error"] = scriptErr.Error()
+		}
+
+		jsonResult, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize result: %v", err)
+		}
+
+		toolResult := mcp.NewToolResultStructured(response, string(jsonResult))
+		toolResult.IsError = scriptErr != nil
+		return toolResult, nil
+	})
+}
+
+// checkScriptPolicy applies the static write policy rules to every statement of script.
+// Outside a session transaction a script with DML must be atomic when max_affected_rows is
+// set, so that a statement over the cap can be rolled back.
+func checkScriptPolicy(ctx context.Context, rules policy.Rules, database, script string, atomic bool) *policy.Violation {
+	statements, err := db.SplitStatements(script)
+	if err != nil {
+		// Check refuses text the lexer cannot split
+		return rules.Check(script, database)
+	}
+	for _, stmt := range statements {
+		if v := rules.CheckStatement(stmt, database); v != nil {
+			return v
+		}
+		if rules.MaxAffectedRows > 0 && stmt.Category == db.CategoryDML && !atomic && db.GetTxManager().Get(sessionIDFromContext(ctx)) == nil {
+			return &policy.Violation{
+				Rule:      policy.RuleMaxAffectedRows,
+				Statement: stmt.Keyword,
+				Message:   "scripts with DML must set atomic so that statements over the affected rows limit can be rolled back",
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// withTimeoutArgument declares the per-call timeout_ms argument.
+func withTimeoutArgument() mcp.ToolOption {
+	return mcp.WithNumber("timeout_ms",
+		mcp.Description("Statement timeout for this call in milliseconds. Can only lower the server or tenant timeout, which caps it."),
+	)
+}
+
+// withCallTimeout bounds the statements of a call. timeout_ms, when the tool accepts it and the
+// caller gives it, lowers the tenant or server timeout of databaseURI.
+func withCallTimeout(ctx context.Context, request mcp.CallToolRequest, databaseURI string) (context.Context, context.CancelFunc, time.Duration, error) {
+	return withTimeout(ctx, request, db.StatementTimeoutFor(databaseURI))
+}
+
+// withTimeout bounds the statements of a call by timeout, the operator's limit, or by timeout_ms
+// when it is shorter. timeout_ms cannot raise the limit; a timeout of 0 sets no limit.
+func withTimeout(ctx context.Context, request mcp.CallToolRequest, timeout time.Duration) (context.Context, context.CancelFunc, time.Duration, error) {
+	timeout, err := requestTimeout(request, timeout)
+	if err != nil {
+		return ctx, func() {}, 0, err
+	}
+	ctx, cancel := db.WithStatementTimeout(ctx, timeout)
+	return ctx, cancel, timeout, nil
+}
+
+// callTimeout returns the statement timeout of a call on databaseURI, like withCallTimeout, for
+// calls that bound several steps separately, such as pre-checks before a confirmation.
+func callTimeout(request mcp.CallToolRequest, databaseURI string) (time.Duration, error) {
+	return requestTimeout(request, db.StatementTimeoutFor(databaseURI))
+}
+
+// requestTimeout lowers timeout to the timeout_ms of request when it is shorter.
+func requestTimeout(request mcp.CallToolRequest, timeout time.Duration) (time.Duration, error) {
+	if ms := request.GetInt("timeout_ms", 0); ms < 0 {
+		return 0, fmt.Errorf("timeout_ms must be positive")
+	} else if int64(ms) > int64(math.MaxInt64/time.Millisecond) {
+		// 过大的值换算为 time.Duration 时会溢出为负数，从而取消超时
+		return 0, fmt.Errorf("timeout_ms is too large")
+	} else if requested := time.Duration(ms) * time.Millisecond; ms > 0 && (timeout <= 0 || requested < timeout) {
+		timeout = requested
+	}
+	return timeout, nil
+}
+
+// timeoutResult is the standardized response of a call stopped by its statement timeout.
+func timeoutResult(timeout, elapsed time.Duration) (*mcp.CallToolResult, error) {
+	response := map[string]interface{}{
+		"status": "timeout",
+		"type":   "timeout",
+		"data": map[string]interface{}{
+			"timeout_ms": timeout.Milliseconds(),
+			"elapsed_ms": elapsed.Milliseconds(),
+		},
+		"error": fmt.Sprintf("statement timed out after %s (timeout %s)", elapsed.Round(time.Millisecond), timeout),
+	}
+
+	jsonResult, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize result: %v", err)
+	}
+	result := mcp.NewToolResultStructured(response, string(jsonResult))
+	result.IsError = true
+	return result, nil
+}
@@ -4,17 +4,71 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"regexp"
 	"strings"
+	"time"
 
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/audit"
 	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/export"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/jobs"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/policy"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/resources"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
+const (
+	// defaultPageSize is the number of rows read-query returns when page_size is not given.
+	defaultPageSize = 20
+	// defaultMaxRows caps page_size when Config.MaxRows is not set.
+	defaultMaxRows = 1000
+	// defaultMaxResultBytes caps the serialized rows of one call when Config.MaxResultBytes is not set.
+	defaultMaxResultBytes = 1 << 20
+)
+
 // Config controls tool registration defaults.
 type Config struct {
 	DefaultAdminBaseURL string
+	// DefaultRows is the number of rows returned per read-query call when page_size is not given.
+	DefaultRows int
+	// MaxRows is the largest page_size a caller may request.
+	MaxRows int
+	// MaxResultBytes is the largest serialized size of the rows returned by one call.
+	MaxResultBytes int
+	// Policy guards the write tools; nil allows every write.
+	Policy *policy.Policy
+	// ImportDir is the directory import-file reads from; the tool is not registered without it.
+	ImportDir string
+	// Exports is where export-query writes files; the tool is not registered without it.
+	Exports *export.Store
+	// Jobs runs the submit-query jobs; a manager with default limits is used when nil.
+	Jobs *jobs.Manager
+	// JobTimeout bounds a submit-query job unless the call gives timeout_ms (0 disables it).
+	JobTimeout time.Duration
+	// Results keeps the results read-query stores as resources; as_resource is refused without it.
+	Results *resources.ResultStore
+	// History holds the SQL calls recorded by the audit middleware; query-history is not registered without it.
+	History *audit.History
+}
+
+// withDefaults fills unset result budgets and keeps DefaultRows within MaxRows.
+func (c Config) withDefaults() Config {
+	if c.MaxRows <= 0 {
+		c.MaxRows = defaultMaxRows
+	}
+	if c.DefaultRows <= 0 {
+		c.DefaultRows = defaultPageSize
+	}
+	if c.DefaultRows > c.MaxRows {
+		c.DefaultRows = c.MaxRows
+	}
+	if c.MaxResultBytes <= 0 {
+		c.MaxResultBytes = defaultMaxResultBytes
+	}
+	if c.Jobs == nil {
+		c.Jobs = jobs.NewManager(jobs.Options{})
+	}
+	return c
 }
 
 // resolveDBTarget 决定本次请求使用哪个数据库：X-Database-URI 优先，无 header 时回退默认池，两者都无则报错。
@@ -36,11 +90,43 @@ func RegisterTools(s *server.MCPServer) {
 
 // RegisterToolsWithConfig registers all tools with the MCP server using default tool config.
 func RegisterToolsWithConfig(s *server.MCPServer, config Config) {
+	config = config.withDefaults()
+
 	// Register read query tool
-	registerReadQueryTool(s)
+	registerReadQueryTool(s, config)
 
 	// Register write query tool
-	registerWriteQueryTool(s)
+	registerWriteQueryTool(s, config)
+
+	// Register structured EXPLAIN tool
+	registerExplainQueryTool(s)
+
+	// Register multi-statement script tool
+	registerExecuteScriptTool(s, config)
+
+	// Register bulk ingestion tool
+	registerBulkInsertTool(s, config)
+
+	// Register file import tool
+	if config.ImportDir != "" {
+		registerImportFileTool(s, config)
+	}
+
+	// Register export tool
+	if config.Exports != nil {
+		registerExportQueryTool(s, config)
+	}
+
+	// Register background query job tools
+	registerQueryJobTools(s, config)
+
+	// Register query history tool
+	if config.History != nil {
+		registerQueryHistoryTool(s, config)
+	}
+
+	// Register session transaction tools
+	registerTransactionTools(s)
 
 	// Register metrics history tool
 	registerQueryMetricsHistoryTool(s, config)
@@ -50,28 +136,38 @@ func RegisterToolsWithConfig(s *server.MCPServer, config Config) {
 // tool schema do not reject tools due to empty or invalid outputSchema.
 var validOutputSchema = []byte(`{"type":"object"}`)
 
-var (
-	limitClausePattern = regexp.MustCompile(`(?is)\blimit\b`)
-	fetchClausePattern = regexp.MustCompile(`(?is)\bfetch\s+(first|next)\b`)
-)
-
 // registerReadQueryTool registers read query tool with concurrency and timeout support
-func registerReadQueryTool(s *server.MCPServer) {
+func registerReadQueryTool(s *server.MCPServer, config Config) {
 	// Create read query tool
 	readQueryTool := mcp.NewTool("read-query",
-		mcp.WithDescription("Execute SELECT, SHOW, EXPLAIN and other read-only queries on KWDB (KaiwuDB). SELECT queries without a LIMIT clause will automatically have LIMIT 20 added to prevent large result sets."),
+		mcp.WithDescription("Execute SELECT, SHOW, EXPLAIN and other read-only queries on KWDB (KaiwuDB). "+
+			fmt.Sprintf("Each call returns at most page_size rows (default %d) and at most max_bytes of serialized rows, whatever the SQL says. ", config.DefaultRows)+
+			"When the result is cut short, metadata.truncated is true and next_cursor holds a token; pass it as cursor to fetch the next page. "+
+			"Inside a read-write session transaction, only common read-only built-in functions may be called."),
 		mcp.WithString("sql",
-			mcp.Required(),
-			mcp.Description("SQL query to execute. Only read operations like SELECT, SHOW, EXPLAIN are allowed."),
+			mcp.Description("SQL query to execute. Only read operations like SELECT, SHOW, EXPLAIN are allowed. Required unless cursor is given."),
+		),
+		withQueryParams(),
+		mcp.WithString("cursor",
+			mcp.Description("next_cursor token from a previous read-query call. Fetches the next page of the same statement; sql and params are ignored."),
+		),
+		mcp.WithNumber("page_size",
+			mcp.Description(fmt.Sprintf("Rows per page (default %d, max %d).", config.DefaultRows, config.MaxRows)),
+		),
+		mcp.WithNumber("max_bytes",
+			mcp.Description(fmt.Sprintf("Maximum serialized JSON bytes of the returned rows (default and max %d).", config.MaxResultBytes)),
+		),
+		withTimeoutArgument(),
+		withOutputFormat(),
+		mcp.WithBoolean("as_resource",
+			mcp.Description(fmt.Sprintf("Store the whole result, up to %d MiB of rows, as a kwdb://result/{id} resource in output_format (default json_columnar) "+
+				"and return a summary, a preview of %d rows and a resource link instead of a page. No LIMIT is added and cursor cannot be used. Needs a client session, which alone can read the result.", maxResourceResultBytes>>20, resourcePreviewRows)),
 		),
 		mcp.WithRawOutputSchema(json.RawMessage(validOutputSchema)),
 	)
 
 	// Add read query handler
 	s.AddTool(readQueryTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		sql := request.GetString("sql", "")
-		originalSQL := sql
-
 		// 从请求头中获取数据库 URI，用于多租户多数据库访问。
 		// 兼容模式下，如果未提供 header 且默认连接池已初始化，则回退到单库连接池。
 		headerURI := request.Header.Get("X-Database-URI")
@@ -80,53 +176,130 @@ func registerReadQueryTool(s *server.MCPServer) {
 			return mcp.NewToolResultError("missing X-Database-URI header"), nil
 		}
 
-		// Check if the query is a SELECT statement without LIMIT
-		if isSelectWithoutLimit(sql) {
-			// Add LIMIT 20 to the query
-			sql = addLimitToQuery(sql, 20)
+		ctx, err := withSessionTx(ctx, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Transaction conflict", err), nil
+		}
+		// 可选的只读连接串（例如只读角色），读查询优先使用该连接池。
+		ctx = db.WithReadURI(ctx, request.Header.Get("X-Database-Read-URI"))
+
+		asResource := request.GetBool("as_resource", false)
+		if asResource && request.GetString("cursor", "") != "" {
+			return mcp.NewToolResultError("as_resource cannot be combined with cursor"), nil
+		}
+		if asResource && config.Results == nil {
+			return mcp.NewToolResultError("result resources are not enabled on this server"), nil
+		}
+
+		sessionID := sessionIDFromContext(ctx)
+		if asResource && sessionID == "" {
+			// 结果资源只能由存储它的会话读取，没有会话时无法安全地保存
+			return mcp.NewToolResultError("as_resource needs a client session"), nil
+		}
+		page, err := resolveReadQueryPage(request, config, sessionID, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid read-query request", err), nil
 		}
+		outputFormat := request.GetString("output_format", "")
+		if err := validateOutputFormat(outputFormat); err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid read-query request", err), nil
+		}
+
+		ctx, cancel, timeout, err := withCallTimeout(ctx, request, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid read-query request", err), nil
+		}
+		defer cancel()
+
+		if asResource {
+			return readQueryResource(ctx, config, useURI, sessionID, page, outputFormat, timeout)
+		}
+
+		// Let the server stop after the page plus one row, so the scan can tell whether more rows follow.
+		// The cursor offset goes into the SQL when possible; otherwise the scan skips the rows before the page.
+		sql, autoLimited, offsetPushed := db.ApplyRowPage(page.sql, page.offset, page.pageSize+1)
 
-		var (
-			result []map[string]interface{}
-			err    error
-		)
-		if useURI != "" {
-			result, err = db.ExecuteQueryWithURI(ctx, useURI, sql)
-		} else {
-			result, err = db.ExecuteQueryWithContext(ctx, sql)
+		opts := db.QueryOptions{Offset: page.offset, MaxRows: page.pageSize, MaxBytes: page.maxBytes}
+		if offsetPushed {
+			opts.Offset = 0
 		}
+		started := time.Now()
+		result, err := db.ExecuteQueryWithOptions(ctx, useURI, sql, opts, page.args...)
 		if err != nil {
+			if db.IsTimeout(ctx, err) {
+				return timeoutResult(timeout, time.Since(started))
+			}
 			return mcp.NewToolResultErrorFromErr("Query execution failed", err), nil
 		}
+		// 页面已成功读取，消费本次使用的游标；失败的调用可以用同一游标重试
+		if token := request.GetString("cursor", ""); token != "" {
+			readQueryCursors.remove(token)
+		}
 
-		// Extract column names (if result is not empty)
-		var columns []string
-		if len(result) > 0 {
-			columns = make([]string, 0, len(result[0]))
-			for col := range result[0] {
-				columns = append(columns, col)
+		var nextCursor interface{}
+		if result.Truncated && len(result.Rows) > 0 {
+			next := *page
+			next.sessionID = sessionID
+			next.databaseURI = useURI
+			next.offset = page.offset + len(result.Rows)
+			token, err := readQueryCursors.put(next)
+			if err != nil {
+				return mcp.NewToolResultErrorFromErr("Query execution failed", err), nil
 			}
+			nextCursor = token
 		}
 
-		// Standardized success response
+		// Standardized success response. Columns keep SELECT order and rows are
+		// positional arrays aligned with columns, so duplicate names survive.
+		metadata := map[string]interface{}{
+			"affected_rows":  0,
+			"row_count":      len(result.Rows),
+			"query":          sql,
+			"original_query": page.sql,
+			"auto_limited":   autoLimited,
+			"param_count":    page.paramCount,
+			"offset":         page.offset,
+			"page_size":      page.pageSize,
+			"max_bytes":      page.maxBytes,
+			"truncated":      result.Truncated,
+			"rows_scanned":   result.RowsScanned,
+			"bytes":          result.Bytes,
+			"oversize_row":   result.Oversize,
+		}
 		response := map[string]interface{}{
 			"status": "success",
 			"type":   "query_result",
 			"data": map[string]interface{}{
 				"result_type": "table",
-				"columns":     columns,
-				"rows":        result,
-				"metadata": map[string]interface{}{
-					"affected_rows":  0,
-					"row_count":      len(result),
-					"query":          sql,
-					"original_query": originalSQL,
-					"auto_limited":   sql != originalSQL,
-				},
+				"columns":     result.Columns,
+				"rows":        result.Rows,
+				"next_cursor": nextCursor,
+				"metadata":    metadata,
 			},
 			"error": nil,
 		}
 
+		if outputFormat != "" {
+			table, err := renderTable(outputFormat, result.ColumnNames(), result.Rows)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render result: %v", err)
+			}
+			metadata["output"] = newOutputInfo(outputFormat, table)
+			// The summary repeats the response without its rows, which the table already holds.
+			summary := map[string]interface{}{
+				"status": "success",
+				"type":   "query_result",
+				"data": map[string]interface{}{
+					"result_type": "table",
+					"columns":     result.Columns,
+					"next_cursor": nextCursor,
+					"metadata":    metadata,
+				},
+				"error": nil,
+			}
+			return tableResult(response, summary, table)
+		}
+
 		// Convert result to JSON for text fallback (backward compatible with clients
 		// that only read content[].text).
 		jsonResult, err := json.MarshalIndent(response, "", "  ")
@@ -140,15 +313,73 @@ func registerReadQueryTool(s *server.MCPServer) {
 	})
 }
 
+// resolveReadQueryPage returns the statement and page a read-query call should run,
+// either from its sql/params arguments or from the cursor it continues.
+func resolveReadQueryPage(request mcp.CallToolRequest, config Config, sessionID, databaseURI string) (*queryCursor, error) {
+	if token := request.GetString("cursor", ""); token != "" {
+		return readQueryCursors.get(token, sessionID, databaseURI)
+	}
+
+	sql := request.GetString("sql", "")
+	if strings.TrimSpace(sql) == "" {
+		return nil, fmt.Errorf("sql is required unless cursor is given")
+	}
+	if err := db.ValidateReadQuery(sql); err != nil {
+		return nil, err
+	}
+
+	pageSize, err := budgetArgument(request, "page_size", config.DefaultRows, config.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	maxBytes, err := budgetArgument(request, "max_bytes", config.MaxResultBytes, config.MaxResultBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	args, err := parseQueryParams(request, sql)
+	if err != nil {
+		return nil, err
+	}
+
+	return &queryCursor{
+		sql:        sql,
+		args:       args,
+		paramCount: len(args),
+		pageSize:   pageSize,
+		maxBytes:   maxBytes,
+	}, nil
+}
+
+// budgetArgument reads a positive per-call budget, using def when absent and capping it at max.
+func budgetArgument(request mcp.CallToolRequest, name string, def, max int) (int, error) {
+	value := request.GetInt(name, def)
+	if value <= 0 {
+		return 0, fmt.Errorf("%s must be positive", name)
+	}
+	if value > max {
+		value = max
+	}
+	return value, nil
+}
+
 // registerWriteQueryTool 注册写查询工具，支持并发和超时
-func registerWriteQueryTool(s *server.MCPServer) {
+func registerWriteQueryTool(s *server.MCPServer, config Config) {
 	// Create write query tool
 	writeQueryTool := mcp.NewTool("write-query",
 		mcp.WithDescription("Execute data modification queries including DML and DDL operations on KWDB (KaiwuDB)"),
 		mcp.WithString("sql",
 			mcp.Required(),
-			mcp.Description("SQL query to execute. Supports all write operations including INSERT, UPDATE, DELETE, CREATE, DROP, ALTER, etc."),
+			mcp.Description("SQL query to execute. Supports all write operations including INSERT, UPDATE, DELETE, CREATE, DROP, ALTER, etc. "+
+				"Rows produced by a RETURNING clause are included in the result."),
 		),
+		withQueryParams(),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Run the statement in a transaction that is always rolled back and report its impact. "+
+				fmt.Sprintf("INSERT, UPSERT, UPDATE and DELETE also return a preview of up to %d changed rows. ", config.DefaultRows)+
+				"Only DML is supported, and not inside a session transaction."),
+		),
+		withTimeoutArgument(),
 		mcp.WithRawOutputSchema(json.RawMessage(validOutputSchema)),
 	)
 
@@ -164,31 +395,100 @@ func registerWriteQueryTool(s *server.MCPServer) {
 			return mcp.NewToolResultError("missing X-Database-URI header"), nil
 		}
 
-		var (
-			rowsAffected int64
-			err          error
-		)
-		if useURI != "" {
-			rowsAffected, err = db.ExecuteWriteQueryWithURI(ctx, useURI, sql)
-		} else {
-			rowsAffected, err = db.ExecuteWriteQueryWithContext(ctx, sql)
+		ctx, err := withSessionTx(ctx, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Transaction conflict", err), nil
+		}
+
+		args, err := parseQueryParams(request, sql)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid query parameters", err), nil
+		}
+
+		opts := db.WriteOptions{
+			DryRun: request.GetBool("dry_run", false),
+			Rows:   db.QueryOptions{MaxRows: config.DefaultRows, MaxBytes: config.MaxResultBytes},
+		}
+
+		// 写策略检查；dry run 总会回滚，因此不需要用户确认和影响行数预检查
+		rules, database, err := policyRules(config.Policy, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Write policy check failed", err), nil
+		}
+		if violation := rules.Check(sql, database); violation != nil {
+			return policyResult(violation)
+		}
+		timeout, err := callTimeout(request, useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Invalid write-query request", err), nil
+		}
+		if !opts.DryRun {
+			// 影响行数只预检查一次：先检查上限，再把结果用于确认信息
+			precheckCtx, cancelPrecheck := db.WithStatementTimeout(ctx, timeout)
+			started := time.Now()
+			affected, violation, err := checkAffectedRowsPolicy(precheckCtx, rules, useURI, sql, args...)
+			timedOut := db.IsTimeout(precheckCtx, err)
+			cancelPrecheck()
+			if err != nil {
+				if timedOut {
+					return timeoutResult(timeout, time.Since(started))
+				}
+				return mcp.NewToolResultErrorFromErr("Write policy check failed", err), nil
+			}
+			if violation != nil {
+				return policyResult(violation)
+			}
+			// 等待用户确认的时间不计入语句超时
+			if result, err := confirmWrite(ctx, s, rules, database, useURI, sql, timeout, affected, args...); result != nil || err != nil {
+				return result, err
+			}
 		}
+
+		ctx, cancel := db.WithStatementTimeout(ctx, timeout)
+		defer cancel()
+
+		started := time.Now()
+		result, err := db.ExecuteWriteQueryWithOptions(ctx, useURI, sql, opts, args...)
 		if err != nil {
+			if db.IsTimeout(ctx, err) {
+				return timeoutResult(timeout, time.Since(started))
+			}
 			return mcp.NewToolResultErrorFromErr("Write operation failed", err), nil
 		}
 
+		metadata := map[string]interface{}{
+			"query":       sql,
+			"param_count": len(args),
+		}
+		data := map[string]interface{}{
+			"result_type":   "write",
+			"affected_rows": result.AffectedRows,
+			"metadata":      metadata,
+		}
+		if result.Returned != nil {
+			data["returned"] = returnedRows(result.Returned)
+		}
+		if result.DryRun {
+			// Nothing was committed; the preview shows the rows the statement would change.
+			data["dry_run"] = true
+			data["rolled_back"] = true
+			var preview interface{}
+			if result.Preview != nil {
+				preview = returnedRows(result.Preview)
+				metadata["preview_query"] = result.PreviewQuery
+			}
+			if result.PreviewError != "" {
+				metadata["preview_error"] = result.PreviewError
+			}
+			data["preview"] = preview
+		}
+
 		// Standardized success response
 		response := map[string]interface{}{
 			"status": "success",
 			"type":   "write_result",
-			"data": map[string]interface{}{
-				"result_type":   "write",
-				"affected_rows": rowsAffected,
-				"metadata": map[string]interface{}{
-					"query": sql,
-				},
-			},
-			"error": nil,
+			"data":   data,
+			"error":  nil,
 		}
 
 		// Convert result to JSON for text fallback
@@ -201,58 +501,38 @@ func registerWriteQueryTool(s *server.MCPServer) {
 	})
 }
 
-// isSelectWithoutLimit checks if a SQL query is a SELECT statement without a LIMIT clause
-func isSelectWithoutLimit(sql string) bool {
-	// Convert to uppercase for case-insensitive comparison
-	sqlUpper := strings.ToUpper(sql)
-	sqlUpperTrimmed := strings.TrimSpace(sqlUpper)
-
-	// Check if it's a SELECT statement
-	if !strings.HasPrefix(sqlUpperTrimmed, "SELECT") {
-		return false
+// returnedRows renders rows produced by a write statement in the column and row shape of read-query.
+func returnedRows(set *db.ResultSet) map[string]interface{} {
+	return map[string]interface{}{
+		"columns":   set.Columns,
+		"rows":      set.Rows,
+		"row_count": len(set.Rows),
+		"truncated": set.Truncated,
 	}
-
-	// Check if it already has a LIMIT clause
-	if limitClausePattern.MatchString(sql) {
-		return false
-	}
-
-	// SQL-standard FETCH pagination is already an explicit row limit.
-	if fetchClausePattern.MatchString(sql) {
-		return false
-	}
-
-	// KWDB FILL clauses must remain the final clause in the statement.
-	if strings.Contains(sqlUpper, "FILL(") {
-		return false
-	}
-
-	// Check if it's a special case like EXPLAIN or SHOW
-	if strings.HasPrefix(sqlUpperTrimmed, "EXPLAIN") ||
-		strings.HasPrefix(sqlUpperTrimmed, "SHOW") {
-		return false
-	}
-
-	return true
 }
 
-// addLimitToQuery adds a LIMIT clause to a SQL query
-func addLimitToQuery(sql string, limit int) string {
-	// Check if the query ends with a semicolon
-	endsWithSemicolon := strings.TrimSpace(sql)[len(strings.TrimSpace(sql))-1] == ';'
+// withQueryParams declares the optional params argument shared by the query tools.
+func withQueryParams() mcp.ToolOption {
+	return mcp.WithArray("params",
+		mcp.Description("Optional values bound to the $1..$n placeholders in sql, in order. "+
+			"Use plain JSON values, or typed hints such as {\"type\": \"timestamptz\", \"value\": \"2025-01-01T00:00:00Z\"}. "+
+			"Supported hint types: string, int, float, bool, timestamp, timestamptz, date, decimal, bytes (base64), json, array (with optional element_type)."),
+	)
+}
 
-	// Remove the semicolon if present
-	if endsWithSemicolon {
-		sql = strings.TrimSpace(sql)[:len(strings.TrimSpace(sql))-1]
+// parseQueryParams reads the params argument and validates it against the placeholders in sql.
+func parseQueryParams(request mcp.CallToolRequest, sql string) ([]interface{}, error) {
+	var params []interface{}
+	if raw, ok := request.GetArguments()["params"]; ok && raw != nil {
+		list, ok := raw.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("params must be an array")
+		}
+		params = list
 	}
 
-	// Add the LIMIT clause
-	sql = fmt.Sprintf("%s LIMIT %d", sql, limit)
-
-	// Add back the semicolon if it was present
-	if endsWithSemicolon {
-		sql = sql + ";"
+	if err := db.ValidatePlaceholders(sql, len(params)); err != nil {
+		return nil, err
 	}
-
-	return sql
+	return db.BuildQueryArgs(params)
 }
@@ -1,7 +1,17 @@
 package tools
 
 import (
+	"context"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/policy"
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/resources"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
 )
 
 func TestResolveDBTarget_WithHeader(t *testing.T) {
@@ -33,71 +43,351 @@ func TestResolveDBTarget_NoHeaderNoDefaultPool(t *testing.T) {
 	}
 }
 
-func TestIsSelectWithoutLimit(t *testing.T) {
-	tests := []struct {
-		name string
-		sql  string
-		want bool
+func TestParseQueryParams(t *testing.T) {
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]any{
+		"sql":    "SELECT * FROM t WHERE id = $1 AND ts > $2",
+		"params": []any{float64(7), map[string]any{"type": "timestamp", "value": "2025-01-01 00:00:00"}},
+	}
+
+	args, err := parseQueryParams(request, request.GetString("sql", ""))
+	if err != nil {
+		t.Fatalf("parseQueryParams returned error: %v", err)
+	}
+	if len(args) != 2 || args[0] != int64(7) {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+
+	request.Params.Arguments = map[string]any{"sql": "SELECT * FROM t WHERE id = $1"}
+	if _, err := parseQueryParams(request, request.GetString("sql", "")); err == nil {
+		t.Fatal("missing params for placeholder should fail")
+	}
+
+	request.Params.Arguments = map[string]any{"sql": "SELECT 1", "params": "oops"}
+	if _, err := parseQueryParams(request, request.GetString("sql", "")); err == nil {
+		t.Fatal("non-array params should fail")
+	}
+}
+
+func TestResolveReadQueryPageBudgets(t *testing.T) {
+	config := Config{DefaultRows: 50, MaxRows: 10, MaxResultBytes: 4096}.withDefaults()
+	if config.DefaultRows != 10 {
+		t.Fatalf("DefaultRows should be capped at MaxRows, got %d", config.DefaultRows)
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]any{"sql": "SHOW TABLES"}
+	page, err := resolveReadQueryPage(request, config, "", "")
+	if err != nil {
+		t.Fatalf("resolveReadQueryPage returned error: %v", err)
+	}
+	if page.pageSize != 10 || page.maxBytes != 4096 {
+		t.Fatalf("unexpected default budgets: page_size=%d max_bytes=%d", page.pageSize, page.maxBytes)
+	}
+
+	request.Params.Arguments = map[string]any{"sql": "SHOW TABLES", "page_size": float64(500), "max_bytes": float64(100)}
+	page, err = resolveReadQueryPage(request, config, "", "")
+	if err != nil {
+		t.Fatalf("resolveReadQueryPage returned error: %v", err)
+	}
+	if page.pageSize != 10 || page.maxBytes != 100 {
+		t.Fatalf("per-call budgets should be capped by the server: page_size=%d max_bytes=%d", page.pageSize, page.maxBytes)
+	}
+
+	request.Params.Arguments = map[string]any{"sql": "SHOW TABLES", "page_size": float64(0)}
+	if _, err := resolveReadQueryPage(request, config, "", ""); err == nil {
+		t.Fatal("non-positive page_size should fail")
+	}
+
+	request.Params.Arguments = map[string]any{}
+	if _, err := resolveReadQueryPage(request, config, "", ""); err == nil {
+		t.Fatal("missing sql without cursor should fail")
+	}
+}
+
+func TestReturnedRowsMatchesReadQueryShape(t *testing.T) {
+	set := &db.ResultSet{
+		Columns:   []db.Column{{Name: "id", Type: "INT8"}},
+		Rows:      [][]interface{}{{int64(1)}, {int64(2)}},
+		Truncated: true,
+	}
+
+	got := returnedRows(set)
+	if got["row_count"] != 2 || got["truncated"] != true {
+		t.Fatalf("unexpected returned rows: %+v", got)
+	}
+	if cols, ok := got["columns"].([]db.Column); !ok || len(cols) != 1 || cols[0].Name != "id" {
+		t.Fatalf("columns should keep the read-query column shape, got %#v", got["columns"])
+	}
+}
+
+func TestWithCallTimeout(t *testing.T) {
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]interface{}{"timeout_ms": float64(1500)}
+	ctx, cancel, timeout, err := withCallTimeout(context.Background(), request, "")
+	if err != nil {
+		t.Fatalf("withCallTimeout returned error: %v", err)
+	}
+	defer cancel()
+	if timeout != 1500*time.Millisecond {
+		t.Fatalf("timeout = %v, want 1.5s", timeout)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		t.Fatal("call context should have a deadline")
+	}
+
+	request.Params.Arguments = map[string]interface{}{"timeout_ms": float64(-1)}
+	if _, _, _, err := withCallTimeout(context.Background(), request, ""); err == nil {
+		t.Fatal("negative timeout_ms should be rejected")
+	}
+	// A timeout_ms that overflows time.Duration must not leave the call unbounded.
+	request.Params.Arguments = map[string]interface{}{"timeout_ms": float64(1e16)}
+	if _, _, _, err := withTimeout(context.Background(), request, 30*time.Second); err == nil {
+		t.Fatal("an overflowing timeout_ms should be rejected")
+	}
+
+	// The configured timeout caps timeout_ms.
+	for _, tt := range []struct {
+		ms         float64
+		configured time.Duration
+		want       time.Duration
 	}{
-		{
-			name: "plain select without limit is auto-limited",
-			sql:  "SELECT * FROM test_table",
-			want: true,
-		},
-		{
-			name: "select with explicit limit is left alone",
-			sql:  "SELECT * FROM test_table LIMIT 10",
-			want: false,
-		},
-		{
-			name: "select with explicit limit on next line is left alone",
-			sql:  "SELECT * FROM test_table\nLIMIT 10",
-			want: false,
-		},
-		{
-			name: "select with explicit limit after tab is left alone",
-			sql:  "SELECT * FROM test_table\tLIMIT 10",
-			want: false,
-		},
-		{
-			name: "select with explicit limit and newline before count is left alone",
-			sql:  "SELECT * FROM test_table LIMIT\n10",
-			want: false,
-		},
-		{
-			name: "select with fetch first clause is left alone",
-			sql:  "SELECT * FROM test_table ORDER BY id FETCH FIRST 10 ROW ONLY",
-			want: false,
-		},
-		{
-			name: "select with offset fetch clause is left alone",
-			sql:  "SELECT * FROM test_table ORDER BY id OFFSET 5 ROWS FETCH NEXT 10 ROWS ONLY",
-			want: false,
-		},
-		{
-			name: "select with fill clause is left alone",
-			sql: `SELECT k_timestamp, data_int8 FROM test_fill_db.metric_numeric
-WHERE device_id = 'device1' AND k_timestamp = '2025-12-19 10:00:03'
-FILL(EXACT);`,
-			want: false,
-		},
+		{500, time.Second, 500 * time.Millisecond},
+		{86400000, time.Second, time.Second},
+		{0, time.Second, time.Second},
+		{86400000, 0, 24 * time.Hour},
+	} {
+		request.Params.Arguments = map[string]interface{}{"timeout_ms": tt.ms}
+		_, cancel, timeout, err := withTimeout(context.Background(), request, tt.configured)
+		if err != nil {
+			t.Fatalf("withTimeout returned error: %v", err)
+		}
+		cancel()
+		if timeout != tt.want {
+			t.Errorf("timeout_ms %v with limit %v: timeout = %v, want %v", tt.ms, tt.configured, timeout, tt.want)
+		}
+	}
+}
+
+func TestTimeoutResult(t *testing.T) {
+	result, err := timeoutResult(time.Second, 1200*time.Millisecond)
+	if err != nil || !result.IsError {
+		t.Fatalf("timeoutResult = %+v, %v", result, err)
+	}
+	response := result.StructuredContent.(map[string]interface{})
+	data := response["data"].(map[string]interface{})
+	if response["status"] != "timeout" || data["elapsed_ms"] != int64(1200) || data["timeout_ms"] != int64(1000) {
+		t.Fatalf("unexpected timeout response: %+v", response)
+	}
+}
+
+func TestPolicyResult(t *testing.T) {
+	result, err := policyResult(&policy.Violation{Rule: policy.RuleRequireWhere, Statement: "DELETE", Message: "DELETE without a WHERE clause is not allowed"})
+	if err != nil || !result.IsError {
+		t.Fatalf("policyResult = %+v, %v", result, err)
+	}
+	response := result.StructuredContent.(map[string]interface{})
+	data := response["data"].(map[string]interface{})
+	if response["status"] != "rejected" || data["rule"] != policy.RuleRequireWhere {
+		t.Fatalf("unexpected policy response: %+v", response)
+	}
+}
+
+func TestPolicyRules(t *testing.T) {
+	p := &policy.Policy{
+		Default: policy.Rules{DenyDatabases: []string{"iot"}},
+		Tenants: map[string]policy.Rules{"analytics": {RequireWhere: true}},
+	}
+	for _, dsn := range []string{
+		"postgresql://root@10.0.0.5:26257/iot",
+		"host=10.0.0.5 port=26257 dbname=iot user=root",
+	} {
+		rules, database, err := policyRules(p, dsn)
+		if err != nil || database != "iot" {
+			t.Fatalf("policyRules(%q) = %q, %v", dsn, database, err)
+		}
+		if v := rules.Check("DELETE FROM t", database); v == nil || v.Rule != policy.RuleDenyDatabases {
+			t.Errorf("%q: an unqualified write should be denied, got %v", dsn, v)
+		}
+	}
+	if rules, _, err := policyRules(p, "dbname=analytics host=10.0.0.5"); err != nil || !rules.RequireWhere {
+		t.Errorf("tenant rules of a key=value DSN were not found: %+v, %v", rules, err)
+	}
+
+	// Without a database, rules that depend on it cannot be applied.
+	if _, _, err := policyRules(p, "host=10.0.0.5 user=root"); err == nil {
+		t.Error("a connection string without a database should be refused")
+	}
+	if _, _, err := policyRules(&policy.Policy{Default: policy.Rules{RequireWhere: true}}, "host=10.0.0.5 user=root"); err != nil {
+		t.Errorf("rules that do not depend on the database should apply: %v", err)
+	}
+}
+
+func TestCheckScriptPolicy(t *testing.T) {
+	rules := policy.Rules{BlockStatements: []string{"DROP DATABASE"}, MaxAffectedRows: 10}
+	script := "CREATE TABLE t (a INT); INSERT INTO t VALUES (1)"
+
+	if v := checkScriptPolicy(context.Background(), rules, "shop", script+"; DROP DATABASE shop", true); v == nil || v.Rule != policy.RuleBlockStatements {
+		t.Fatalf("expected block_statements, got %v", v)
+	}
+	if v := checkScriptPolicy(context.Background(), rules, "shop", script, false); v == nil || v.Rule != policy.RuleMaxAffectedRows {
+		t.Fatalf("a non-atomic script with DML should be refused under a cap, got %v", v)
+	}
+	if v := checkScriptPolicy(context.Background(), rules, "shop", script, true); v != nil {
+		t.Fatalf("an atomic script should pass, got %v", v)
+	}
+}
+
+// elicitationFunc answers elicitation requests in tests.
+type elicitationFunc func(context.Context, mcp.ElicitationRequest) (*mcp.ElicitationResult, error)
+
+func (f elicitationFunc) Elicit(ctx context.Context, request mcp.ElicitationRequest) (*mcp.ElicitationResult, error) {
+	return f(ctx, request)
+}
+
+func TestConfirmWrite(t *testing.T) {
+	s := server.NewMCPServer("test", "1.0.0", server.WithElicitation())
+	rules := policy.Rules{ConfirmStatements: []string{"DROP TABLE"}}
+
+	if result, err := confirmWrite(context.Background(), s, rules, "shop", "", "INSERT INTO t VALUES (1)", 0, nil); result != nil || err != nil {
+		t.Fatalf("a statement without a confirmation rule should run, got %+v, %v", result, err)
+	}
+	// SQL the lexer cannot split is refused rather than run unconfirmed.
+	if result, err := confirmWrite(context.Background(), s, rules, "shop", "", "DROP TABLE 't", 0, nil); err != nil || result == nil || !result.IsError {
+		t.Fatalf("unparsable SQL should be refused, got %+v, %v", result, err)
+	}
+
+	// Without elicitation support the default fallback refuses.
+	result, err := confirmWrite(context.Background(), s, rules, "shop", "", "DROP TABLE t", 0, nil)
+	if err != nil || result == nil || !result.IsError {
+		t.Fatalf("expected a refusal, got %+v, %v", result, err)
+	}
+	if kind := result.StructuredContent.(map[string]interface{})["type"]; kind != "confirmation_required" {
+		t.Fatalf("type = %v, want confirmation_required", kind)
+	}
+	allow := rules
+	allow.ConfirmFallback = policy.FallbackAllow
+	if result, err := confirmWrite(context.Background(), s, allow, "shop", "", "DROP TABLE t", 0, nil); result != nil || err != nil {
+		t.Fatalf("confirm_fallback allow should run the statement, got %+v, %v", result, err)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := isSelectWithoutLimit(tt.sql)
-			if got != tt.want {
-				t.Fatalf("isSelectWithoutLimit(%q) = %v, want %v", tt.sql, got, tt.want)
+	ask := func(answer mcp.ElicitationResponse) context.Context {
+		session := server.NewInProcessSessionWithHandlers("confirm-test", nil, elicitationFunc(func(_ context.Context, request mcp.ElicitationRequest) (*mcp.ElicitationResult, error) {
+			if !strings.Contains(request.Params.Message, "DROP TABLE t") {
+				t.Errorf("confirmation message should show the statement, got %q", request.Params.Message)
 			}
-		})
+			return &mcp.ElicitationResult{ElicitationResponse: answer}, nil
+		}))
+		session.SetClientCapabilities(mcp.ClientCapabilities{Elicitation: &struct{}{}})
+		return s.WithContext(context.Background(), session)
+	}
+
+	ctx := ask(mcp.ElicitationResponse{Action: mcp.ElicitationResponseActionAccept, Content: map[string]interface{}{"confirm": true}})
+	if result, err := confirmWrite(ctx, s, rules, "shop", "", "DROP TABLE t", 0, nil); result != nil || err != nil {
+		t.Fatalf("a confirmed statement should run, got %+v, %v", result, err)
+	}
+	for _, answer := range []mcp.ElicitationResponse{
+		{Action: mcp.ElicitationResponseActionAccept, Content: map[string]interface{}{"confirm": false}},
+		{Action: mcp.ElicitationResponseActionDecline},
+	} {
+		result, err := confirmWrite(ask(answer), s, rules, "shop", "", "DROP TABLE t", 0, nil)
+		if err != nil || result == nil {
+			t.Fatalf("answer %+v should stop the call, got %+v, %v", answer, result, err)
+		}
+		if kind := result.StructuredContent.(map[string]interface{})["type"]; kind != "confirmation_declined" {
+			t.Fatalf("type = %v, want confirmation_declined", kind)
+		}
+	}
+
+	// The count of an earlier max_affected_rows pre-check is shown without running the statement again.
+	affected := int64(42)
+	deletes := policy.Rules{ConfirmStatements: []string{"DELETE"}}
+	result, err = confirmWrite(context.Background(), s, deletes, "shop", "", "DELETE FROM t", time.Second, &affected)
+	if err != nil || result == nil {
+		t.Fatalf("expected a refusal, got %+v, %v", result, err)
+	}
+	impact := result.StructuredContent.(map[string]interface{})["data"].(map[string]interface{})["impact"].(writeImpact)
+	if impact.AffectedRows == nil || *impact.AffectedRows != 42 {
+		t.Fatalf("impact = %+v, want 42 affected rows", impact)
+	}
+}
+
+func TestParseBulkRows(t *testing.T) {
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]any{
+		"columns": []any{"ts", "value"},
+		"rows": []any{
+			[]any{"2025-01-01 00:00:00", float64(1.5)},
+			[]any{map[string]any{"type": "timestamp", "value": "2025-01-01 00:00:01"}, nil},
+		},
+	}
+	columns, rows, err := parseBulkRows(request)
+	if err != nil {
+		t.Fatalf("parseBulkRows returned error: %v", err)
+	}
+	if len(columns) != 2 || len(rows) != 2 || rows[1][1] != nil {
+		t.Fatalf("unexpected rows: %v %#v", columns, rows)
+	}
+
+	request.Params.Arguments = map[string]any{"csv": "ts,value\n2025-01-01 00:00:00,1.5\n\"2025-01-01 00:00:01\",\n"}
+	columns, rows, err = parseBulkRows(request)
+	if err != nil {
+		t.Fatalf("parseBulkRows with csv returned error: %v", err)
+	}
+	if strings.Join(columns, ",") != "ts,value" || len(rows) != 2 || rows[0][1] != "1.5" || rows[1][1] != nil {
+		t.Fatalf("unexpected csv rows: %v %#v", columns, rows)
+	}
+
+	for _, args := range []map[string]any{
+		{},
+		{"rows": []any{[]any{1}}},
+		{"columns": []any{"a"}, "rows": []any{1}},
+		{"columns": []any{"a"}, "rows": []any{[]any{1}}, "csv": "1"},
+		{"columns": []any{"a", "b"}, "csv": "1,2\n3"},
+	} {
+		request.Params.Arguments = args
+		if _, _, err := parseBulkRows(request); err == nil {
+			t.Errorf("parseBulkRows(%v) should fail", args)
+		}
 	}
 }
 
-func TestAddLimitToQuery(t *testing.T) {
-	sql := "SELECT * FROM test_table;"
-	got := addLimitToQuery(sql, 20)
+func TestBulkInsertStatementPolicy(t *testing.T) {
+	stmt, err := bulkInsertStatement("shop.Orders", []string{"id", "Note"})
+	if err != nil {
+		t.Fatalf("bulkInsertStatement returned error: %v", err)
+	}
+	if stmt != `INSERT INTO "shop"."orders" ("id", "Note") VALUES ($1, $2)` {
+		t.Fatalf("unexpected statement %s", stmt)
+	}
+	rules := policy.Rules{DenyTables: []string{"shop.orders"}}
+	if v := rules.Check(stmt, "other"); v == nil || v.Rule != policy.RuleDenyTables {
+		t.Fatalf("the load should be checked against deny_tables, got %v", v)
+	}
+}
+
+func TestReadQueryAsResourceArguments(t *testing.T) {
+	call := func(config Config, args map[string]any) *mcp.CallToolResult {
+		t.Helper()
+		s := server.NewMCPServer("test", "1.0", server.WithToolCapabilities(true))
+		RegisterToolsWithConfig(s, config)
+		request := mcp.CallToolRequest{Header: http.Header{}, Params: mcp.CallToolParams{Name: "read-query", Arguments: args}}
+		request.Header.Set("X-Database-URI", "postgres://root@localhost:26257/db1")
+		result, err := s.GetTool("read-query").Handler(context.Background(), request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
 
-	if got != "SELECT * FROM test_table LIMIT 20;" {
-		t.Fatalf("addLimitToQuery(%q, 20) = %q", sql, got)
+	if result := call(Config{}, map[string]any{"sql": "SELECT 1", "as_resource": true}); !result.IsError {
+		t.Fatal("as_resource should be refused without a result store")
+	}
+	config := Config{Results: resources.NewResultStore()}
+	if result := call(config, map[string]any{"cursor": "abc", "as_resource": true}); !result.IsError {
+		t.Fatal("as_resource should not be combined with cursor")
+	}
+	if result := call(config, map[string]any{"sql": "SELECT 1", "as_resource": true}); !result.IsError {
+		t.Fatal("as_resource should be refused without a client session")
 	}
 }
@@ -0,0 +1,128 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gitee.com/kwdb/kwdb-mcp-server/pkg/db"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// registerTransactionTools registers the session transaction tools.
+func registerTransactionTools(s *server.MCPServer) {
+	beginTool := mcp.NewTool("begin-transaction",
+		mcp.WithDescription("Begin an explicit transaction on KWDB (KaiwuDB) for this MCP session. "+
+			"Until commit-transaction or rollback-transaction is called, read-query, write-query and execute-script run inside it. "+
+			"A transaction left idle is rolled back automatically, and so is any open transaction when the session closes."),
+		mcp.WithString("isolation_level",
+			mcp.Description("Isolation level: serializable, repeatable read, read committed or read uncommitted. Defaults to the database default (serializable)."),
+		),
+		mcp.WithBoolean("read_only",
+			mcp.Description("Begin a read-only transaction (default false)."),
+		),
+		mcp.WithRawOutputSchema(json.RawMessage(validOutputSchema)),
+	)
+
+	s.AddTool(beginTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// 从请求头中获取数据库 URI，用于多租户多数据库访问。
+		headerURI := request.Header.Get("X-Database-URI")
+		useURI, _, missingHeader := resolveDBTarget(headerURI, db.IsDefaultPoolInitialized())
+		if missingHeader {
+			return mcp.NewToolResultError("missing X-Database-URI header"), nil
+		}
+
+		// 获取连接和开始事务受语句超时限制，事务本身在调用结束后继续存在
+		ctx, cancel := db.WithStatementTimeout(ctx, db.StatementTimeoutFor(useURI))
+		defer cancel()
+		stx, err := db.GetTxManager().Begin(
+			ctx,
+			sessionIDFromContext(ctx),
+			useURI,
+			request.GetString("isolation_level", ""),
+			request.GetBool("read_only", false),
+		)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Failed to begin transaction", err), nil
+		}
+
+		return transactionResult("open", stx)
+	})
+
+	commitTool := mcp.NewTool("commit-transaction",
+		mcp.WithDescription("Commit the explicit transaction of this MCP session."),
+		mcp.WithRawOutputSchema(json.RawMessage(validOutputSchema)),
+	)
+
+	s.AddTool(commitTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// 从请求头中获取数据库 URI，必须与开启事务时相同
+		headerURI := request.Header.Get("X-Database-URI")
+		useURI, _, missingHeader := resolveDBTarget(headerURI, db.IsDefaultPoolInitialized())
+		if missingHeader {
+			return mcp.NewToolResultError("missing X-Database-URI header"), nil
+		}
+
+		stx, err := db.GetTxManager().Commit(sessionIDFromContext(ctx), useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Failed to commit transaction", err), nil
+		}
+		return transactionResult("committed", stx)
+	})
+
+	rollbackTool := mcp.NewTool("rollback-transaction",
+		mcp.WithDescription("Roll back the explicit transaction of this MCP session."),
+		mcp.WithRawOutputSchema(json.RawMessage(validOutputSchema)),
+	)
+
+	s.AddTool(rollbackTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// 从请求头中获取数据库 URI，必须与开启事务时相同
+		headerURI := request.Header.Get("X-Database-URI")
+		useURI, _, missingHeader := resolveDBTarget(headerURI, db.IsDefaultPoolInitialized())
+		if missingHeader {
+			return mcp.NewToolResultError("missing X-Database-URI header"), nil
+		}
+
+		stx, err := db.GetTxManager().Rollback(sessionIDFromContext(ctx), useURI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("Failed to roll back transaction", err), nil
+		}
+		return transactionResult("rolled_back", stx)
+	})
+}
+
+// transactionResult builds the standardized response of the transaction tools.
+func transactionResult(state string, stx *db.SessionTx) (*mcp.CallToolResult, error) {
+	response := map[string]interface{}{
+		"status": "success",
+		"type":   "transaction",
+		"data": map[string]interface{}{
+			"result_type":     "transaction",
+			"state":           state,
+			"isolation_level": stx.Isolation,
+			"read_only":       stx.ReadOnly,
+			"started_at":      stx.StartedAt.UTC().Format(time.RFC3339Nano),
+		},
+		"error": nil,
+	}
+
+	jsonResult, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize result: %v", err)
+	}
+	return mcp.NewToolResultStructured(response, string(jsonResult)), nil
+}
+
+// withSessionTx attaches the open transaction of the calling session to ctx, so statements run in it.
+// It fails when the transaction was begun against a different database than databaseURI.
+func withSessionTx(ctx context.Context, databaseURI string) (context.Context, error) {
+	stx := db.GetTxManager().Get(sessionIDFromContext(ctx))
+	if stx == nil {
+		return ctx, nil
+	}
+	if stx.DatabaseURI != databaseURI {
+		return ctx, fmt.Errorf("this session has an open transaction on another database; commit or roll it back first")
+	}
+	return db.WithSessionTx(ctx, stx), nil
+}